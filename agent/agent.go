@@ -1,222 +1,409 @@
 package agent
 
 import (
-    "encoding/json"
+    "errors"
     "fmt"
     "os"
+    "path/filepath"
+    "sort"
     "strings"
+    "sync"
+    "time"
     
     "jkneen.ai-agent/llm"
+    "jkneen.ai-agent/lsp"
     "jkneen.ai-agent/tools"
 )
 
 // Message represents a single message in the conversation
 type Message struct {
-    Role    string `json:"role"`
-    Content string `json:"content"`
+    Role        string           `json:"role"`
+    Content     string           `json:"content"`
+    ToolCalls   []llm.ToolCall   `json:"tool_calls,omitempty"`
+    ToolResults []llm.ToolResult `json:"tool_results,omitempty"`
 }
 
-// Agent holds the state and logic for the AI agent
+// DefaultMaxToolIterations bounds how many rounds of tool calls a single turn may make
+const DefaultMaxToolIterations = 25
+
+// ErrBusy is returned by TryProcess, Undo and Restore while a turn is running
+var ErrBusy = errors.New("agent is busy with another turn")
+
+// Agent holds the state and logic for the AI agent. It is safe for
+// concurrent use: turns run one at a time, while history and state can be
+// read at any time.
 type Agent struct {
+    turnMu sync.Mutex   // Held for the duration of a turn
+    mu     sync.RWMutex // Guards context and permissions
+
     context      []Message
-    contextFile  string
-    llmClient    *llm.Client
+    store        Store // Nil if the session is not persisted
+    llmClient    llm.Provider
     toolRegistry map[string]tools.Tool
+
+    maxToolIterations int
+    maxParallelTools  int
+    readOnly          bool // Set for sub-agents, which may only make read-only tool calls
+
+    checkpoints checkpointStore
+    permissions PermissionPolicy
+
+    lspClient *lsp.Client // Nil unless a language server is configured
+    warnings  []string    // Non-fatal problems found while starting, such as broken plugins
+
+    promptData PromptData // Environment details reused for sub-agent prompts
+    plan       planState
+    todos      todoList
+    hooks      hookSet
+    events     eventBus
+    usageMu    sync.Mutex // Guards turnUsage, which parallel sub-agents add to
+    turnUsage  llm.Usage  // Tokens used so far in the current turn
 }
 
-// NewAgent initializes a new agent with a context file
-func NewAgent(contextFile string) (*Agent, error) {
-    llmClient := llm.NewClient() // Initialize Claude client
-    
-    // Create system message with tool descriptions
-    systemMessage := "You are a helpful AI assistant powered by Claude. You have access to these tools:\n\n"
-    
+// NewAgent initializes an agent for the command line: it talks to Claude,
+// keeps the session in contextFile and loads the built-in tools, plugins,
+// instructions, memories and hooks configured for the working directory.
+// opts are applied after these defaults: WithProvider, WithStore and
+// WithSystemPrompt replace them, while WithTools adds tools alongside the
+// built-in ones.
+func NewAgent(contextFile string, opts ...Option) (*Agent, error) {
     // Will be populated with registered tools
     toolRegistry := make(map[string]tools.Tool)
     
     // Register all available tools
-    webSearchTool := &tools.WebSearchTool{}
     fileSearchTool := &tools.FileSearchTool{RootDir: "."}
-    fileReadTool := &tools.FileReadTool{}
-    fileEditTool := &tools.FileEditTool{}
+    fileTracker := tools.NewFileTracker() // Shared so edits can detect files changed since they were read
+    fileReadTool := &tools.FileReadTool{Tracker: fileTracker}
+    fileEditTool := &tools.FileEditTool{Tracker: fileTracker}
+    applyPatchTool := &tools.ApplyPatchTool{Tracker: fileTracker}
+    gitTool := &tools.GitTool{}
+    goTestTool := &tools.GoTestTool{}
+    goSymbolsTool := &tools.GoSymbolsTool{}
     
     // Add tools to registry
-    toolRegistry[webSearchTool.GetName()] = webSearchTool
     toolRegistry[fileSearchTool.GetName()] = fileSearchTool
     toolRegistry[fileReadTool.GetName()] = fileReadTool
     toolRegistry[fileEditTool.GetName()] = fileEditTool
+    toolRegistry[applyPatchTool.GetName()] = applyPatchTool
+    toolRegistry[gitTool.GetName()] = gitTool
+    toolRegistry[goTestTool.GetName()] = goTestTool
+    toolRegistry[goSymbolsTool.GetName()] = goSymbolsTool
+    
+    workingDir, err := os.Getwd()
+    if err != nil {
+        return nil, fmt.Errorf("failed to get working directory: %w", err)
+    }
+    
+    config, err := LoadConfig(workingDir)
+    if err != nil {
+        return nil, err
+    }
     
-    // Build system message with tool descriptions
-    for _, tool := range toolRegistry {
-        systemMessage += fmt.Sprintf("- %s: %s\n", tool.GetName(), tool.GetDescription())
-    }
-    
-    systemMessage += "\nTo use a tool, simply mention its name and what you want to do with it. For example: 'I need to use the web_search tool to find information about...' or 'I'll use file_search to look for...'."
-    
-    // Add special instructions for the file_edit tool
-    systemMessage += "\n\nTo use the file_edit tool, include a JSON object with the following structure:"
-    systemMessage += "\n```json"
-    systemMessage += "\n{"
-    systemMessage += "\n  \"file_path\": \"path/to/file.txt\", // Required: Path to the file to edit"
-    systemMessage += "\n  \"operation\": \"replace\", // Required: Either 'replace' or 'append'"
-    systemMessage += "\n  \"content\": \"new content\", // Required: The content to write"
-    systemMessage += "\n  \"start_line\": 1, // Optional: Line number to start replacing (only for replace)"
-    systemMessage += "\n  \"end_line\": 5 // Optional: Line number to end replacing (only for replace)"
-    systemMessage += "\n}"
-    systemMessage += "\n```"
-    systemMessage += "\nFor example: 'I'll use the file_edit tool to update the README.md file: {\"file_path\": \"README.md\", \"operation\": \"replace\", \"content\": \"# Updated README\"}'."
-    
-    ag := &Agent{
-        context:      []Message{{Role: "system", Content: systemMessage}},
-        contextFile:  contextFile,
-        llmClient:    llmClient,
-        toolRegistry: toolRegistry,
-    }
-
-    // Load existing context if available
-    if err := ag.loadContext(); err != nil {
-        // Ignore if file doesn't exist
-        if !os.IsNotExist(err) {
-            return nil, err
+    // Merge project and user instruction files (AGENTS.md etc.)
+    instructions, err := LoadInstructions(workingDir)
+    if err != nil {
+        return nil, fmt.Errorf("failed to load instructions: %w", err)
+    }
+    
+    // Web search is only offered when a provider is configured
+    if config.SearchProvider != "" {
+        provider, err := tools.NewSearchProvider(config.SearchProvider, config.SearchURL, config.SearchAPIKey)
+        if err != nil {
+            return nil, fmt.Errorf("invalid search configuration: %w", err)
         }
+        webSearchTool := &tools.WebSearchTool{Provider: provider}
+        toolRegistry[webSearchTool.GetName()] = webSearchTool
     }
-
+    
+    webFetchTool := &tools.WebFetchTool{
+        AllowDomains: config.FetchAllowDomains,
+        DenyDomains:  config.FetchDenyDomains,
+    }
+    if cacheDir, err := os.UserCacheDir(); err == nil {
+        webFetchTool.CacheDir = filepath.Join(cacheDir, "ai-agent", "web")
+    }
+    toolRegistry[webFetchTool.GetName()] = webFetchTool
+    
+    // The language server is only started when a tool first needs it
+    var lspClient *lsp.Client
+    if len(config.LSPCommand) > 0 {
+        lspClient = lsp.NewClient(config.LSPCommand, workingDir)
+        lspClient.Languages = config.LSPLanguages
+        lspTool := &tools.LSPTool{Client: lspClient}
+        toolRegistry[lspTool.GetName()] = lspTool
+        fileEditTool.Diagnostics = lspClient
+    }
+    
+    // Executables in the plugin directories become tools; built-in tools keep their names
+    warnings := config.warnings
+    pluginDirs := config.PluginDirs
+    if configDir, err := userConfigDir(); err == nil {
+        pluginDirs = append([]string{filepath.Join(configDir, "plugins")}, pluginDirs...)
+    }
+    for _, dir := range pluginDirs {
+        plugins, errs := tools.LoadPlugins(dir)
+        for _, err := range errs {
+            warnings = append(warnings, err.Error())
+        }
+        for _, plugin := range plugins {
+            if _, exists := toolRegistry[plugin.GetName()]; exists {
+                warnings = append(warnings, fmt.Sprintf("plugin %s: tool name %q is already in use", plugin.Path, plugin.GetName()))
+                continue
+            }
+            toolRegistry[plugin.GetName()] = plugin
+        }
+    }
+    
+    // Memories live under the user config dir, split into user-wide and per-project files
+    var memories []tools.Memory
+    if configDir, err := userConfigDir(); err == nil {
+        memoryStore := tools.NewMemoryStore(filepath.Join(configDir, "memory"), tools.ProjectMemoryDir(configDir, workingDir))
+        for _, tool := range []tools.Tool{
+            &tools.MemorySaveTool{Store: memoryStore},
+            &tools.MemorySearchTool{Store: memoryStore},
+            &tools.MemoryDeleteTool{Store: memoryStore},
+        } {
+            toolRegistry[tool.GetName()] = tool
+        }
+        if memories, err = memoryStore.All(); err != nil {
+            warnings = append(warnings, err.Error())
+        }
+    }
+    
+    toolList := make([]tools.Tool, 0, len(toolRegistry))
+    for _, name := range sortedToolNames(toolRegistry) {
+        toolList = append(toolList, toolRegistry[name])
+    }
+    defaults := []Option{
+        withDefaultProvider(func() llm.Provider { return llm.NewClient() }), // Claude, unless WithProvider is given
+        WithTools(toolList...),
+        WithAgentTools(),
+        WithStore(&FileStore{Path: contextFile}),
+        withConfig(config, workingDir, instructions, memories),
+    }
+    ag, err := New(append(defaults, opts...)...)
+    if err != nil {
+        if lspClient != nil {
+            lspClient.Close()
+        }
+        return nil, err
+    }
+    ag.lspClient = lspClient
+    ag.warnings = warnings
     return ag, nil
 }
 
-// loadContext reads the conversation context from a file
-func (a *Agent) loadContext() error {
-    data, err := os.ReadFile(a.contextFile)
-    if err != nil {
-        return err
+// Warnings returns problems found while starting the agent that did not
+// prevent it from running, such as plugins that failed to load
+func (a *Agent) Warnings() []string {
+    return a.warnings
+}
+
+// Close releases resources held by the agent, such as the language server
+func (a *Agent) Close() error {
+    if a.lspClient != nil {
+        return a.lspClient.Close()
     }
-    return json.Unmarshal(data, &a.context)
+    return nil
 }
 
-// SaveContext writes the conversation context to a file
+// SaveContext writes the conversation context and todo list to the store.
+// It may be called during a turn, saving the conversation so far.
 func (a *Agent) SaveContext() error {
-    data, err := json.MarshalIndent(a.context, "", "  ")
-    if err != nil {
-        return err
+    if a.store == nil {
+        return nil
     }
-    return os.WriteFile(a.contextFile, data, 0644)
+    return a.store.Save(&Session{Messages: a.History(), Todos: a.Todos()})
+}
+
+// History returns a copy of the conversation, starting with the system prompt
+func (a *Agent) History() []Message {
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+    return append([]Message(nil), a.context...)
+}
+
+// addMessages appends messages to the conversation
+func (a *Agent) addMessages(messages ...Message) {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    a.context = append(a.context, messages...)
 }
 
-// Process handles user input and returns a response
+// Process handles user input and returns a response, running the
+// SessionStart, UserPromptSubmit and Stop hooks around the turn and
+// publishing its progress to subscribers. If another turn is running,
+// Process waits for it to finish first.
 func (a *Agent) Process(input string) (string, error) {
-    // Add user message to context
-    a.context = append(a.context, Message{Role: "user", Content: input})
+    a.turnMu.Lock()
+    defer a.turnMu.Unlock()
+    return a.runTurn(input)
+}
 
-    // First get a response from the LLM
-    llmMessages := convertToLLMMessages(a.context)
-    llmResponse, err := a.llmClient.Query(llmMessages)
+// TryProcess is like Process but returns ErrBusy instead of waiting when
+// another turn is running
+func (a *Agent) TryProcess(input string) (string, error) {
+    if !a.turnMu.TryLock() {
+        return "", ErrBusy
+    }
+    defer a.turnMu.Unlock()
+    return a.runTurn(input)
+}
+
+// runTurn runs a turn; the caller holds turnMu
+func (a *Agent) runTurn(input string) (string, error) {
+    started := time.Now()
+    a.usageMu.Lock()
+    a.turnUsage = llm.Usage{}
+    a.usageMu.Unlock()
+    a.emit(TurnStartedEvent{Prompt: input})
+    
+    response, err := a.processWithHooks(input)
+    if err != nil {
+        a.emit(ErrorEvent{Err: err})
+    }
+    a.usageMu.Lock()
+    usage := a.turnUsage
+    a.usageMu.Unlock()
+    a.emit(TurnFinishedEvent{Response: response, Usage: usage, Duration: time.Since(started)})
+    return response, err
+}
+
+// processWithHooks runs a turn between the prompt hooks and the Stop hooks
+func (a *Agent) processWithHooks(input string) (string, error) {
+    input, hookFailures, err := a.submitPrompt(input)
+    if err != nil {
+        return "", err
+    }
+    
+    response, err := a.process(input)
     if err != nil {
         return "", err
     }
     
-    // Check if the LLM response wants to use a tool
-    if a.shouldUseTool(llmResponse) {
-        // Detect which tool to use from the response
-        toolName := a.detectToolName(llmResponse)
-        
-        // Add the LLM's "I want to use a tool" response to the context
-        a.context = append(a.context, Message{Role: "assistant", Content: llmResponse})
-        
-        // Execute the tool
-        toolResponse, err := a.executeTool(toolName, llmResponse)
+    if _, err := a.runHooks(HookEvent{Event: HookStop, WorkingDir: a.promptData.WorkingDir, Response: response}); err != nil {
+        hookFailures = append(hookFailures, err.Error())
+    }
+    for _, failure := range hookFailures {
+        response += fmt.Sprintf("\n\n(%s)", failure)
+    }
+    return response, nil
+}
+
+// submitPrompt runs the SessionStart hooks on the first turn and the
+// UserPromptSubmit hooks on every turn, returning the prompt to send and
+// any hook failures to report with the response
+func (a *Agent) submitPrompt(input string) (string, []string, error) {
+    var events []HookEvent
+    a.hooks.sessionStart.Do(func() {
+        events = append(events, HookEvent{Event: HookSessionStart, WorkingDir: a.promptData.WorkingDir})
+    })
+    events = append(events, HookEvent{Event: HookUserPromptSubmit, WorkingDir: a.promptData.WorkingDir, Prompt: input})
+    
+    var failures, messages []string
+    for _, event := range events {
+        result, err := a.runHooks(event)
         if err != nil {
-            return "", fmt.Errorf("tool execution error: %w", err)
+            failures = append(failures, err.Error())
         }
-        
-        // Add tool response to context
-        toolRoleMessage := fmt.Sprintf("Tool '%s' returned: %s", toolName, toolResponse)
-        a.context = append(a.context, Message{Role: "tool", Content: toolRoleMessage})
-        
-        // Get final response from LLM with tool results
-        llmMessages = convertToLLMMessages(a.context)
-        finalResponse, err := a.llmClient.Query(llmMessages)
-        if err != nil {
-            return "", err
+        if result.Block {
+            return "", nil, fmt.Errorf("prompt blocked by a hook: %s", result.Reason)
+        }
+        if result.Prompt != "" {
+            input = result.Prompt
+        }
+        if result.Message != "" {
+            messages = append(messages, result.Message)
         }
-        
-        // Add final response to context
-        a.context = append(a.context, Message{Role: "assistant", Content: finalResponse})
-        return finalResponse, nil
     }
-
-    // No tool needed, just return the LLM response
-    a.context = append(a.context, Message{Role: "assistant", Content: llmResponse})
-    return llmResponse, nil
+    // Hook output is passed to the model as extra context for the prompt
+    if len(messages) > 0 {
+        input += "\n\n" + strings.Join(messages, "\n")
+    }
+    return input, failures, nil
 }
 
-// shouldUseTool determines if a tool is needed based on LLM response
-func (a *Agent) shouldUseTool(input string) bool {
-    for toolName := range a.toolRegistry {
-        if strings.Contains(strings.ToLower(input), strings.ToLower(toolName)) {
-            return true
-        }
+// process runs a single turn: the model's response and any tool calls
+func (a *Agent) process(input string) (string, error) {
+    // File snapshots taken during this turn are grouped into one checkpoint
+    a.checkpoints.beginTurn(input, len(a.History()))
+    
+    // Add user message to context
+    a.addMessages(Message{Role: "user", Content: input})
+
+    // First get a response from the LLM
+    response, err := a.complete()
+    if err != nil {
+        return "", err
     }
-    // Also check for explicit tool usage phrases
-    return strings.Contains(strings.ToLower(input), "use the") && 
-           strings.Contains(strings.ToLower(input), "tool")
+    return a.runToolLoop(response)
 }
 
-// detectToolName extracts the tool name from LLM response
-func (a *Agent) detectToolName(input string) string {
-    for toolName := range a.toolRegistry {
-        if strings.Contains(strings.ToLower(input), strings.ToLower(toolName)) {
-            return toolName
-        }
+// complete asks the model to respond to the context, publishing its text
+// and counting its token usage towards the turn
+func (a *Agent) complete() (*llm.Response, error) {
+    response, err := a.llmClient.Complete(a.llmMessages(), a.toolDefinitions())
+    if err != nil {
+        return nil, err
     }
-    return "web_search" // fallback to web_search if no specific tool detected
+    a.addUsage(response.Usage)
+    if response.Text != "" {
+        a.emit(ModelDeltaEvent{Text: response.Text})
+    }
+    return response, nil
+}
+
+// addUsage counts tokens towards the current turn
+func (a *Agent) addUsage(usage llm.Usage) {
+    a.usageMu.Lock()
+    defer a.usageMu.Unlock()
+    a.turnUsage.Add(usage)
 }
 
-// extractToolInput extracts the input for the tool from LLM response
-func (a *Agent) extractToolInput(toolName, input string) string {
-    // For file_edit tool, extract JSON content
-    if toolName == "file_edit" {
-        // Find JSON in the input
-        jsonStart := strings.Index(input, "{")
-        jsonEnd := strings.LastIndex(input, "}")
-        
-        if jsonStart != -1 && jsonEnd != -1 && jsonEnd > jsonStart {
-            return input[jsonStart : jsonEnd+1]
+// runToolLoop executes the model's native tool calls and feeds the results
+// back until the model answers without requesting further tools
+func (a *Agent) runToolLoop(response *llm.Response) (string, error) {
+    for iteration := 0; len(response.ToolCalls) > 0; iteration++ {
+        if iteration >= a.maxToolIterations {
+            return "", fmt.Errorf("stopped after %d rounds of tool calls", a.maxToolIterations)
+        }
+
+        a.addMessages(Message{Role: "assistant", Content: response.Text, ToolCalls: response.ToolCalls})
+        results := a.executeToolCalls(response.ToolCalls)
+        a.addMessages(Message{Role: "tool", ToolResults: results})
+
+        var err error
+        response, err = a.complete()
+        if err != nil {
+            return "", err
         }
-        
-        // If we couldn't extract JSON, return a helpful error message
-        return `{"error": "Could not extract valid JSON from input. Please provide a valid JSON object with file_path, operation, and content fields."}`
-    }
-    
-    // Regular extraction for other tools
-    toolNameIndex := strings.Index(strings.ToLower(input), strings.ToLower(toolName))
-    if toolNameIndex == -1 {
-        return input
-    }
-    
-    // Get content after tool name
-    afterToolName := input[toolNameIndex+len(toolName):]
-    
-    // Clean up - remove common phrases that might appear
-    phrases := []string{"tool", "to", "with", "for", "using", "use", "the", ":"}
-    cleanInput := afterToolName
-    for _, phrase := range phrases {
-        cleanInput = strings.ReplaceAll(cleanInput, phrase, "")
     }
-    
-    return strings.TrimSpace(cleanInput)
+
+    a.addMessages(Message{Role: "assistant", Content: response.Text})
+    return response.Text, nil
 }
 
-// executeTool runs a registered tool
-func (a *Agent) executeTool(toolName, input string) (string, error) {
-    tool, exists := a.toolRegistry[toolName]
-    if !exists {
-        return "", fmt.Errorf("tool %s not found", toolName)
+// toolDefinitions describes the registered tools to the model, sorted by name.
+// In plan mode only read-only tools are offered, and submit_plan only then.
+func (a *Agent) toolDefinitions() []llm.ToolDefinition {
+    planMode := a.InPlanMode()
+    definitions := make([]llm.ToolDefinition, 0, len(a.toolRegistry))
+    for _, tool := range a.toolRegistry {
+        if _, isSubmitPlan := tool.(*SubmitPlanTool); isSubmitPlan {
+            if !planMode {
+                continue
+            }
+        } else if planMode && !tools.HasReadOnlyCalls(tool) && !allowedInPlanMode(tool) {
+            continue
+        }
+        definitions = append(definitions, llm.ToolDefinition{
+            Name:        tool.GetName(),
+            Description: tool.GetDescription(),
+            InputSchema: tool.GetInputSchema(),
+        })
     }
-    
-    // Extract actual input for the tool
-    toolInput := a.extractToolInput(toolName, input)
-    
-    return tool.Execute(toolInput)
+    sort.Slice(definitions, func(i, j int) bool { return definitions[i].Name < definitions[j].Name })
+    return definitions
 }
 
 // convertToLLMMessages converts agent messages to LLM messages
@@ -224,8 +411,10 @@ func convertToLLMMessages(agentMessages []Message) []llm.Message {
     llmMessages := make([]llm.Message, len(agentMessages))
     for i, msg := range agentMessages {
         llmMessages[i] = llm.Message{
-            Role:    msg.Role,
-            Content: msg.Content,
+            Role:        msg.Role,
+            Content:     msg.Content,
+            ToolCalls:   msg.ToolCalls,
+            ToolResults: msg.ToolResults,
         }
     }
     return llmMessages
@@ -0,0 +1,233 @@
+package agent
+
+import (
+    "errors"
+    "fmt"
+    "path/filepath"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "testing"
+    "time"
+
+    "jkneen.ai-agent/llm"
+)
+
+// echoProvider answers each prompt with "re: <prompt>" after an optional
+// delay, tracking how many requests are in flight at once
+type echoProvider struct {
+    delay   time.Duration
+    started chan struct{} // If set, receives a value as each request starts
+    release chan struct{} // If set, each request waits for a value before answering
+
+    inFlight    atomic.Int32
+    maxInFlight atomic.Int32
+}
+
+func (p *echoProvider) Complete(messages []llm.Message, toolDefs []llm.ToolDefinition) (*llm.Response, error) {
+    n := p.inFlight.Add(1)
+    defer p.inFlight.Add(-1)
+    for {
+        max := p.maxInFlight.Load()
+        if n <= max || p.maxInFlight.CompareAndSwap(max, n) {
+            break
+        }
+    }
+    if p.started != nil {
+        p.started <- struct{}{}
+    }
+    if p.release != nil {
+        <-p.release
+    }
+    time.Sleep(p.delay)
+    return &llm.Response{Text: "re: " + messages[len(messages)-1].Content, StopReason: "end_turn"}, nil
+}
+
+// todoProvider answers each prompt by calling todo_write with the prompt as
+// the only item, then replies "done" once it sees the tool result
+type todoProvider struct{}
+
+func (todoProvider) Complete(messages []llm.Message, toolDefs []llm.ToolDefinition) (*llm.Response, error) {
+    last := messages[len(messages)-1]
+    if len(last.ToolResults) > 0 {
+        return &llm.Response{Text: "done", StopReason: "end_turn"}, nil
+    }
+    input := fmt.Sprintf(`{"todos":[{"content":%q,"status":"in_progress"}]}`, last.Content)
+    return &llm.Response{
+        ToolCalls:  []llm.ToolCall{{ID: "call-1", Name: "todo_write", Input: []byte(input)}},
+        StopReason: "tool_use",
+    }, nil
+}
+
+func newTestAgent(t *testing.T, provider llm.Provider, opts ...Option) *Agent {
+    t.Helper()
+    ag, err := New(append([]Option{WithProvider(provider), WithSystemPrompt("You are a test agent.")}, opts...)...)
+    if err != nil {
+        t.Fatalf("New: %v", err)
+    }
+    return ag
+}
+
+func TestProcessSerializesConcurrentTurns(t *testing.T) {
+    provider := &echoProvider{delay: time.Millisecond}
+    ag := newTestAgent(t, provider)
+
+    const turns = 20
+    var wg sync.WaitGroup
+    for i := 0; i < turns; i++ {
+        wg.Add(1)
+        go func(i int) {
+            defer wg.Done()
+            prompt := fmt.Sprintf("prompt %d", i)
+            response, err := ag.Process(prompt)
+            if err != nil {
+                t.Errorf("Process(%q): %v", prompt, err)
+            } else if response != "re: "+prompt {
+                t.Errorf("Process(%q) = %q", prompt, response)
+            }
+        }(i)
+    }
+    wg.Wait()
+
+    if max := provider.maxInFlight.Load(); max != 1 {
+        t.Errorf("%d model requests ran at once, want 1", max)
+    }
+    history := ag.History()
+    if len(history) != 1+2*turns {
+        t.Fatalf("history has %d messages, want %d", len(history), 1+2*turns)
+    }
+    // Each prompt must be directly followed by its own answer
+    for i := 1; i < len(history); i += 2 {
+        prompt, answer := history[i], history[i+1]
+        if prompt.Role != "user" || answer.Role != "assistant" || answer.Content != "re: "+prompt.Content {
+            t.Errorf("messages %d-%d are not a prompt and its answer: %+v, %+v", i, i+1, prompt, answer)
+        }
+    }
+}
+
+func TestOverlappingTurnsAreRejected(t *testing.T) {
+    provider := &echoProvider{started: make(chan struct{}), release: make(chan struct{})}
+    ag := newTestAgent(t, provider)
+
+    done := make(chan error)
+    go func() {
+        _, err := ag.Process("first")
+        done <- err
+    }()
+    <-provider.started // The first turn is now waiting on the model
+
+    tests := []struct {
+        name string
+        call func() error
+    }{
+        {"TryProcess", func() error { _, err := ag.TryProcess("second"); return err }},
+        {"Undo", func() error { _, err := ag.Undo(); return err }},
+        {"Restore", func() error { _, err := ag.Restore(1, true); return err }},
+    }
+    for _, test := range tests {
+        if err := test.call(); !errors.Is(err, ErrBusy) {
+            t.Errorf("%s during a turn returned %v, want ErrBusy", test.name, err)
+        }
+    }
+
+    provider.release <- struct{}{}
+    if err := <-done; err != nil {
+        t.Fatalf("first turn: %v", err)
+    }
+
+    // Once the turn is over the agent accepts new turns again
+    go func() { <-provider.started; provider.release <- struct{}{} }()
+    response, err := ag.TryProcess("second")
+    if err != nil || response != "re: second" {
+        t.Errorf("TryProcess after the turn = %q, %v", response, err)
+    }
+}
+
+func TestStateCanBeReadDuringTurns(t *testing.T) {
+    store := &FileStore{Path: filepath.Join(t.TempDir(), "session.json")}
+    ag := newTestAgent(t, todoProvider{}, WithStore(store), WithAgentTools())
+    var toolResults atomic.Int32
+    ag.Subscribe(func(event Event) {
+        if _, ok := event.(ToolResultEvent); ok {
+            toolResults.Add(1)
+        }
+    })
+
+    stop := make(chan struct{})
+    var readers sync.WaitGroup
+    for i := 0; i < 2; i++ {
+        readers.Add(1)
+        go func() {
+            defer readers.Done()
+            for {
+                select {
+                case <-stop:
+                    return
+                default:
+                }
+                for _, message := range ag.History() {
+                    _ = strings.TrimSpace(message.Content)
+                }
+                if err := ag.SaveContext(); err != nil {
+                    t.Errorf("SaveContext: %v", err)
+                    return
+                }
+                ag.Todos()
+                ag.Checkpoints()
+                ag.InPlanMode()
+                ag.SetPermissionPolicy(DenyAll)
+            }
+        }()
+    }
+
+    const workers, turnsEach = 4, 10
+    var turns sync.WaitGroup
+    for i := 0; i < workers; i++ {
+        turns.Add(1)
+        go func(i int) {
+            defer turns.Done()
+            for j := 0; j < turnsEach; j++ {
+                if response, err := ag.Process(fmt.Sprintf("prompt %d.%d", i, j)); err != nil || response != "done" {
+                    t.Errorf("Process = %q, %v", response, err)
+                }
+            }
+        }(i)
+    }
+    turns.Wait()
+    close(stop)
+    readers.Wait()
+
+    // Each turn adds a prompt, a tool call, its result and the answer
+    if got, want := len(ag.History()), 1+4*workers*turnsEach; got != want {
+        t.Errorf("history has %d messages, want %d", got, want)
+    }
+    if got := toolResults.Load(); got != workers*turnsEach {
+        t.Errorf("saw %d tool results, want %d", got, workers*turnsEach)
+    }
+    if todos := ag.Todos(); len(todos) != 1 || todos[0].Status != TodoInProgress {
+        t.Errorf("Todos() = %+v, want the last turn's item", todos)
+    }
+}
+
+func TestNewDefaultProvider(t *testing.T) {
+    var built int
+    defaultProvider := withDefaultProvider(func() llm.Provider {
+        built++
+        return &echoProvider{}
+    })
+
+    if _, err := New(defaultProvider, WithProvider(todoProvider{}), WithSystemPrompt("Test")); err != nil {
+        t.Fatalf("New with a provider: %v", err)
+    }
+    if built != 0 {
+        t.Errorf("default provider built %d times although WithProvider was given", built)
+    }
+
+    ag, err := New(defaultProvider, WithSystemPrompt("Test"))
+    if err != nil {
+        t.Fatalf("New without a provider: %v", err)
+    }
+    if _, ok := ag.llmClient.(*echoProvider); !ok || built != 1 {
+        t.Errorf("provider = %T after %d builds, want the default built once", ag.llmClient, built)
+    }
+}
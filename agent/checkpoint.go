@@ -0,0 +1,225 @@
+package agent
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "sync"
+    "time"
+
+    "jkneen.ai-agent/tools"
+)
+
+// FileSnapshot records a file's state before a tool first modified it in a turn
+type FileSnapshot struct {
+    Path    string
+    Existed bool
+    Content []byte
+    Mode    os.FileMode
+}
+
+// Checkpoint groups the file snapshots taken during a single turn
+type Checkpoint struct {
+    ID         int
+    Turn       int
+    Created    time.Time
+    Prompt     string         // The user input that started the turn
+    ContextLen int            // Length of the conversation before the turn began
+    Files      []FileSnapshot // In the order they were first modified
+}
+
+// checkpointStore keeps the checkpoints of a session in memory
+type checkpointStore struct {
+    mu          sync.Mutex
+    checkpoints []*Checkpoint
+    current     *Checkpoint // Checkpoint for the turn in progress, if any
+    nextID      int
+    turn        int
+}
+
+// beginTurn starts collecting snapshots for a new turn
+func (s *checkpointStore) beginTurn(prompt string, contextLen int) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.turn++
+    s.current = &Checkpoint{Turn: s.turn, Prompt: prompt, ContextLen: contextLen}
+}
+
+// snapshot records the current state of each path unless it was already
+// captured during this turn. The checkpoint is only kept once it holds a file.
+func (s *checkpointStore) snapshot(paths []string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.current == nil {
+        return nil
+    }
+
+    for _, path := range paths {
+        absPath, err := filepath.Abs(path)
+        if err != nil {
+            return fmt.Errorf("failed to resolve %s: %w", path, err)
+        }
+        if s.current.hasFile(absPath) {
+            continue
+        }
+
+        snapshot := FileSnapshot{Path: absPath}
+        info, err := os.Stat(absPath)
+        switch {
+        case os.IsNotExist(err):
+            // Restoring removes files the turn created
+        case err != nil:
+            return fmt.Errorf("failed to snapshot %s: %w", path, err)
+        case info.IsDir():
+            continue
+        default:
+            content, err := os.ReadFile(absPath)
+            if err != nil {
+                return fmt.Errorf("failed to snapshot %s: %w", path, err)
+            }
+            snapshot.Existed = true
+            snapshot.Content = content
+            snapshot.Mode = info.Mode().Perm()
+        }
+
+        if len(s.current.Files) == 0 {
+            s.nextID++
+            s.current.ID = s.nextID
+            s.current.Created = time.Now()
+            s.checkpoints = append(s.checkpoints, s.current)
+        }
+        s.current.Files = append(s.current.Files, snapshot)
+    }
+    return nil
+}
+
+// hasFile reports whether the checkpoint already holds a snapshot of path
+func (c *Checkpoint) hasFile(path string) bool {
+    for _, file := range c.Files {
+        if file.Path == path {
+            return true
+        }
+    }
+    return false
+}
+
+// list returns the checkpoints, oldest first
+func (s *checkpointStore) list() []Checkpoint {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    list := make([]Checkpoint, len(s.checkpoints))
+    for i, checkpoint := range s.checkpoints {
+        list[i] = *checkpoint
+    }
+    return list
+}
+
+// restore reverts files to their state before checkpoint id was taken by
+// undoing it and every later checkpoint, newest first. The restored
+// checkpoints are removed and the target is returned.
+func (s *checkpointStore) restore(id int) (*Checkpoint, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    index := -1
+    for i, checkpoint := range s.checkpoints {
+        if checkpoint.ID == id {
+            index = i
+            break
+        }
+    }
+    if index == -1 {
+        return nil, fmt.Errorf("checkpoint %d not found", id)
+    }
+
+    for i := len(s.checkpoints) - 1; i >= index; i-- {
+        checkpoint := s.checkpoints[i]
+        for j := len(checkpoint.Files) - 1; j >= 0; j-- {
+            // Restoring is idempotent, so a failed restore can simply be retried
+            if err := restoreSnapshot(checkpoint.Files[j]); err != nil {
+                return nil, err
+            }
+        }
+    }
+
+    target := s.checkpoints[index]
+    s.checkpoints = s.checkpoints[:index]
+    // Stop recording into a checkpoint that no longer exists
+    if s.current != nil && s.current.ID >= target.ID {
+        s.current = nil
+    }
+    return target, nil
+}
+
+// restoreSnapshot puts a single file back into its snapshotted state
+func restoreSnapshot(snapshot FileSnapshot) error {
+    if !snapshot.Existed {
+        if err := os.Remove(snapshot.Path); err != nil && !os.IsNotExist(err) {
+            return fmt.Errorf("failed to remove %s: %w", snapshot.Path, err)
+        }
+        return nil
+    }
+    if err := os.MkdirAll(filepath.Dir(snapshot.Path), 0755); err != nil {
+        return fmt.Errorf("failed to restore %s: %w", snapshot.Path, err)
+    }
+    if err := tools.WriteFileAtomic(snapshot.Path, snapshot.Content, snapshot.Mode); err != nil {
+        return fmt.Errorf("failed to restore %s: %w", snapshot.Path, err)
+    }
+    return nil
+}
+
+// snapshotFor captures the files a mutating tool is about to touch
+func (a *Agent) snapshotFor(tool tools.Tool, input string) error {
+    if tools.IsReadOnlyCall(tool, input) {
+        return nil
+    }
+    mutator, ok := tool.(tools.FileMutator)
+    if !ok {
+        return nil
+    }
+    return a.checkpoints.snapshot(mutator.MutatedFiles(input))
+}
+
+// Checkpoints returns the session's checkpoints, oldest first
+func (a *Agent) Checkpoints() []Checkpoint {
+    return a.checkpoints.list()
+}
+
+// Undo reverts the file changes made during the most recent turn that
+// changed files. It returns ErrBusy while a turn is running.
+func (a *Agent) Undo() (*Checkpoint, error) {
+    if !a.turnMu.TryLock() {
+        return nil, ErrBusy
+    }
+    defer a.turnMu.Unlock()
+    checkpoints := a.checkpoints.list()
+    if len(checkpoints) == 0 {
+        return nil, fmt.Errorf("nothing to undo")
+    }
+    return a.restore(checkpoints[len(checkpoints)-1].ID, false)
+}
+
+// Restore reverts files to their state before the given checkpoint. When
+// rewindConversation is set the conversation is also truncated to the point
+// before the checkpoint's turn began. It returns ErrBusy while a turn is running.
+func (a *Agent) Restore(id int, rewindConversation bool) (*Checkpoint, error) {
+    if !a.turnMu.TryLock() {
+        return nil, ErrBusy
+    }
+    defer a.turnMu.Unlock()
+    return a.restore(id, rewindConversation)
+}
+
+// restore implements Restore; the caller holds turnMu
+func (a *Agent) restore(id int, rewindConversation bool) (*Checkpoint, error) {
+    checkpoint, err := a.checkpoints.restore(id)
+    if err != nil {
+        return nil, err
+    }
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    if rewindConversation && checkpoint.ContextLen <= len(a.context) {
+        a.context = a.context[:checkpoint.ContextLen]
+    }
+    return checkpoint, nil
+}
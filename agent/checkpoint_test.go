@@ -0,0 +1,205 @@
+package agent
+
+import (
+    "encoding/json"
+    "errors"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "jkneen.ai-agent/llm/llmtest"
+    "jkneen.ai-agent/tools"
+)
+
+// fileOp is the input of fileOpTool
+type fileOp struct {
+    Path    string `json:"path"`
+    Content string `json:"content,omitempty"`
+    Delete  bool   `json:"delete,omitempty"`
+}
+
+// fileOpTool writes or deletes a file, reporting it as mutated so the agent
+// snapshots it first
+type fileOpTool struct{}
+
+func (fileOpTool) Execute(input string) (string, error) {
+    var op fileOp
+    if err := json.Unmarshal([]byte(input), &op); err != nil {
+        return "", err
+    }
+    if op.Delete {
+        return "deleted", os.Remove(op.Path)
+    }
+    return "written", os.WriteFile(op.Path, []byte(op.Content), 0644)
+}
+
+func (fileOpTool) MutatedFiles(input string) []string {
+    var op fileOp
+    json.Unmarshal([]byte(input), &op)
+    return []string{op.Path}
+}
+
+func (fileOpTool) GetName() string {
+    return "file_op"
+}
+
+func (fileOpTool) GetDescription() string {
+    return "Writes or deletes a file"
+}
+
+func (fileOpTool) GetInputSchema() map[string]interface{} {
+    return map[string]interface{}{"type": "object"}
+}
+
+func (fileOpTool) IsReadOnly() bool {
+    return false
+}
+
+var _ tools.FileMutator = fileOpTool{}
+
+// wantFile fails the test unless path holds content, or doesn't exist when content is nil
+func wantFile(t *testing.T, path string, content *string) {
+    t.Helper()
+    data, err := os.ReadFile(path)
+    switch {
+    case content == nil && !os.IsNotExist(err):
+        t.Errorf("%s exists (%v), want it removed", filepath.Base(path), err)
+    case content != nil && err != nil:
+        t.Errorf("reading %s: %v", filepath.Base(path), err)
+    case content != nil && string(data) != *content:
+        t.Errorf("%s = %q, want %q", filepath.Base(path), data, *content)
+    }
+}
+
+func ptr(s string) *string {
+    return &s
+}
+
+func TestCheckpointUndoAndRestore(t *testing.T) {
+    dir := t.TempDir()
+    kept, created, deleted := filepath.Join(dir, "kept.txt"), filepath.Join(dir, "created.txt"), filepath.Join(dir, "deleted.txt")
+    if err := os.WriteFile(kept, []byte("original\n"), 0600); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(deleted, []byte("delete me\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    provider := llmtest.NewProvider(
+        llmtest.Reply("",
+            llmtest.Call("file_op", fileOp{Path: kept, Content: "changed\n"}),
+            llmtest.Call("file_op", fileOp{Path: created, Content: "new\n"}),
+            llmtest.Call("file_op", fileOp{Path: deleted, Delete: true}),
+        ),
+        // A second change to the same file within the turn keeps the first snapshot
+        llmtest.Reply("", llmtest.Call("file_op", fileOp{Path: kept, Content: "changed again\n"})),
+        llmtest.Reply("edited"),
+        llmtest.Reply("nothing to change"),
+        llmtest.Reply("", llmtest.Call("file_op", fileOp{Path: kept, Content: "third\n"})),
+        llmtest.Reply("edited again"),
+    )
+    ag := newTestAgent(t, provider, WithTools(fileOpTool{}))
+    for _, prompt := range []string{"edit the files", "just chat", "edit once more"} {
+        if _, err := ag.Process(prompt); err != nil {
+            t.Fatalf("Process(%q): %v", prompt, err)
+        }
+    }
+
+    checkpoints := ag.Checkpoints()
+    if len(checkpoints) != 2 {
+        t.Fatalf("%d checkpoints, want 2: %+v", len(checkpoints), checkpoints)
+    }
+    first := checkpoints[0]
+    if first.ID != 1 || first.Turn != 1 || first.Prompt != "edit the files" || first.ContextLen != 1 || len(first.Files) != 3 {
+        t.Fatalf("first checkpoint = %+v", first)
+    }
+    wantSnapshots := []FileSnapshot{
+        {Path: kept, Existed: true, Content: []byte("original\n"), Mode: 0600},
+        {Path: created},
+        {Path: deleted, Existed: true, Content: []byte("delete me\n"), Mode: 0644},
+    }
+    for i, want := range wantSnapshots {
+        got := first.Files[i]
+        if got.Path != want.Path || got.Existed != want.Existed || string(got.Content) != string(want.Content) || got.Mode != want.Mode {
+            t.Errorf("snapshot %d = %+v, want %+v", i, got, want)
+        }
+    }
+    if second := checkpoints[1]; second.ID != 2 || second.Turn != 3 || len(second.Files) != 1 {
+        t.Errorf("second checkpoint = %+v", second)
+    }
+
+    // Undo reverts only the last turn that changed files
+    undone, err := ag.Undo()
+    if err != nil || undone.ID != 2 {
+        t.Fatalf("Undo = %+v, %v", undone, err)
+    }
+    wantFile(t, kept, ptr("changed again\n"))
+    wantFile(t, created, ptr("new\n"))
+    wantFile(t, deleted, nil)
+
+    historyLen := len(ag.History())
+    restored, err := ag.Restore(1, false)
+    if err != nil || restored.ID != 1 {
+        t.Fatalf("Restore = %+v, %v", restored, err)
+    }
+    wantFile(t, kept, ptr("original\n"))
+    wantFile(t, created, nil)
+    wantFile(t, deleted, ptr("delete me\n"))
+    if info, err := os.Stat(kept); err != nil || info.Mode().Perm() != 0600 {
+        t.Errorf("restored mode = %v, %v; want 0600", info.Mode().Perm(), err)
+    }
+    if len(ag.History()) != historyLen {
+        t.Errorf("restoring files alone changed the conversation from %d to %d messages", historyLen, len(ag.History()))
+    }
+
+    if checkpoints := ag.Checkpoints(); len(checkpoints) != 0 {
+        t.Errorf("checkpoints left after restoring the first: %+v", checkpoints)
+    }
+    if _, err := ag.Undo(); err == nil || err.Error() != "nothing to undo" {
+        t.Errorf("Undo with no checkpoints: %v", err)
+    }
+    if _, err := ag.Restore(2, false); err == nil || !strings.Contains(err.Error(), "checkpoint 2 not found") {
+        t.Errorf("restoring a removed checkpoint: %v", err)
+    }
+}
+
+func TestCheckpointRestoreRewindsConversation(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "notes.txt")
+    provider := llmtest.NewProvider(
+        llmtest.Reply("hello"),
+        llmtest.Reply("", llmtest.Call("file_op", fileOp{Path: path, Content: "draft\n"})),
+        llmtest.Reply("", llmtest.Call("undo", "{}")),
+        llmtest.Reply("written"),
+    )
+    // Undoing from inside a turn is refused
+    var ag *Agent
+    var undoErr error
+    undo := &fakeTool{name: "undo", run: func(string) (string, error) {
+        _, undoErr = ag.Undo()
+        return "tried", nil
+    }}
+    ag = newTestAgent(t, provider, WithTools(fileOpTool{}, undo))
+
+    for _, prompt := range []string{"hi", "write notes"} {
+        if _, err := ag.Process(prompt); err != nil {
+            t.Fatalf("Process(%q): %v", prompt, err)
+        }
+    }
+    if !errors.Is(undoErr, ErrBusy) {
+        t.Errorf("Undo during a turn returned %v, want ErrBusy", undoErr)
+    }
+
+    checkpoints := ag.Checkpoints()
+    if len(checkpoints) != 1 || checkpoints[0].ContextLen != 3 {
+        t.Fatalf("checkpoints = %+v", checkpoints)
+    }
+    if _, err := ag.Restore(checkpoints[0].ID, true); err != nil {
+        t.Fatalf("Restore: %v", err)
+    }
+    wantFile(t, path, nil)
+    history := ag.History()
+    if len(history) != 3 || history[1].Content != "hi" || history[2].Content != "hello" {
+        t.Errorf("history after rewinding = %+v", history)
+    }
+}
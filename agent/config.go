@@ -0,0 +1,192 @@
+package agent
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+
+    "jkneen.ai-agent/tools"
+)
+
+// ProjectConfigFile is the name of the per-project configuration file
+const ProjectConfigFile = "ai-agent.json"
+
+// Config holds user-tunable agent settings. The user-global config lives in
+// ~/.config/ai-agent/config.json and is overridden field by field by the
+// project's ai-agent.json. Settings that launch programs or send credentials
+// are only read from the user config, so that starting the agent in a cloned
+// repository cannot run code from it or leak keys.
+type Config struct {
+    // SystemPrompt replaces the default system prompt template
+    SystemPrompt string `json:"system_prompt,omitempty"`
+    // SystemPromptFile names a file holding the system prompt template; relative
+    // paths are resolved against the config file's directory
+    SystemPromptFile string `json:"system_prompt_file,omitempty"`
+    // AppendSystemPrompt is a template appended to the rendered system prompt
+    AppendSystemPrompt string `json:"append_system_prompt,omitempty"`
+    // LSPCommand is the language server to launch, e.g. ["gopls"]. The lsp
+    // tool and post-edit diagnostics are disabled when it is empty. User
+    // config only.
+    LSPCommand []string `json:"lsp_command,omitempty"`
+    // LSPLanguages lists the language IDs the server checks, e.g. ["go"], so
+    // edits to other files don't wait for diagnostics. Guessed for well-known
+    // servers when empty. User config only.
+    LSPLanguages []string `json:"lsp_languages,omitempty"`
+    // SearchProvider selects the web_search backend: searxng, brave or mock.
+    // SearchURL and SearchAPIKey configure it. Each falls back to the
+    // SEARCH_PROVIDER, SEARCH_URL and SEARCH_API_KEY environment variables.
+    // User config only, since the key is sent to the URL.
+    SearchProvider string `json:"search_provider,omitempty"`
+    SearchURL      string `json:"search_url,omitempty"`
+    SearchAPIKey   string `json:"search_api_key,omitempty"`
+    // FetchAllowDomains, if set, limits web_fetch to these domains and their
+    // subdomains; FetchDenyDomains are always refused. A project can narrow
+    // but not widen the user's lists.
+    FetchAllowDomains []string `json:"fetch_allow_domains,omitempty"`
+    FetchDenyDomains  []string `json:"fetch_deny_domains,omitempty"`
+    // PluginDirs lists extra directories of plugin executables, loaded after
+    // ~/.config/ai-agent/plugins; relative paths are resolved against the
+    // config file's directory. User config only.
+    PluginDirs []string `json:"plugin_dirs,omitempty"`
+    // Hooks maps event names such as PreToolUse to shell commands run on
+    // that event. User config only.
+    Hooks map[string][]HookConfig `json:"hooks,omitempty"`
+
+    warnings []string // Project settings that were ignored
+}
+
+// LoadConfig reads the user-global config and the project config for dir
+func LoadConfig(dir string) (*Config, error) {
+    config := &Config{}
+
+    if configDir, err := userConfigDir(); err == nil {
+        if err := config.mergeFile(filepath.Join(configDir, "config.json"), false); err != nil {
+            return nil, err
+        }
+    }
+    if err := config.mergeFile(filepath.Join(dir, ProjectConfigFile), true); err != nil {
+        return nil, err
+    }
+
+    // Keep secrets such as search API keys out of config files if preferred
+    for field, name := range map[*string]string{
+        &config.SearchProvider: "SEARCH_PROVIDER",
+        &config.SearchURL:      "SEARCH_URL",
+        &config.SearchAPIKey:   "SEARCH_API_KEY",
+    } {
+        if *field == "" {
+            *field = os.Getenv(name)
+        }
+    }
+    return config, nil
+}
+
+// mergeFile overlays the non-empty settings of a config file onto c.
+// A missing file is not an error. Settings that launch programs are ignored
+// in project files, with a warning.
+func (c *Config) mergeFile(path string, project bool) error {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        if os.IsNotExist(err) {
+            return nil
+        }
+        return fmt.Errorf("failed to read config %s: %w", path, err)
+    }
+
+    var overlay Config
+    if err := json.Unmarshal(data, &overlay); err != nil {
+        return fmt.Errorf("invalid config %s: %w", path, err)
+    }
+
+    if project {
+        var ignored []string
+        if len(overlay.LSPCommand) > 0 {
+            ignored = append(ignored, "lsp_command")
+            overlay.LSPCommand = nil
+        }
+        if len(overlay.LSPLanguages) > 0 {
+            ignored = append(ignored, "lsp_languages")
+            overlay.LSPLanguages = nil
+        }
+        if len(overlay.PluginDirs) > 0 {
+            ignored = append(ignored, "plugin_dirs")
+            overlay.PluginDirs = nil
+        }
+        if len(overlay.Hooks) > 0 {
+            ignored = append(ignored, "hooks")
+            overlay.Hooks = nil
+        }
+        for name, field := range map[string]*string{
+            "search_provider": &overlay.SearchProvider,
+            "search_url":      &overlay.SearchURL,
+            "search_api_key":  &overlay.SearchAPIKey,
+        } {
+            if *field != "" {
+                ignored = append(ignored, name)
+                *field = ""
+            }
+        }
+        if len(ignored) > 0 {
+            sort.Strings(ignored)
+            c.warnings = append(c.warnings, fmt.Sprintf("%s: ignoring %s; settings that run programs or send credentials are only read from the user config",
+                path, strings.Join(ignored, ", ")))
+        }
+    }
+
+    if overlay.SystemPromptFile != "" && !filepath.IsAbs(overlay.SystemPromptFile) {
+        overlay.SystemPromptFile = filepath.Join(filepath.Dir(path), overlay.SystemPromptFile)
+    }
+
+    for i, dir := range overlay.PluginDirs {
+        if !filepath.IsAbs(dir) {
+            overlay.PluginDirs[i] = filepath.Join(filepath.Dir(path), dir)
+        }
+    }
+    c.PluginDirs = append(c.PluginDirs, overlay.PluginDirs...)
+
+    if len(overlay.Hooks) > 0 {
+        c.Hooks = overlay.Hooks
+    }
+
+    if overlay.SystemPrompt != "" || overlay.SystemPromptFile != "" {
+        // A more specific override replaces both forms of the less specific one
+        c.SystemPrompt = overlay.SystemPrompt
+        c.SystemPromptFile = overlay.SystemPromptFile
+    }
+    if overlay.AppendSystemPrompt != "" {
+        c.AppendSystemPrompt = overlay.AppendSystemPrompt
+    }
+    if len(overlay.LSPCommand) > 0 {
+        c.LSPCommand = overlay.LSPCommand
+    }
+    if len(overlay.LSPLanguages) > 0 {
+        c.LSPLanguages = overlay.LSPLanguages
+    }
+    if len(overlay.FetchAllowDomains) > 0 {
+        if c.FetchAllowDomains != nil {
+            // Only domains both lists allow remain allowed
+            c.FetchAllowDomains = tools.IntersectDomains(c.FetchAllowDomains, overlay.FetchAllowDomains)
+        } else {
+            c.FetchAllowDomains = overlay.FetchAllowDomains
+        }
+    }
+    // Deny lists accumulate so a project cannot lift a user-wide restriction
+    c.FetchDenyDomains = append(c.FetchDenyDomains, overlay.FetchDenyDomains...)
+    if overlay.SearchProvider != "" {
+        // The URL and key belong to the provider they were configured with
+        c.SearchProvider = overlay.SearchProvider
+        c.SearchURL = overlay.SearchURL
+        c.SearchAPIKey = overlay.SearchAPIKey
+    } else {
+        if overlay.SearchURL != "" {
+            c.SearchURL = overlay.SearchURL
+        }
+        if overlay.SearchAPIKey != "" {
+            c.SearchAPIKey = overlay.SearchAPIKey
+        }
+    }
+    return nil
+}
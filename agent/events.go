@@ -0,0 +1,126 @@
+package agent
+
+import (
+    "sync"
+    "time"
+
+    "jkneen.ai-agent/llm"
+)
+
+// Event is something that happened while the agent worked. Subscribers
+// receive one of the *Event types below and switch on the concrete type.
+type Event interface {
+    event()
+}
+
+// TurnStartedEvent is sent when Process begins a turn
+type TurnStartedEvent struct {
+    Prompt string
+}
+
+// ModelDeltaEvent carries text produced by the model. The client does not
+// stream yet, so each model response arrives as a single delta.
+type ModelDeltaEvent struct {
+    Text string
+}
+
+// ToolCallEvent is sent when the model requests a tool call, before any
+// hooks or permission checks run
+type ToolCallEvent struct {
+    ID    string // The tool call ID assigned by the model
+    Name  string
+    Input string
+}
+
+// ToolResultEvent is sent when a tool call finishes
+type ToolResultEvent struct {
+    ID       string
+    Name     string
+    Output   string // The result, or the error message if IsError is set
+    IsError  bool
+    Duration time.Duration
+}
+
+// TodosUpdatedEvent is sent when the model changes its task list
+type TodosUpdatedEvent struct {
+    Todos []TodoItem
+}
+
+// SubAgentEvent wraps an event from a sub-agent started by the task tool.
+// The sub-agent's turn events describe its task, not a turn of the parent.
+type SubAgentEvent struct {
+    Task  string // The task's description, if the model gave one
+    Event Event
+}
+
+// ErrorEvent is sent when a turn fails; Process returns the same error
+type ErrorEvent struct {
+    Err error
+}
+
+// TurnFinishedEvent is sent when a turn ends, successfully or not
+type TurnFinishedEvent struct {
+    Response string    // Empty if the turn failed
+    Usage    llm.Usage // Tokens used by all model requests in the turn, including sub-agents'
+    Duration time.Duration
+}
+
+func (TurnStartedEvent) event()  {}
+func (ModelDeltaEvent) event()   {}
+func (ToolCallEvent) event()     {}
+func (ToolResultEvent) event()   {}
+func (TodosUpdatedEvent) event() {}
+func (SubAgentEvent) event()     {}
+func (ErrorEvent) event()        {}
+func (TurnFinishedEvent) event() {}
+
+// subscriber is a registered event handler
+type subscriber struct {
+    id int
+    fn func(Event)
+}
+
+// eventBus delivers events to subscribers one at a time, in the order they
+// were published
+type eventBus struct {
+    mu          sync.Mutex // Guards subscribers and nextID
+    subscribers []subscriber
+    nextID      int
+
+    deliver sync.Mutex // Serializes delivery, as tool workers publish concurrently
+}
+
+// Subscribe registers fn to receive every event and returns a function that
+// removes it. Events are delivered synchronously on the agent's goroutines,
+// so fn should return quickly and must not start another turn.
+func (a *Agent) Subscribe(fn func(Event)) (unsubscribe func()) {
+    a.events.mu.Lock()
+    defer a.events.mu.Unlock()
+    id := a.events.nextID
+    a.events.nextID++
+    a.events.subscribers = append(a.events.subscribers, subscriber{id: id, fn: fn})
+
+    return func() {
+        a.events.mu.Lock()
+        defer a.events.mu.Unlock()
+        for i, s := range a.events.subscribers {
+            if s.id == id {
+                a.events.subscribers = append(a.events.subscribers[:i:i], a.events.subscribers[i+1:]...)
+                return
+            }
+        }
+    }
+}
+
+// emit publishes an event to the current subscribers in subscription order
+func (a *Agent) emit(event Event) {
+    a.events.mu.Lock()
+    subscribers := a.events.subscribers
+    a.events.mu.Unlock()
+
+    a.events.deliver.Lock()
+    defer a.events.deliver.Unlock()
+    for _, s := range subscribers {
+        s.fn(event)
+    }
+}
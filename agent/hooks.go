@@ -0,0 +1,374 @@
+package agent
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "os"
+    "os/exec"
+    "regexp"
+    "runtime"
+    "strings"
+    "sync"
+    "time"
+
+    "jkneen.ai-agent/tools"
+)
+
+// Hook events
+const (
+    HookSessionStart     = "SessionStart"     // Before the first prompt of a session is processed
+    HookUserPromptSubmit = "UserPromptSubmit" // Before a prompt is sent; may block or rewrite it
+    HookPreToolUse       = "PreToolUse"       // Before a tool runs; may block it or rewrite its input
+    HookPostToolUse      = "PostToolUse"      // After a tool runs; may add a message to its result
+    HookStop             = "Stop"             // When a turn finishes
+)
+
+// hookEvents lists the valid event names
+var hookEvents = []string{HookSessionStart, HookUserPromptSubmit, HookPreToolUse, HookPostToolUse, HookStop}
+
+// DefaultHookTimeout bounds a shell hook that doesn't set its own timeout
+const DefaultHookTimeout = 60 * time.Second
+
+// hookBlockExitCode is the exit status with which a shell hook blocks the action
+const hookBlockExitCode = 2
+
+// HookEvent describes what triggered a hook. Shell hooks receive it as JSON on stdin.
+type HookEvent struct {
+    Event      string          `json:"event"`
+    WorkingDir string          `json:"cwd"`
+    Prompt     string          `json:"prompt,omitempty"`      // UserPromptSubmit
+    ToolName   string          `json:"tool_name,omitempty"`   // PreToolUse, PostToolUse
+    ToolInput  json.RawMessage `json:"tool_input,omitempty"`  // The input object, or a JSON string for plain-text input
+    Files      []string        `json:"files,omitempty"`       // Files the tool call creates, modifies or deletes
+    ToolOutput string          `json:"tool_output,omitempty"` // PostToolUse
+    ToolError  string          `json:"tool_error,omitempty"`  // PostToolUse
+    Response   string          `json:"response,omitempty"`    // Stop
+}
+
+// HookResult is a hook's decision. Shell hooks may print it as JSON on stdout.
+type HookResult struct {
+    Block     bool            `json:"block,omitempty"`      // Stop the prompt or tool call
+    Reason    string          `json:"reason,omitempty"`     // Why it was blocked, shown to the model or user
+    Prompt    string          `json:"prompt,omitempty"`     // Replacement prompt (UserPromptSubmit)
+    ToolInput json.RawMessage `json:"tool_input,omitempty"` // Replacement tool input (PreToolUse)
+    Message   string          `json:"message,omitempty"`    // Added to the prompt or tool result
+}
+
+// HookFunc handles a hook event. A nil result means carry on unchanged.
+type HookFunc func(event HookEvent) (*HookResult, error)
+
+// HookConfig is a shell command run on an agent event
+type HookConfig struct {
+    Matcher string `json:"matcher,omitempty"` // Regular expression matched against the tool name, for tool events
+    Command string `json:"command"`
+    Timeout string `json:"timeout,omitempty"` // e.g. "10s"; defaults to DefaultHookTimeout
+}
+
+// hook is a registered hook handler
+type hook struct {
+    name    string // Shown in error messages
+    matcher *regexp.Regexp
+    fn      HookFunc
+}
+
+// hookSet holds the agent's hooks
+type hookSet struct {
+    mu           sync.Mutex
+    hooks        map[string][]hook
+    sessionStart sync.Once
+}
+
+// AddHook registers fn for event. For tool events matcher is a regular
+// expression matched against the tool name; an empty matcher matches all tools.
+func (a *Agent) AddHook(event, matcher string, fn HookFunc) error {
+    return a.addHook(event, matcher, "callback", fn)
+}
+
+func (a *Agent) addHook(event, matcher, name string, fn HookFunc) error {
+    if !isHookEvent(event) {
+        return fmt.Errorf("unknown hook event %q: use one of %s", event, strings.Join(hookEvents, ", "))
+    }
+    var compiled *regexp.Regexp
+    if matcher != "" {
+        var err error
+        if compiled, err = regexp.Compile("^(?:" + matcher + ")$"); err != nil {
+            return fmt.Errorf("invalid hook matcher %q: %w", matcher, err)
+        }
+    }
+
+    a.hooks.mu.Lock()
+    defer a.hooks.mu.Unlock()
+    if a.hooks.hooks == nil {
+        a.hooks.hooks = make(map[string][]hook)
+    }
+    a.hooks.hooks[event] = append(a.hooks.hooks[event], hook{name: name, matcher: compiled, fn: fn})
+    return nil
+}
+
+// toolHooks copies the PreToolUse and PostToolUse hooks, which sub-agents
+// share so that hooks guarding tools also see the sub-agents' calls
+func (s *hookSet) toolHooks() map[string][]hook {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    copied := make(map[string][]hook)
+    for _, event := range []string{HookPreToolUse, HookPostToolUse} {
+        copied[event] = append([]hook(nil), s.hooks[event]...)
+    }
+    return copied
+}
+
+// matches reports whether any hook is registered for event and tool name
+func (s *hookSet) matches(event, toolName string) bool {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    for _, h := range s.hooks[event] {
+        if h.matcher == nil || h.matcher.MatchString(toolName) {
+            return true
+        }
+    }
+    return false
+}
+
+// addConfiguredHooks registers the shell hooks from the config
+func (a *Agent) addConfiguredHooks(config map[string][]HookConfig, workingDir string) error {
+    for event := range config {
+        if !isHookEvent(event) {
+            return fmt.Errorf("unknown hook event %q: use one of %s", event, strings.Join(hookEvents, ", "))
+        }
+    }
+    // Register in a fixed order so hooks for different events fail predictably
+    for _, event := range hookEvents {
+        for _, hookConfig := range config[event] {
+            fn, err := commandHook(hookConfig, workingDir)
+            if err != nil {
+                return err
+            }
+            if err := a.addHook(event, hookConfig.Matcher, hookConfig.Command, fn); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+// isHookEvent reports whether event is a known hook event name
+func isHookEvent(event string) bool {
+    for _, known := range hookEvents {
+        if event == known {
+            return true
+        }
+    }
+    return false
+}
+
+// runHooks calls the hooks for an event in order. Changes made by one hook
+// are visible to the next; the first hook to block ends the chain. Failing
+// hooks don't block and are reported in the returned error.
+func (a *Agent) runHooks(event HookEvent) (HookResult, error) {
+    a.hooks.mu.Lock()
+    hooks := append([]hook(nil), a.hooks.hooks[event.Event]...)
+    a.hooks.mu.Unlock()
+
+    var combined HookResult
+    var messages, failures []string
+    for _, h := range hooks {
+        if h.matcher != nil && !h.matcher.MatchString(event.ToolName) {
+            continue
+        }
+        result, err := h.fn(event)
+        if err != nil {
+            failures = append(failures, fmt.Sprintf("%s hook %q failed: %v", event.Event, h.name, err))
+            continue
+        }
+        if result == nil {
+            continue
+        }
+        if result.Message != "" {
+            messages = append(messages, result.Message)
+        }
+        if result.Prompt != "" {
+            event.Prompt = result.Prompt
+            combined.Prompt = result.Prompt
+        }
+        if len(result.ToolInput) > 0 {
+            event.ToolInput = result.ToolInput
+            combined.ToolInput = result.ToolInput
+        }
+        if result.Block {
+            combined.Block = true
+            combined.Reason = result.Reason
+            if combined.Reason == "" {
+                combined.Reason = fmt.Sprintf("blocked by %s hook %q", event.Event, h.name)
+            }
+            break
+        }
+    }
+    combined.Message = strings.Join(messages, "\n")
+
+    if len(failures) > 0 {
+        return combined, fmt.Errorf("%s", strings.Join(failures, "; "))
+    }
+    return combined, nil
+}
+
+// commandHook adapts a shell command to a HookFunc. The command receives the
+// event as JSON on stdin. Exit status 2 blocks the action with stderr as the
+// reason; other failures are reported without blocking. On success, stdout
+// holding a JSON object is read as a HookResult, and any other output is
+// used as the result message.
+func commandHook(config HookConfig, workingDir string) (HookFunc, error) {
+    if strings.TrimSpace(config.Command) == "" {
+        return nil, fmt.Errorf("hook command is empty")
+    }
+    timeout := DefaultHookTimeout
+    if config.Timeout != "" {
+        parsed, err := time.ParseDuration(config.Timeout)
+        if err != nil || parsed <= 0 {
+            return nil, fmt.Errorf("invalid timeout %q for hook %q", config.Timeout, config.Command)
+        }
+        timeout = parsed
+    }
+
+    return func(event HookEvent) (*HookResult, error) {
+        payload, err := json.Marshal(event)
+        if err != nil {
+            return nil, err
+        }
+        ctx, cancel := context.WithTimeout(context.Background(), timeout)
+        defer cancel()
+
+        var cmd *exec.Cmd
+        if runtime.GOOS == "windows" {
+            cmd = exec.CommandContext(ctx, "cmd", "/C", config.Command)
+        } else {
+            cmd = exec.CommandContext(ctx, "sh", "-c", config.Command)
+        }
+        cmd.Dir = workingDir
+        cmd.Env = append(os.Environ(), "AGENT_HOOK_EVENT="+event.Event, "AGENT_TOOL_NAME="+event.ToolName)
+        cmd.Stdin = bytes.NewReader(payload)
+        cmd.WaitDelay = 2 * time.Second
+        var stdout, stderr bytes.Buffer
+        cmd.Stdout = &stdout
+        cmd.Stderr = &stderr
+
+        runErr := cmd.Run()
+        if ctx.Err() == context.DeadlineExceeded {
+            return nil, fmt.Errorf("timed out after %s", timeout)
+        }
+        if exitErr, ok := runErr.(*exec.ExitError); ok && exitErr.ExitCode() == hookBlockExitCode {
+            reason := strings.TrimSpace(stderr.String())
+            if reason == "" {
+                reason = strings.TrimSpace(stdout.String())
+            }
+            return &HookResult{Block: true, Reason: reason}, nil
+        }
+        if runErr != nil {
+            if message := strings.TrimSpace(stderr.String()); message != "" {
+                return nil, fmt.Errorf("%w: %s", runErr, message)
+            }
+            return nil, runErr
+        }
+
+        output := strings.TrimSpace(stdout.String())
+        if strings.HasPrefix(output, "{") {
+            var result HookResult
+            if err := json.Unmarshal([]byte(output), &result); err != nil {
+                return nil, fmt.Errorf("invalid JSON output: %w", err)
+            }
+            return &result, nil
+        }
+        return &HookResult{Message: output}, nil
+    }, nil
+}
+
+// toolInputJSON returns tool input as JSON for hooks: objects as they are,
+// anything else as a JSON string
+func toolInputJSON(input string) json.RawMessage {
+    trimmed := strings.TrimSpace(input)
+    if strings.HasPrefix(trimmed, "{") && json.Valid([]byte(trimmed)) {
+        return json.RawMessage(trimmed)
+    }
+    encoded, _ := json.Marshal(input)
+    return encoded
+}
+
+// toolInputString reverses toolInputJSON for input rewritten by a hook
+func toolInputString(input json.RawMessage) string {
+    var text string
+    if json.Unmarshal(input, &text) == nil {
+        return text
+    }
+    return string(input)
+}
+
+// runTool runs a tool call through the PreToolUse hooks, plan mode and
+// permission checks, checkpointing and the PostToolUse hooks
+func (a *Agent) runTool(tool tools.Tool, input string) (string, error) {
+    event := HookEvent{
+        Event:      HookPreToolUse,
+        WorkingDir: a.promptData.WorkingDir,
+        ToolName:   tool.GetName(),
+        ToolInput:  toolInputJSON(input),
+    }
+    if mutator, ok := tool.(tools.FileMutator); ok {
+        event.Files = mutator.MutatedFiles(input)
+    }
+    pre, hookErr := a.runHooks(event)
+    if pre.Block {
+        return "", fmt.Errorf("%s was blocked by a hook: %s", tool.GetName(), pre.Reason)
+    }
+    if len(pre.ToolInput) > 0 {
+        input = toolInputString(pre.ToolInput)
+        event.ToolInput = pre.ToolInput
+        if mutator, ok := tool.(tools.FileMutator); ok {
+            event.Files = mutator.MutatedFiles(input)
+        }
+    }
+
+    if err := a.checkPermission(tool, input); err != nil {
+        return "", err
+    }
+    if err := a.snapshotFor(tool, input); err != nil {
+        return "", fmt.Errorf("failed to checkpoint files: %w", err)
+    }
+    output, err := tool.Execute(input)
+
+    event.Event = HookPostToolUse
+    event.ToolOutput = output
+    if err != nil {
+        event.ToolError = err.Error()
+    }
+    post, postErr := a.runHooks(event)
+    // Hooks such as formatters may rewrite the files the tool just wrote
+    if tracking, ok := tool.(tools.TrackingTool); ok && err == nil && a.hooks.matches(HookPostToolUse, event.ToolName) {
+        for _, path := range event.Files {
+            tracking.FileTracker().Refresh(path)
+        }
+    }
+
+    // Hook messages and failures are passed on to the model with the result
+    var notes []string
+    for _, note := range []string{pre.Message, post.Message} {
+        if note != "" {
+            notes = append(notes, note)
+        }
+    }
+    for _, failure := range []error{hookErr, postErr} {
+        if failure != nil {
+            notes = append(notes, failure.Error())
+        }
+    }
+    if post.Block {
+        notes = append(notes, "Hook feedback: "+post.Reason)
+    }
+    if len(notes) > 0 {
+        suffix := "\n\n" + strings.Join(notes, "\n")
+        if err != nil {
+            return "", fmt.Errorf("%w%s", err, suffix)
+        }
+        output += suffix
+    }
+    return output, err
+}
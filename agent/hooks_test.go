@@ -0,0 +1,181 @@
+package agent
+
+import (
+    "encoding/json"
+    "os"
+    "path/filepath"
+    "runtime"
+    "strings"
+    "testing"
+
+    "jkneen.ai-agent/llm/llmtest"
+    "jkneen.ai-agent/tools"
+)
+
+func TestCommandHook(t *testing.T) {
+    if runtime.GOOS == "windows" {
+        t.Skip("the hook commands are POSIX shell")
+    }
+    tests := []struct {
+        name    string
+        config  HookConfig
+        want    HookResult
+        wantErr string
+    }{
+        {
+            name:   "plain output is the message",
+            config: HookConfig{Command: `echo "$AGENT_HOOK_EVENT for $AGENT_TOOL_NAME"`},
+            want:   HookResult{Message: "PreToolUse for file_edit"},
+        },
+        {
+            name:   "JSON output is the result",
+            config: HookConfig{Command: `echo '{"message": "rewritten", "tool_input": {"file_path": "b.go"}}'`},
+            want:   HookResult{Message: "rewritten", ToolInput: json.RawMessage(`{"file_path": "b.go"}`)},
+        },
+        {
+            name:   "exit status 2 blocks with stderr as the reason",
+            config: HookConfig{Command: `echo "generated files are read-only" >&2; exit 2`},
+            want:   HookResult{Block: true, Reason: "generated files are read-only"},
+        },
+        {
+            name:   "exit status 2 falls back to stdout",
+            config: HookConfig{Command: `echo "not today"; exit 2`},
+            want:   HookResult{Block: true, Reason: "not today"},
+        },
+        {
+            name:    "other failures don't block",
+            config:  HookConfig{Command: `echo "formatter missing" >&2; exit 1`},
+            wantErr: "exit status 1: formatter missing",
+        },
+        {
+            name:    "invalid JSON",
+            config:  HookConfig{Command: `echo '{"block": tru}'`},
+            wantErr: "invalid JSON output",
+        },
+        {
+            name:    "timeout",
+            config:  HookConfig{Command: `exec sleep 5`, Timeout: "100ms"},
+            wantErr: "timed out after 100ms",
+        },
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            fn, err := commandHook(test.config, t.TempDir())
+            if err != nil {
+                t.Fatalf("commandHook: %v", err)
+            }
+            result, err := fn(HookEvent{Event: HookPreToolUse, ToolName: "file_edit"})
+            if test.wantErr != "" {
+                if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+                    t.Fatalf("hook error = %v, want %q", err, test.wantErr)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("hook: %v", err)
+            }
+            if result.Block != test.want.Block || result.Reason != test.want.Reason || result.Message != test.want.Message || string(result.ToolInput) != string(test.want.ToolInput) {
+                t.Errorf("result = %+v, want %+v", *result, test.want)
+            }
+        })
+    }
+}
+
+func TestCommandHookReceivesEvent(t *testing.T) {
+    if runtime.GOOS == "windows" {
+        t.Skip("the hook commands are POSIX shell")
+    }
+    dir := t.TempDir()
+    fn, err := commandHook(HookConfig{Command: "cat > event.json"}, dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    sent := HookEvent{
+        Event:      HookPostToolUse,
+        WorkingDir: dir,
+        ToolName:   "file_edit",
+        ToolInput:  json.RawMessage(`{"file_path":"main.go"}`),
+        Files:      []string{"main.go"},
+        ToolOutput: "Edited main.go",
+    }
+    if _, err := fn(sent); err != nil {
+        t.Fatalf("hook: %v", err)
+    }
+
+    // The command runs in the working directory with the event on stdin
+    data, err := os.ReadFile(filepath.Join(dir, "event.json"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    var received HookEvent
+    if err := json.Unmarshal(data, &received); err != nil {
+        t.Fatalf("stdin is not a JSON event: %v\n%s", err, data)
+    }
+    if received.Event != sent.Event || received.ToolName != sent.ToolName || string(received.ToolInput) != string(sent.ToolInput) ||
+        len(received.Files) != 1 || received.Files[0] != "main.go" || received.ToolOutput != sent.ToolOutput {
+        t.Errorf("hook received %+v, want %+v", received, sent)
+    }
+    if !strings.Contains(string(data), `"cwd":`) || !strings.Contains(string(data), `"tool_name":"file_edit"`) {
+        t.Errorf("payload uses unexpected field names: %s", data)
+    }
+}
+
+func TestCommandHookConfigErrors(t *testing.T) {
+    for _, config := range []HookConfig{
+        {Command: "  "},
+        {Command: "gofmt -w .", Timeout: "soon"},
+        {Command: "gofmt -w .", Timeout: "-1s"},
+    } {
+        if _, err := commandHook(config, "."); err == nil {
+            t.Errorf("commandHook(%+v) succeeded", config)
+        }
+    }
+}
+
+// A formatter run by a PostToolUse hook changes the file after the edit
+// recorded it; the next edit must not be refused as a conflicting change
+func TestPostToolUseHookRefreshesFileTracker(t *testing.T) {
+    if runtime.GOOS == "windows" {
+        t.Skip("the hook commands are POSIX shell")
+    }
+    dir := t.TempDir()
+    path := filepath.Join(dir, "main.txt")
+    edit := func(operation, content string) interface{} {
+        return map[string]string{"file_path": path, "operation": operation, "content": content}
+    }
+    provider := llmtest.NewProvider(
+        llmtest.Reply("", llmtest.Call("file_edit", edit("replace", "x   =   1\n"))),
+        llmtest.Reply("", llmtest.Call("file_edit", edit("append", "y = 2\n"))),
+        llmtest.Reply("done"),
+    )
+    tracker := tools.NewFileTracker()
+    ag := newTestAgent(t, provider, WithTools(&tools.FileEditTool{Tracker: tracker}))
+    err := ag.addConfiguredHooks(map[string][]HookConfig{
+        HookPostToolUse: {{Matcher: "file_edit", Command: `sed 's/  */ /g' main.txt > main.tmp && mv main.tmp main.txt`}},
+    }, dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if _, err := ag.Process("write the file"); err != nil {
+        t.Fatalf("Process: %v", err)
+    }
+    for _, result := range sentToolResults(provider.Requests()) {
+        if result.IsError {
+            t.Errorf("tool call %s failed: %s", result.ToolCallID, result.Content)
+        }
+    }
+    data, _ := os.ReadFile(path)
+    if string(data) != "x = 1\ny = 2\n" {
+        t.Errorf("file = %q, want the formatted line and the appended one", data)
+    }
+
+    // Changes made outside the agent are still caught
+    if err := os.WriteFile(path, []byte("changed elsewhere\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    info, _ := os.Stat(path)
+    if err := tracker.Check(path, info, []byte("changed elsewhere\n")); err == nil {
+        t.Error("the tracker accepted an outside change")
+    }
+}
@@ -0,0 +1,167 @@
+package agent
+
+import (
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// InstructionFileNames lists the file names searched for project instructions,
+// in order of preference within a single directory
+var InstructionFileNames = []string{"AGENTS.md", "CLAUDE.md"}
+
+// maxIncludeDepth bounds how deeply @path includes may nest
+const maxIncludeDepth = 5
+
+// InstructionFile is a single instruction file merged into the system prompt
+type InstructionFile struct {
+    Path    string
+    Content string
+}
+
+// userConfigDir returns the directory holding user-global agent configuration
+func userConfigDir() (string, error) {
+    dir, err := os.UserConfigDir()
+    if err != nil {
+        return "", err
+    }
+    return filepath.Join(dir, "ai-agent"), nil
+}
+
+// LoadInstructions discovers instruction files for the given working directory.
+// The user-global file comes first, followed by files found in the directory's
+// ancestors from the filesystem root down to dir itself, so that more specific
+// instructions appear later and take precedence.
+func LoadInstructions(dir string) ([]InstructionFile, error) {
+    absDir, err := filepath.Abs(dir)
+    if err != nil {
+        return nil, fmt.Errorf("failed to resolve %s: %w", dir, err)
+    }
+
+    var paths []string
+    if configDir, err := userConfigDir(); err == nil {
+        if path := findInstructionFile(configDir); path != "" {
+            paths = append(paths, path)
+        }
+    }
+
+    // Collect from dir upwards, then reverse so the root comes first
+    var projectPaths []string
+    for current := absDir; ; {
+        if path := findInstructionFile(current); path != "" {
+            projectPaths = append(projectPaths, path)
+        }
+        parent := filepath.Dir(current)
+        if parent == current {
+            break
+        }
+        current = parent
+    }
+    for i := len(projectPaths) - 1; i >= 0; i-- {
+        paths = append(paths, projectPaths[i])
+    }
+
+    var files []InstructionFile
+    seen := make(map[string]bool)
+    for _, path := range paths {
+        if seen[path] {
+            continue
+        }
+        seen[path] = true
+        content, err := expandIncludes(path, 0, map[string]bool{})
+        if err != nil {
+            return nil, err
+        }
+        files = append(files, InstructionFile{Path: path, Content: content})
+    }
+    return files, nil
+}
+
+// findInstructionFile returns the first instruction file present in dir, or "" if none
+func findInstructionFile(dir string) string {
+    for _, name := range InstructionFileNames {
+        path := filepath.Join(dir, name)
+        if info, err := os.Stat(path); err == nil && !info.IsDir() {
+            return path
+        }
+    }
+    return ""
+}
+
+// expandIncludes reads an instruction file and replaces every line of the form
+// "@relative/or/absolute/path" with the contents of the referenced file
+func expandIncludes(path string, depth int, visiting map[string]bool) (string, error) {
+    if depth > maxIncludeDepth {
+        return "", fmt.Errorf("instruction includes nested too deeply at %s", path)
+    }
+    if visiting[path] {
+        return "", fmt.Errorf("instruction include cycle detected at %s", path)
+    }
+    visiting[path] = true
+    defer delete(visiting, path)
+
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return "", fmt.Errorf("failed to read instruction file %s: %w", path, err)
+    }
+
+    lines := strings.Split(string(data), "\n")
+    for i, line := range lines {
+        trimmed := strings.TrimSpace(line)
+        if !isIncludeLine(trimmed) {
+            continue
+        }
+        includePath := expandHome(trimmed[1:])
+        if !filepath.IsAbs(includePath) {
+            includePath = filepath.Join(filepath.Dir(path), includePath)
+        }
+        included, err := expandIncludes(filepath.Clean(includePath), depth+1, visiting)
+        if err != nil {
+            // Missing includes are reported inline rather than failing the whole prompt
+            if errors.Is(err, os.ErrNotExist) {
+                lines[i] = fmt.Sprintf("(include not found: %s)", trimmed[1:])
+                continue
+            }
+            return "", err
+        }
+        lines[i] = strings.TrimRight(included, "\n")
+    }
+    return strings.Join(lines, "\n"), nil
+}
+
+// isIncludeLine reports whether a trimmed line is an @path include. The path
+// must contain a "/" or "." so that lines like "@alice" or "@types" are kept.
+func isIncludeLine(line string) bool {
+    if !strings.HasPrefix(line, "@") || len(line) == 1 || strings.ContainsAny(line, " \t") {
+        return false
+    }
+    return strings.ContainsAny(line[1:], "/.")
+}
+
+// expandHome replaces a leading "~/" with the user's home directory
+func expandHome(path string) string {
+    if !strings.HasPrefix(path, "~/") {
+        return path
+    }
+    home, err := os.UserHomeDir()
+    if err != nil {
+        return path
+    }
+    return filepath.Join(home, path[2:])
+}
+
+// formatInstructions renders instruction files as a system prompt section
+func formatInstructions(files []InstructionFile) string {
+    if len(files) == 0 {
+        return ""
+    }
+    var sb strings.Builder
+    sb.WriteString("\n\nFollow these project instructions. Later files are more specific and take precedence over earlier ones.")
+    for _, file := range files {
+        sb.WriteString(fmt.Sprintf("\n\n# Instructions from %s\n\n", file.Path))
+        sb.WriteString(strings.TrimSpace(file.Content))
+    }
+    return sb.String()
+}
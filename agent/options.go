@@ -0,0 +1,220 @@
+package agent
+
+import (
+    "fmt"
+    "log/slog"
+    "os"
+
+    "jkneen.ai-agent/llm"
+    "jkneen.ai-agent/tools"
+)
+
+// Limits bounds how much work the agent does per turn. Zero fields keep
+// their defaults.
+type Limits struct {
+    MaxToolIterations int // Rounds of tool calls per turn; DefaultMaxToolIterations by default
+    MaxParallelTools  int // Read-only tool calls run at once; DefaultMaxParallelTools by default
+}
+
+// Option configures an agent created by New
+type Option func(*options)
+
+// options collects the settings passed to New
+type options struct {
+    provider     llm.Provider
+    newProvider  func() llm.Provider // Called if no provider is given
+    tools        []tools.Tool
+    agentTools   bool
+    store        Store
+    systemPrompt string
+    logger       *slog.Logger
+    permissions  PermissionPolicy
+    limits       Limits
+
+    // Set by NewAgent from the config files
+    config       *Config
+    workingDir   string
+    instructions []InstructionFile
+    memories     []tools.Memory
+}
+
+// WithProvider sets the model the agent talks to. It is required.
+func WithProvider(provider llm.Provider) Option {
+    return func(o *options) { o.provider = provider }
+}
+
+// WithTools registers tools for the model to call. New registers no tools
+// of its own apart from submit_plan, which is only offered in plan mode.
+func WithTools(toolList ...tools.Tool) Option {
+    return func(o *options) { o.tools = append(o.tools, toolList...) }
+}
+
+// WithAgentTools registers todo_write and task, which act on the agent
+// itself. Sub-agents started by task may use the read-only tools.
+func WithAgentTools() Option {
+    return func(o *options) { o.agentTools = true }
+}
+
+// WithStore loads the session from store and saves it there on SaveContext.
+// Without a store nothing is persisted.
+func WithStore(store Store) Option {
+    return func(o *options) { o.store = store }
+}
+
+// WithSystemPrompt replaces the default system prompt template. The template
+// is rendered with PromptData, so text without actions is used as it is.
+func WithSystemPrompt(prompt string) Option {
+    return func(o *options) { o.systemPrompt = prompt }
+}
+
+// WithLogger logs the agent's events, such as tool calls and token usage
+func WithLogger(logger *slog.Logger) Option {
+    return func(o *options) { o.logger = logger }
+}
+
+// WithPermissionPolicy sets the policy consulted before gated tool calls
+// run; DenyAll by default
+func WithPermissionPolicy(policy PermissionPolicy) Option {
+    return func(o *options) { o.permissions = policy }
+}
+
+// WithLimits overrides the per-turn limits
+func WithLimits(limits Limits) Option {
+    return func(o *options) { o.limits = limits }
+}
+
+// withDefaultProvider sets a provider to create only if WithProvider is not
+// given, so that NewAgent does not build a client it would discard
+func withDefaultProvider(newProvider func() llm.Provider) Option {
+    return func(o *options) { o.newProvider = newProvider }
+}
+
+// withConfig applies the settings NewAgent loads from config files
+func withConfig(config *Config, workingDir string, instructions []InstructionFile, memories []tools.Memory) Option {
+    return func(o *options) {
+        o.config = config
+        o.workingDir = workingDir
+        o.instructions = instructions
+        o.memories = memories
+    }
+}
+
+// New creates an agent from options. Unlike NewAgent it reads no config,
+// instruction or .env files and registers only the tools it is given.
+func New(opts ...Option) (*Agent, error) {
+    o := options{permissions: DenyAll}
+    for _, opt := range opts {
+        opt(&o)
+    }
+    if o.provider == nil && o.newProvider != nil {
+        o.provider = o.newProvider()
+    }
+    if o.provider == nil {
+        return nil, fmt.Errorf("no model provider: use WithProvider")
+    }
+    if o.limits.MaxToolIterations <= 0 {
+        o.limits.MaxToolIterations = DefaultMaxToolIterations
+    }
+    if o.limits.MaxParallelTools <= 0 {
+        o.limits.MaxParallelTools = DefaultMaxParallelTools
+    }
+    config := o.config
+    if config == nil {
+        config = &Config{}
+    }
+    if o.systemPrompt != "" {
+        override := *config
+        override.SystemPrompt, override.SystemPromptFile = o.systemPrompt, ""
+        config = &override
+    }
+    if o.workingDir == "" {
+        workingDir, err := os.Getwd()
+        if err != nil {
+            return nil, fmt.Errorf("failed to get working directory: %w", err)
+        }
+        o.workingDir = workingDir
+    }
+
+    ag := &Agent{
+        llmClient:    o.provider,
+        toolRegistry: make(map[string]tools.Tool),
+        store:        o.store,
+
+        maxToolIterations: o.limits.MaxToolIterations,
+        maxParallelTools:  o.limits.MaxParallelTools,
+        permissions:       o.permissions,
+    }
+    for _, tool := range o.tools {
+        if _, exists := ag.toolRegistry[tool.GetName()]; exists {
+            return nil, fmt.Errorf("tool name %q is registered twice", tool.GetName())
+        }
+        ag.toolRegistry[tool.GetName()] = tool
+    }
+    if o.agentTools {
+        // Sub-agents draw on the other tools, so task is registered last
+        ag.toolRegistry["todo_write"] = &TodoWriteTool{agent: ag}
+        ag.toolRegistry["task"] = &TaskTool{parent: ag}
+    }
+
+    // Render the system prompt from the configured template
+    ag.promptData = newPromptData(o.workingDir, ag.toolRegistry, o.instructions)
+    ag.promptData.Memories = formatMemories(o.memories)
+    systemMessage, err := RenderSystemPrompt(config, ag.promptData)
+    if err != nil {
+        return nil, err
+    }
+    ag.context = []Message{{Role: "system", Content: systemMessage}}
+
+    // Registered after the prompt is rendered; it is only offered in plan mode
+    submitPlanTool := &SubmitPlanTool{agent: ag}
+    ag.toolRegistry[submitPlanTool.GetName()] = submitPlanTool
+
+    if err := ag.addConfiguredHooks(config.Hooks, o.workingDir); err != nil {
+        return nil, err
+    }
+    if o.logger != nil {
+        ag.Subscribe(logEvent(o.logger))
+    }
+
+    // Load the saved session, if any
+    if ag.store != nil {
+        saved, err := ag.store.Load()
+        if err != nil {
+            return nil, fmt.Errorf("failed to load session: %w", err)
+        }
+        if saved != nil {
+            ag.context = saved.Messages
+            ag.todos.items = saved.Todos
+        }
+    }
+
+    // Always use the freshly built system prompt so instruction changes apply to resumed sessions
+    if len(ag.context) > 0 && ag.context[0].Role == "system" {
+        ag.context[0].Content = systemMessage
+    } else {
+        ag.context = append([]Message{{Role: "system", Content: systemMessage}}, ag.context...)
+    }
+    return ag, nil
+}
+
+// logEvent returns a subscriber writing events to logger
+func logEvent(logger *slog.Logger) func(Event) {
+    return func(event Event) {
+        switch e := event.(type) {
+        case TurnStartedEvent:
+            logger.Debug("turn started", "prompt_length", len(e.Prompt))
+        case ToolCallEvent:
+            logger.Debug("tool call", "tool", e.Name, "id", e.ID)
+        case ToolResultEvent:
+            if e.IsError {
+                logger.Warn("tool call failed", "tool", e.Name, "id", e.ID, "error", e.Output, "duration", e.Duration)
+            } else {
+                logger.Debug("tool call finished", "tool", e.Name, "id", e.ID, "duration", e.Duration)
+            }
+        case ErrorEvent:
+            logger.Error("turn failed", "error", e.Err)
+        case TurnFinishedEvent:
+            logger.Info("turn finished", "input_tokens", e.Usage.InputTokens, "output_tokens", e.Usage.OutputTokens, "duration", e.Duration)
+        }
+    }
+}
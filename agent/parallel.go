@@ -0,0 +1,98 @@
+package agent
+
+import (
+    "fmt"
+    "sync"
+    "time"
+
+    "jkneen.ai-agent/llm"
+    "jkneen.ai-agent/tools"
+)
+
+// DefaultMaxParallelTools bounds how many read-only tool calls run at once
+const DefaultMaxParallelTools = 4
+
+// executeToolCalls runs the tool calls from a single model response and
+// returns their results in the same order as the calls. Consecutive read-only
+// calls run concurrently on a bounded worker pool; any call that may mutate
+// state runs on its own, after everything before it has finished and before
+// anything after it starts.
+func (a *Agent) executeToolCalls(calls []llm.ToolCall) []llm.ToolResult {
+    results := make([]llm.ToolResult, len(calls))
+
+    for start := 0; start < len(calls); {
+        if !a.isReadOnlyCall(calls[start]) {
+            results[start] = a.executeToolCall(calls[start])
+            start++
+            continue
+        }
+
+        // Find the run of read-only calls beginning at start
+        end := start
+        for end < len(calls) && a.isReadOnlyCall(calls[end]) {
+            end++
+        }
+
+        workers := a.maxParallelTools
+        if workers < 1 {
+            workers = 1
+        }
+        semaphore := make(chan struct{}, workers)
+        var wg sync.WaitGroup
+        for i := start; i < end; i++ {
+            wg.Add(1)
+            semaphore <- struct{}{}
+            go func(i int) {
+                defer wg.Done()
+                defer func() { <-semaphore }()
+                results[i] = a.executeToolCall(calls[i])
+            }(i)
+        }
+        wg.Wait()
+        start = end
+    }
+
+    return results
+}
+
+// isReadOnlyCall reports whether a tool call may safely run concurrently
+func (a *Agent) isReadOnlyCall(call llm.ToolCall) bool {
+    tool, exists := a.toolRegistry[call.Name]
+    return exists && tools.IsReadOnlyCall(tool, string(call.Input))
+}
+
+// executeToolCall runs a single native tool call, converting failures into
+// error results so the model can see and react to them
+func (a *Agent) executeToolCall(call llm.ToolCall) (result llm.ToolResult) {
+    result.ToolCallID = call.ID
+
+    a.emit(ToolCallEvent{ID: call.ID, Name: call.Name, Input: string(call.Input)})
+    started := time.Now()
+    defer func() {
+        a.emit(ToolResultEvent{ID: call.ID, Name: call.Name, Output: result.Content, IsError: result.IsError, Duration: time.Since(started)})
+    }()
+
+    tool, exists := a.toolRegistry[call.Name]
+    if !exists {
+        result.Content = fmt.Sprintf("tool %s not found", call.Name)
+        result.IsError = true
+        return result
+    }
+
+    // A panicking tool must not take down the other workers
+    defer func() {
+        if r := recover(); r != nil {
+            result.Content = fmt.Sprintf("tool %s panicked: %v", call.Name, r)
+            result.IsError = true
+        }
+    }()
+
+    output, err := a.runTool(tool, string(call.Input))
+    if err != nil {
+        result.Content = err.Error()
+        result.IsError = true
+        return result
+    }
+    result.Content = output
+    return result
+}
@@ -0,0 +1,70 @@
+package agent
+
+import (
+    "fmt"
+
+    "jkneen.ai-agent/tools"
+)
+
+// PermissionRequest describes a tool call awaiting the user's approval
+type PermissionRequest struct {
+    Tool    string
+    Input   string
+    Summary string // Short description of the action, e.g. "git commit of staged changes"
+}
+
+// PermissionPolicy decides whether gated tool calls may run
+type PermissionPolicy interface {
+    Approve(request PermissionRequest) bool
+}
+
+// PermissionFunc adapts a function to a PermissionPolicy
+type PermissionFunc func(request PermissionRequest) bool
+
+// Approve calls f(request)
+func (f PermissionFunc) Approve(request PermissionRequest) bool {
+    return f(request)
+}
+
+// DenyAll is the default policy, refusing every gated tool call
+var DenyAll PermissionPolicy = PermissionFunc(func(PermissionRequest) bool { return false })
+
+// SetPermissionPolicy sets the policy consulted before gated tool calls run
+func (a *Agent) SetPermissionPolicy(policy PermissionPolicy) {
+    if policy == nil {
+        policy = DenyAll
+    }
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    a.permissions = policy
+}
+
+// checkPermission returns an error if plan mode or a sub-agent's read-only
+// restriction forbids the call, or if it needs approval and the policy
+// refuses it
+func (a *Agent) checkPermission(tool tools.Tool, input string) error {
+    if a.readOnly && !tools.IsReadOnlyCall(tool, input) {
+        return fmt.Errorf("sub-agents can only make read-only calls; this call to %s is not", tool.GetName())
+    }
+    if err := a.checkPlanMode(tool, input); err != nil {
+        return err
+    }
+    gated, ok := tool.(tools.GatedTool)
+    if !ok {
+        return nil
+    }
+    needed, summary := gated.NeedsApproval(input)
+    if !needed {
+        return nil
+    }
+    a.mu.RLock()
+    policy := a.permissions
+    a.mu.RUnlock()
+    if policy == nil {
+        policy = DenyAll
+    }
+    if !policy.Approve(PermissionRequest{Tool: tool.GetName(), Input: input, Summary: summary}) {
+        return fmt.Errorf("permission denied: the user did not approve %s", summary)
+    }
+    return nil
+}
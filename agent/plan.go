@@ -0,0 +1,206 @@
+package agent
+
+import (
+    "encoding/json"
+    "fmt"
+    "strings"
+    "sync"
+    "time"
+
+    "jkneen.ai-agent/llm"
+    "jkneen.ai-agent/tools"
+)
+
+// planModeReminder is added to the system prompt while plan mode is on
+const planModeReminder = `Plan mode is active. You may only use read-only tools to investigate; tools that modify files or run commands are disabled. Explore as needed, then call submit_plan with a concrete step-by-step plan and stop. The user will review the plan and either approve it, which unlocks the remaining tools, or send feedback for a revised plan.`
+
+// PlanStep is a single step of a submitted plan
+type PlanStep struct {
+    Description string   `json:"description"`
+    Files       []string `json:"files,omitempty"` // Files the step expects to create or change
+}
+
+// Plan is a step-by-step plan submitted by the model in plan mode
+type Plan struct {
+    Summary   string     `json:"summary"`
+    Steps     []PlanStep `json:"steps"`
+    Submitted time.Time  `json:"-"`
+}
+
+// String renders the plan for display
+func (p *Plan) String() string {
+    var sb strings.Builder
+    sb.WriteString(p.Summary + "\n")
+    for i, step := range p.Steps {
+        sb.WriteString(fmt.Sprintf("\n%d. %s", i+1, step.Description))
+        if len(step.Files) > 0 {
+            sb.WriteString(fmt.Sprintf("\n   Files: %s", strings.Join(step.Files, ", ")))
+        }
+    }
+    return sb.String()
+}
+
+// planState tracks plan mode and the plan awaiting approval
+type planState struct {
+    mu      sync.Mutex
+    active  bool
+    pending *Plan
+}
+
+// EnterPlanMode restricts the agent to read-only tools until a plan is approved
+func (a *Agent) EnterPlanMode() {
+    a.plan.mu.Lock()
+    defer a.plan.mu.Unlock()
+    a.plan.active = true
+    a.plan.pending = nil
+}
+
+// ExitPlanMode leaves plan mode without approving a plan
+func (a *Agent) ExitPlanMode() {
+    a.plan.mu.Lock()
+    defer a.plan.mu.Unlock()
+    a.plan.active = false
+    a.plan.pending = nil
+}
+
+// InPlanMode reports whether mutating tools are currently disabled
+func (a *Agent) InPlanMode() bool {
+    a.plan.mu.Lock()
+    defer a.plan.mu.Unlock()
+    return a.plan.active
+}
+
+// PendingPlan returns the plan awaiting the user's approval, or nil
+func (a *Agent) PendingPlan() *Plan {
+    a.plan.mu.Lock()
+    defer a.plan.mu.Unlock()
+    return a.plan.pending
+}
+
+// ApprovePlan accepts the pending plan and unlocks mutating tools
+func (a *Agent) ApprovePlan() (*Plan, error) {
+    a.plan.mu.Lock()
+    defer a.plan.mu.Unlock()
+    if !a.plan.active {
+        return nil, fmt.Errorf("not in plan mode")
+    }
+    if a.plan.pending == nil {
+        return nil, fmt.Errorf("no plan has been submitted yet")
+    }
+    plan := a.plan.pending
+    a.plan.active = false
+    a.plan.pending = nil
+    return plan, nil
+}
+
+// RejectPlan discards the pending plan, staying in plan mode so the model
+// can revise it
+func (a *Agent) RejectPlan() error {
+    a.plan.mu.Lock()
+    defer a.plan.mu.Unlock()
+    if a.plan.pending == nil {
+        return fmt.Errorf("no plan has been submitted yet")
+    }
+    a.plan.pending = nil
+    return nil
+}
+
+// planModeTool is implemented by tools that change only the agent's own
+// state, such as its task list, and so may be used in plan mode although
+// they are not read-only
+type planModeTool interface {
+    AllowedInPlanMode() bool
+}
+
+// allowedInPlanMode reports whether tool is marked as usable in plan mode
+func allowedInPlanMode(tool tools.Tool) bool {
+    marked, ok := tool.(planModeTool)
+    return ok && marked.AllowedInPlanMode()
+}
+
+// checkPlanMode refuses tool calls that are not read-only while plan mode is on
+func (a *Agent) checkPlanMode(tool tools.Tool, input string) error {
+    if !a.InPlanMode() || tools.IsReadOnlyCall(tool, input) || allowedInPlanMode(tool) {
+        return nil
+    }
+    return fmt.Errorf("plan mode: %s is disabled until the user approves a plan; use read-only tools and call submit_plan", tool.GetName())
+}
+
+// llmMessages converts the context for the model, reminding it of plan mode
+// while it is active
+func (a *Agent) llmMessages() []llm.Message {
+    messages := convertToLLMMessages(a.History())
+    if a.InPlanMode() && len(messages) > 0 && messages[0].Role == "system" {
+        messages[0].Content = strings.TrimRight(messages[0].Content, "\n") + "\n\n" + planModeReminder
+    }
+    return messages
+}
+
+// SubmitPlanTool records the model's plan for the user to review. It is only
+// offered in plan mode.
+type SubmitPlanTool struct {
+    agent *Agent
+}
+
+func (t *SubmitPlanTool) Execute(input string) (string, error) {
+    var plan Plan
+    if err := json.Unmarshal([]byte(input), &plan); err != nil {
+        return "", fmt.Errorf("invalid JSON input: %w", err)
+    }
+    plan.Summary = strings.TrimSpace(plan.Summary)
+    if plan.Summary == "" {
+        return "", fmt.Errorf("summary is required")
+    }
+    if len(plan.Steps) == 0 {
+        return "", fmt.Errorf("at least one step is required")
+    }
+    for i, step := range plan.Steps {
+        if strings.TrimSpace(step.Description) == "" {
+            return "", fmt.Errorf("step %d has no description", i+1)
+        }
+    }
+    plan.Submitted = time.Now()
+
+    t.agent.plan.mu.Lock()
+    defer t.agent.plan.mu.Unlock()
+    if !t.agent.plan.active {
+        return "", fmt.Errorf("submit_plan is only available in plan mode")
+    }
+    t.agent.plan.pending = &plan
+    return "Plan submitted for review. Stop here and wait for the user to approve it or send feedback.", nil
+}
+
+func (t *SubmitPlanTool) GetName() string {
+    return "submit_plan"
+}
+
+func (t *SubmitPlanTool) GetDescription() string {
+    return "Submit a step-by-step implementation plan for the user to review. Only available in plan mode; mutating tools stay disabled until the user approves"
+}
+
+func (t *SubmitPlanTool) GetInputSchema() map[string]interface{} {
+    return map[string]interface{}{
+        "type": "object",
+        "properties": map[string]interface{}{
+            "summary": map[string]interface{}{"type": "string", "description": "One or two sentences describing the overall change"},
+            "steps": map[string]interface{}{
+                "type":        "array",
+                "description": "The ordered steps of the plan",
+                "items": map[string]interface{}{
+                    "type": "object",
+                    "properties": map[string]interface{}{
+                        "description": map[string]interface{}{"type": "string", "description": "What the step does and why"},
+                        "files":       map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}, "description": "Files the step will create or change"},
+                    },
+                    "required": []string{"description"},
+                },
+            },
+        },
+        "required": []string{"summary", "steps"},
+    }
+}
+
+// AllowedInPlanMode is true since plan mode is where plans are submitted
+func (t *SubmitPlanTool) AllowedInPlanMode() bool {
+    return true
+}
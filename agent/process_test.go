@@ -0,0 +1,559 @@
+package agent
+
+import (
+    "errors"
+    "reflect"
+    "slices"
+    "strings"
+    "sync/atomic"
+    "testing"
+    "time"
+
+    "jkneen.ai-agent/llm"
+    "jkneen.ai-agent/llm/llmtest"
+    "jkneen.ai-agent/tools"
+)
+
+// fakeTool is a tool whose behaviour is set by the test
+type fakeTool struct {
+    name     string
+    readOnly bool
+    run      func(input string) (string, error)
+}
+
+func (t *fakeTool) Execute(input string) (string, error) {
+    return t.run(input)
+}
+
+func (t *fakeTool) GetName() string {
+    return t.name
+}
+
+func (t *fakeTool) GetDescription() string {
+    return "A tool for tests"
+}
+
+func (t *fakeTool) GetInputSchema() map[string]interface{} {
+    return map[string]interface{}{"type": "object"}
+}
+
+func (t *fakeTool) IsReadOnly() bool {
+    return t.readOnly
+}
+
+// echoTool returns its input prefixed with its name
+func echoTool(name string, readOnly bool) *fakeTool {
+    return &fakeTool{name: name, readOnly: readOnly, run: func(input string) (string, error) {
+        return name + ": " + input, nil
+    }}
+}
+
+// sentToolResults collects the tool results the agent sent back to the model
+func sentToolResults(requests []llmtest.Request) []llm.ToolResult {
+    var results []llm.ToolResult
+    for _, request := range requests {
+        results = append(results, request.LastMessage().ToolResults...)
+    }
+    return results
+}
+
+func TestProcess(t *testing.T) {
+    errOverloaded := errors.New("overloaded")
+
+    tests := []struct {
+        name   string
+        script []llmtest.Step
+        tools  []tools.Tool
+        limits Limits
+        setup  func(t *testing.T, ag *Agent)
+
+        wantResponse string
+        wantErr      string
+        wantResults  []llm.ToolResult
+        wantRequests int
+    }{
+        {
+            name:         "plain answer",
+            script:       []llmtest.Step{llmtest.Reply("Hello there")},
+            wantResponse: "Hello there",
+            wantRequests: 1,
+        },
+        {
+            name:   "answer naming a tool runs nothing",
+            script: []llmtest.Step{llmtest.Reply("The task is complete. Use the lookup tool to check it again.")},
+            tools:  []tools.Tool{echoTool("task", true), echoTool("lookup", true)},
+            setup: func(t *testing.T, ag *Agent) {
+                ag.Subscribe(func(event Event) {
+                    if call, ok := event.(ToolCallEvent); ok {
+                        t.Errorf("a final answer ran tool %s with input %q", call.Name, call.Input)
+                    }
+                })
+            },
+            wantResponse: "The task is complete. Use the lookup tool to check it again.",
+            wantRequests: 1,
+        },
+        {
+            name: "tool result is sent back",
+            script: []llmtest.Step{
+                llmtest.Reply("Let me check", llmtest.Call("lookup", `{"q":"go"}`)),
+                llmtest.Reply("Found it"),
+            },
+            tools:        []tools.Tool{echoTool("lookup", true)},
+            wantResponse: "Found it",
+            wantResults:  []llm.ToolResult{{ToolCallID: "call-1", Content: `lookup: {"q":"go"}`}},
+            wantRequests: 2,
+        },
+        {
+            name: "several rounds of tool calls",
+            script: []llmtest.Step{
+                llmtest.Reply("", llmtest.Call("lookup", map[string]string{"q": "a"})),
+                llmtest.Reply("", llmtest.Call("lookup", map[string]string{"q": "b"})),
+                llmtest.Reply("Both found"),
+            },
+            tools:        []tools.Tool{echoTool("lookup", true)},
+            wantResponse: "Both found",
+            wantResults: []llm.ToolResult{
+                {ToolCallID: "call-1", Content: `lookup: {"q":"a"}`},
+                {ToolCallID: "call-2", Content: `lookup: {"q":"b"}`},
+            },
+            wantRequests: 3,
+        },
+        {
+            name: "parallel calls keep their order",
+            script: []llmtest.Step{
+                llmtest.Reply("", llmtest.Call("slow", `{}`), llmtest.Call("fast", `{}`)),
+                llmtest.Reply("Done"),
+            },
+            tools: []tools.Tool{
+                &fakeTool{name: "slow", readOnly: true, run: func(string) (string, error) {
+                    time.Sleep(20 * time.Millisecond)
+                    return "slow result", nil
+                }},
+                &fakeTool{name: "fast", readOnly: true, run: func(string) (string, error) { return "fast result", nil }},
+            },
+            wantResponse: "Done",
+            wantResults: []llm.ToolResult{
+                {ToolCallID: "call-1", Content: "slow result"},
+                {ToolCallID: "call-2", Content: "fast result"},
+            },
+            wantRequests: 2,
+        },
+        {
+            name: "tool error is reported to the model",
+            script: []llmtest.Step{
+                llmtest.Reply("", llmtest.Call("broken", `{}`)),
+                llmtest.Reply("The tool failed"),
+            },
+            tools: []tools.Tool{&fakeTool{name: "broken", run: func(string) (string, error) {
+                return "", errors.New("disk full")
+            }}},
+            wantResponse: "The tool failed",
+            wantResults:  []llm.ToolResult{{ToolCallID: "call-1", Content: "disk full", IsError: true}},
+            wantRequests: 2,
+        },
+        {
+            name: "unknown tool",
+            script: []llmtest.Step{
+                llmtest.Reply("", llmtest.Call("missing", `{}`)),
+                llmtest.Reply("Sorry"),
+            },
+            wantResponse: "Sorry",
+            wantResults:  []llm.ToolResult{{ToolCallID: "call-1", Content: "tool missing not found", IsError: true}},
+            wantRequests: 2,
+        },
+        {
+            name: "panicking tool",
+            script: []llmtest.Step{
+                llmtest.Reply("", llmtest.Call("explode", `{}`)),
+                llmtest.Reply("Recovered"),
+            },
+            tools: []tools.Tool{&fakeTool{name: "explode", run: func(string) (string, error) {
+                panic("boom")
+            }}},
+            wantResponse: "Recovered",
+            wantResults:  []llm.ToolResult{{ToolCallID: "call-1", Content: "tool explode panicked: boom", IsError: true}},
+            wantRequests: 2,
+        },
+        {
+            name:         "model error",
+            script:       []llmtest.Step{llmtest.Fail(errOverloaded)},
+            wantErr:      "overloaded",
+            wantRequests: 1,
+        },
+        {
+            name: "model error after a tool call",
+            script: []llmtest.Step{
+                llmtest.Reply("", llmtest.Call("lookup", `{}`)),
+                llmtest.Fail(errOverloaded),
+            },
+            tools:        []tools.Tool{echoTool("lookup", true)},
+            wantErr:      "overloaded",
+            wantResults:  []llm.ToolResult{{ToolCallID: "call-1", Content: "lookup: {}"}},
+            wantRequests: 2,
+        },
+        {
+            name: "too many rounds of tool calls",
+            script: []llmtest.Step{
+                llmtest.Reply("", llmtest.Call("lookup", `{}`)),
+                llmtest.Reply("", llmtest.Call("lookup", `{}`)),
+                llmtest.Reply("", llmtest.Call("lookup", `{}`)),
+            },
+            tools:   []tools.Tool{echoTool("lookup", true)},
+            limits:  Limits{MaxToolIterations: 2},
+            wantErr: "stopped after 2 rounds of tool calls",
+            wantResults: []llm.ToolResult{
+                {ToolCallID: "call-1", Content: "lookup: {}"},
+                {ToolCallID: "call-2", Content: "lookup: {}"},
+            },
+            wantRequests: 3,
+        },
+        {
+            name: "plan mode blocks mutating tools",
+            script: []llmtest.Step{
+                llmtest.Reply("", llmtest.Call("write", `{}`)),
+                llmtest.Reply("I'll plan first"),
+            },
+            tools:        []tools.Tool{echoTool("write", false)},
+            setup:        func(t *testing.T, ag *Agent) { ag.EnterPlanMode() },
+            wantResponse: "I'll plan first",
+            wantResults: []llm.ToolResult{{
+                ToolCallID: "call-1",
+                Content:    "plan mode: write is disabled until the user approves a plan; use read-only tools and call submit_plan",
+                IsError:    true,
+            }},
+            wantRequests: 2,
+        },
+        {
+            name: "hook rewrites tool input",
+            script: []llmtest.Step{
+                llmtest.Reply("", llmtest.Call("lookup", `{"q":"old"}`)),
+                llmtest.Reply("Done"),
+            },
+            tools: []tools.Tool{echoTool("lookup", true)},
+            setup: func(t *testing.T, ag *Agent) {
+                err := ag.AddHook(HookPreToolUse, "look.*", func(event HookEvent) (*HookResult, error) {
+                    return &HookResult{ToolInput: []byte(`{"q":"new"}`)}, nil
+                })
+                if err != nil {
+                    t.Fatal(err)
+                }
+            },
+            wantResponse: "Done",
+            wantResults:  []llm.ToolResult{{ToolCallID: "call-1", Content: `lookup: {"q":"new"}`}},
+            wantRequests: 2,
+        },
+        {
+            name: "hook blocks tool call",
+            script: []llmtest.Step{
+                llmtest.Reply("", llmtest.Call("write", `{}`)),
+                llmtest.Reply("Blocked"),
+            },
+            tools: []tools.Tool{echoTool("write", false)},
+            setup: func(t *testing.T, ag *Agent) {
+                ag.AddHook(HookPreToolUse, "", func(event HookEvent) (*HookResult, error) {
+                    return &HookResult{Block: true, Reason: "read-only checkout"}, nil
+                })
+            },
+            wantResponse: "Blocked",
+            wantResults: []llm.ToolResult{{
+                ToolCallID: "call-1",
+                Content:    "write was blocked by a hook: read-only checkout",
+                IsError:    true,
+            }},
+            wantRequests: 2,
+        },
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            provider := llmtest.NewProvider(test.script...)
+            ag := newTestAgent(t, provider, WithTools(test.tools...), WithLimits(test.limits))
+            if test.setup != nil {
+                test.setup(t, ag)
+            }
+
+            response, err := ag.Process("Do the thing")
+            if test.wantErr != "" {
+                if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+                    t.Fatalf("Process error = %v, want %q", err, test.wantErr)
+                }
+            } else if err != nil {
+                t.Fatalf("Process: %v", err)
+            }
+            if response != test.wantResponse {
+                t.Errorf("response = %q, want %q", response, test.wantResponse)
+            }
+
+            requests := provider.Requests()
+            if len(requests) != test.wantRequests {
+                t.Errorf("model received %d requests, want %d", len(requests), test.wantRequests)
+            }
+            if got := sentToolResults(requests); !reflect.DeepEqual(got, test.wantResults) {
+                t.Errorf("tool results = %+v, want %+v", got, test.wantResults)
+            }
+            if remaining := provider.Remaining(); remaining != 0 {
+                t.Errorf("%d scripted replies were not used", remaining)
+            }
+        })
+    }
+}
+
+func TestProcessRequests(t *testing.T) {
+    provider := llmtest.NewProvider(
+        llmtest.Reply("", llmtest.Call("lookup", `{}`)).WithUsage(100, 10),
+        llmtest.Reply("First answer").WithUsage(120, 5),
+        llmtest.Reply("Second answer").WithUsage(150, 7),
+    )
+    ag := newTestAgent(t, provider, WithTools(echoTool("lookup", true), echoTool("write", false)))
+    var finished []TurnFinishedEvent
+    ag.Subscribe(func(event Event) {
+        if e, ok := event.(TurnFinishedEvent); ok {
+            finished = append(finished, e)
+        }
+    })
+
+    for _, prompt := range []string{"First question", "Second question"} {
+        if _, err := ag.Process(prompt); err != nil {
+            t.Fatalf("Process(%q): %v", prompt, err)
+        }
+    }
+
+    requests := provider.Requests()
+    if len(requests) != 3 {
+        t.Fatalf("model received %d requests, want 3", len(requests))
+    }
+    first := requests[0]
+    if first.Messages[0].Role != "system" || first.Messages[0].Content != "You are a test agent." {
+        t.Errorf("first message = %+v, want the system prompt", first.Messages[0])
+    }
+    if got := first.LastMessage(); got.Role != "user" || got.Content != "First question" {
+        t.Errorf("last message = %+v, want the prompt", got)
+    }
+    // submit_plan is only offered in plan mode
+    if got, want := first.ToolNames(), []string{"lookup", "write"}; !reflect.DeepEqual(got, want) {
+        t.Errorf("offered tools = %v, want %v", got, want)
+    }
+
+    // The second turn sees the whole first turn
+    second := requests[2]
+    var roles []string
+    for _, message := range second.Messages {
+        roles = append(roles, message.Role)
+    }
+    if got, want := strings.Join(roles, ","), "system,user,assistant,tool,assistant,user"; got != want {
+        t.Errorf("second turn roles = %s, want %s", got, want)
+    }
+
+    if len(finished) != 2 {
+        t.Fatalf("got %d TurnFinishedEvents, want 2", len(finished))
+    }
+    if got, want := finished[0].Usage, (llm.Usage{InputTokens: 220, OutputTokens: 15}); got != want {
+        t.Errorf("first turn usage = %+v, want %+v", got, want)
+    }
+    if got, want := finished[1].Usage, (llm.Usage{InputTokens: 150, OutputTokens: 7}); got != want {
+        t.Errorf("second turn usage = %+v, want %+v", got, want)
+    }
+}
+
+func TestProcessPlanModeOffersReadOnlyTools(t *testing.T) {
+    provider := llmtest.NewProvider(llmtest.Reply("Planning"), llmtest.Reply("Implementing"))
+    ag := newTestAgent(t, provider, WithTools(echoTool("lookup", true), echoTool("write", false)))
+
+    ag.EnterPlanMode()
+    ag.Process("Plan it")
+    ag.ExitPlanMode()
+    ag.Process("Do it")
+
+    requests := provider.Requests()
+    if got, want := requests[0].ToolNames(), []string{"lookup", "submit_plan"}; !reflect.DeepEqual(got, want) {
+        t.Errorf("tools in plan mode = %v, want %v", got, want)
+    }
+    if !strings.Contains(requests[0].Messages[0].Content, planModeReminder) {
+        t.Error("system prompt in plan mode lacks the plan mode reminder")
+    }
+    if got, want := requests[1].ToolNames(), []string{"lookup", "write"}; !reflect.DeepEqual(got, want) {
+        t.Errorf("tools after plan mode = %v, want %v", got, want)
+    }
+}
+
+// actionTool is read-only unless its input asks for a write, like git
+type actionTool struct {
+    *fakeTool
+}
+
+func (t actionTool) IsReadOnlyInput(input string) bool {
+    return !strings.Contains(input, `"write"`)
+}
+
+func TestProcessPlanModeAllowsReadOnlyCalls(t *testing.T) {
+    provider := llmtest.NewProvider(
+        llmtest.Reply("", llmtest.Call("repo", `{"action":"read"}`), llmtest.Call("repo", `{"action":"write"}`)),
+        llmtest.Reply("Planned"),
+    )
+    ag := newTestAgent(t, provider, WithTools(actionTool{echoTool("repo", false)}))
+    ag.EnterPlanMode()
+    if _, err := ag.Process("Plan it"); err != nil {
+        t.Fatalf("Process: %v", err)
+    }
+
+    requests := provider.Requests()
+    if got, want := requests[0].ToolNames(), []string{"repo", "submit_plan"}; !reflect.DeepEqual(got, want) {
+        t.Errorf("tools in plan mode = %v, want %v", got, want)
+    }
+    results := sentToolResults(requests)
+    if len(results) != 2 || results[0].IsError || !results[1].IsError || !strings.Contains(results[1].Content, "plan mode") {
+        t.Errorf("tool results = %+v, want the read to run and the write to be refused", results)
+    }
+}
+
+func TestProcessTodoWriteInPlanMode(t *testing.T) {
+    first := `{"todos":[{"content":"read the code","status":"in_progress"}]}`
+    second := `{"todos":[{"content":"read the code","status":"done"}]}`
+    provider := llmtest.NewProvider(
+        llmtest.Reply("", llmtest.Call("todo_write", first), llmtest.Call("todo_write", second)),
+        llmtest.Reply("Planned"),
+    )
+    ag := newTestAgent(t, provider, WithAgentTools())
+    var updates [][]TodoItem
+    ag.Subscribe(func(event Event) {
+        if updated, ok := event.(TodosUpdatedEvent); ok {
+            updates = append(updates, updated.Todos)
+        }
+    })
+    ag.EnterPlanMode()
+    if _, err := ag.Process("Plan it"); err != nil {
+        t.Fatalf("Process: %v", err)
+    }
+
+    if names := provider.Requests()[0].ToolNames(); !slices.Contains(names, "todo_write") {
+        t.Errorf("tools in plan mode = %v, want todo_write among them", names)
+    }
+    if results := sentToolResults(provider.Requests()); len(results) != 2 || results[0].IsError || results[1].IsError {
+        t.Errorf("tool results = %+v, want both todo_write calls to run", results)
+    }
+    // Calls in one response run in order, so the second list wins
+    if len(updates) != 2 || updates[0][0].Status != TodoInProgress || updates[1][0].Status != TodoDone {
+        t.Errorf("updates = %+v, want the first then the second list", updates)
+    }
+    if todos := ag.Todos(); len(todos) != 1 || todos[0].Status != TodoDone {
+        t.Errorf("Todos() = %+v, want the second list", todos)
+    }
+}
+
+func TestProcessSubAgentRunsToolHooks(t *testing.T) {
+    provider := llmtest.NewProvider(
+        llmtest.Reply("", llmtest.Call("task", `{"prompt":"Find it"}`)),
+        llmtest.Reply("", llmtest.Call("lookup", `{}`)),
+        llmtest.Reply("Not found"),
+        llmtest.Reply("Done"),
+    )
+    ag := newTestAgent(t, provider, WithTools(echoTool("lookup", true)), WithAgentTools())
+    err := ag.AddHook(HookPreToolUse, "lookup", func(event HookEvent) (*HookResult, error) {
+        return &HookResult{Block: true, Reason: "lookups are off"}, nil
+    })
+    if err != nil {
+        t.Fatalf("AddHook: %v", err)
+    }
+    if _, err := ag.Process("Delegate it"); err != nil {
+        t.Fatalf("Process: %v", err)
+    }
+
+    // The third request is the sub-agent's, carrying its lookup result
+    results := provider.Requests()[2].LastMessage().ToolResults
+    if len(results) != 1 || !results[0].IsError || !strings.Contains(results[0].Content, "lookups are off") {
+        t.Errorf("sub-agent tool results = %+v, want the lookup blocked by the hook", results)
+    }
+}
+
+func TestProcessForwardsSubAgentUsageAndEvents(t *testing.T) {
+    provider := llmtest.NewProvider(
+        llmtest.Reply("", llmtest.Call("task", `{"description":"find it","prompt":"Find it"}`)).WithUsage(100, 10),
+        llmtest.Reply("", llmtest.Call("lookup", `{}`)).WithUsage(50, 5),
+        llmtest.Reply("Found").WithUsage(60, 6),
+        llmtest.Reply("Done").WithUsage(120, 12),
+    )
+    ag := newTestAgent(t, provider, WithTools(echoTool("lookup", true)), WithAgentTools())
+    var finished []TurnFinishedEvent
+    var forwarded []SubAgentEvent
+    ag.Subscribe(func(event Event) {
+        switch e := event.(type) {
+        case TurnFinishedEvent:
+            finished = append(finished, e)
+        case SubAgentEvent:
+            forwarded = append(forwarded, e)
+        }
+    })
+    if _, err := ag.Process("Delegate it"); err != nil {
+        t.Fatalf("Process: %v", err)
+    }
+
+    if len(finished) != 1 {
+        t.Fatalf("got %d TurnFinishedEvents, want only the parent's", len(finished))
+    }
+    if got, want := finished[0].Usage, (llm.Usage{InputTokens: 330, OutputTokens: 33}); got != want {
+        t.Errorf("turn usage = %+v, want %+v including the sub-agent", got, want)
+    }
+    var calls []string
+    for _, e := range forwarded {
+        if e.Task != "find it" {
+            t.Errorf("forwarded event for task %q, want %q", e.Task, "find it")
+        }
+        if call, ok := e.Event.(ToolCallEvent); ok {
+            calls = append(calls, call.Name)
+        }
+    }
+    if want := []string{"lookup"}; !reflect.DeepEqual(calls, want) {
+        t.Errorf("forwarded tool calls = %v, want %v", calls, want)
+    }
+}
+
+func TestProcessExhaustedScript(t *testing.T) {
+    provider := llmtest.NewProvider(llmtest.Reply("", llmtest.Call("lookup", `{}`)))
+    ag := newTestAgent(t, provider, WithTools(echoTool("lookup", true)))
+
+    var errorEvents atomic.Int32
+    ag.Subscribe(func(event Event) {
+        if _, ok := event.(ErrorEvent); ok {
+            errorEvents.Add(1)
+        }
+    })
+    _, err := ag.Process("Go")
+    if err == nil || !strings.Contains(err.Error(), "script exhausted at request 2") {
+        t.Errorf("Process error = %v, want the script to run out", err)
+    }
+    if errorEvents.Load() != 1 {
+        t.Errorf("got %d ErrorEvents, want 1", errorEvents.Load())
+    }
+}
+
+func TestProcessModelLatency(t *testing.T) {
+    provider := llmtest.NewProvider(llmtest.Reply("Slow answer").After(50 * time.Millisecond))
+    ag := newTestAgent(t, provider)
+
+    var turn TurnFinishedEvent
+    ag.Subscribe(func(event Event) {
+        if e, ok := event.(TurnFinishedEvent); ok {
+            turn = e
+        }
+    })
+    done := make(chan struct{})
+    go func() {
+        defer close(done)
+        ag.Process("Take your time")
+    }()
+
+    // Wait for the turn to start before checking that it blocks others
+    for len(provider.Requests()) == 0 {
+        time.Sleep(time.Millisecond)
+    }
+    if _, err := ag.TryProcess("Hurry"); !errors.Is(err, ErrBusy) {
+        t.Errorf("TryProcess during a slow reply returned %v, want ErrBusy", err)
+    }
+    <-done
+    if turn.Duration < 50*time.Millisecond {
+        t.Errorf("turn took %s, want at least the scripted latency", turn.Duration)
+    }
+    if turn.Response != "Slow answer" {
+        t.Errorf("response = %q", turn.Response)
+    }
+}
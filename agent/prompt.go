@@ -0,0 +1,141 @@
+package agent
+
+import (
+    "fmt"
+    "os"
+    "os/exec"
+    "runtime"
+    "sort"
+    "strings"
+    "text/template"
+    "time"
+
+    "jkneen.ai-agent/tools"
+)
+
+// DefaultSystemPrompt is the template used when no override is configured
+const DefaultSystemPrompt = `You are a helpful AI assistant powered by Claude. You have access to these tools:
+
+{{range .Tools}}- {{.Name}}: {{.Description}}
+{{end}}
+Call tools through the tool-use interface, passing arguments that match each tool's input schema; describing a tool call in your reply does not run it. You can make several independent tool calls in one response. After each call you receive its result and can continue working. Read files before editing them, and answer directly when no tool is needed.
+
+Environment:
+- Working directory: {{.WorkingDir}}
+- Platform: {{.OS}}/{{.Arch}}
+- Date: {{.Date}}
+{{- if .GitBranch}}
+- Git branch: {{.GitBranch}}
+{{- end}}
+{{.Instructions}}{{.Memories}}`
+
+// maxPromptMemories bounds how many saved memories are put in the system prompt
+const maxPromptMemories = 50
+
+// ToolInfo describes a tool to the system prompt template
+type ToolInfo struct {
+    Name        string
+    Description string
+}
+
+// PromptData holds the variables available to system prompt templates
+type PromptData struct {
+    WorkingDir   string
+    OS           string
+    Arch         string
+    Date         string
+    GitBranch    string
+    Tools        []ToolInfo
+    Instructions string
+    Memories     string
+}
+
+// newPromptData gathers template variables for the current environment
+func newPromptData(dir string, toolRegistry map[string]tools.Tool, instructions []InstructionFile) PromptData {
+    toolInfos := make([]ToolInfo, 0, len(toolRegistry))
+    for _, tool := range toolRegistry {
+        toolInfos = append(toolInfos, ToolInfo{Name: tool.GetName(), Description: tool.GetDescription()})
+    }
+    sort.Slice(toolInfos, func(i, j int) bool { return toolInfos[i].Name < toolInfos[j].Name })
+
+    return PromptData{
+        WorkingDir:   dir,
+        OS:           runtime.GOOS,
+        Arch:         runtime.GOARCH,
+        Date:         time.Now().Format("2006-01-02"),
+        GitBranch:    gitBranch(dir),
+        Tools:        toolInfos,
+        Instructions: formatInstructions(instructions),
+    }
+}
+
+// formatMemories renders saved memories for the system prompt, newest last,
+// keeping the most recent when there are too many
+func formatMemories(memories []tools.Memory) string {
+    if len(memories) == 0 {
+        return ""
+    }
+    sort.SliceStable(memories, func(i, j int) bool { return memories[i].Created.Before(memories[j].Created) })
+    if len(memories) > maxPromptMemories {
+        memories = memories[len(memories)-maxPromptMemories:]
+    }
+    var sb strings.Builder
+    sb.WriteString("\n\nMemories saved in earlier sessions (use memory_search for more, memory_delete to drop any that are out of date):")
+    for _, memory := range memories {
+        sb.WriteString(fmt.Sprintf("\n- [%s] (%s) %s", memory.ID, memory.Scope, memory.Content))
+    }
+    return sb.String()
+}
+
+// gitBranch returns the current git branch of dir, or "" outside a repository
+func gitBranch(dir string) string {
+    cmd := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
+    cmd.Dir = dir
+    out, err := cmd.Output()
+    if err != nil {
+        return ""
+    }
+    return strings.TrimSpace(string(out))
+}
+
+// RenderSystemPrompt renders the configured (or default) template followed by
+// any configured appendix
+func RenderSystemPrompt(config *Config, data PromptData) (string, error) {
+    text := DefaultSystemPrompt
+    if config.SystemPromptFile != "" {
+        content, err := os.ReadFile(config.SystemPromptFile)
+        if err != nil {
+            return "", fmt.Errorf("failed to read system prompt file: %w", err)
+        }
+        text = string(content)
+    } else if config.SystemPrompt != "" {
+        text = config.SystemPrompt
+    }
+
+    prompt, err := renderTemplate("system_prompt", text, data)
+    if err != nil {
+        return "", err
+    }
+
+    if config.AppendSystemPrompt != "" {
+        appendix, err := renderTemplate("append_system_prompt", config.AppendSystemPrompt, data)
+        if err != nil {
+            return "", err
+        }
+        prompt = strings.TrimRight(prompt, "\n") + "\n\n" + appendix
+    }
+    return prompt, nil
+}
+
+// renderTemplate executes a single prompt template against data
+func renderTemplate(name, text string, data PromptData) (string, error) {
+    tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+    if err != nil {
+        return "", fmt.Errorf("invalid %s template: %w", name, err)
+    }
+    var sb strings.Builder
+    if err := tmpl.Execute(&sb, data); err != nil {
+        return "", fmt.Errorf("failed to render %s template: %w", name, err)
+    }
+    return sb.String(), nil
+}
@@ -0,0 +1,59 @@
+package agent
+
+import (
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "jkneen.ai-agent/llm/llmtest"
+)
+
+// TestSessionReplay runs a recorded two-turn session against the real
+// Claude client, replayed from testdata/cassettes/session.json
+func TestSessionReplay(t *testing.T) {
+    client := llmtest.NewCassetteClient(t, filepath.Join("testdata", "cassettes", "session.json"))
+    lookup := &fakeTool{name: "lookup", readOnly: true, run: func(input string) (string, error) {
+        return "Capitals: France - Paris; Spain - Madrid", nil
+    }}
+    ag := newTestAgent(t, client, WithTools(lookup))
+
+    var finished []TurnFinishedEvent
+    var toolCalls []ToolCallEvent
+    ag.Subscribe(func(event Event) {
+        switch e := event.(type) {
+        case TurnFinishedEvent:
+            finished = append(finished, e)
+        case ToolCallEvent:
+            toolCalls = append(toolCalls, e)
+        }
+    })
+
+    for _, turn := range []struct{ prompt, want string }{
+        {"Use the lookup tool to find the capital of France.", "Paris"},
+        {"And the capital of Spain? Answer from what you already know.", "Madrid"},
+    } {
+        response, err := ag.Process(turn.prompt)
+        if err != nil {
+            t.Fatalf("Process(%q): %v", turn.prompt, err)
+        }
+        if !strings.Contains(response, turn.want) {
+            t.Errorf("Process(%q) = %q, want it to mention %s", turn.prompt, response, turn.want)
+        }
+    }
+
+    if len(toolCalls) != 1 || toolCalls[0].Name != "lookup" {
+        t.Errorf("tool calls = %+v, want a single lookup", toolCalls)
+    }
+    var roles []string
+    for _, message := range ag.History() {
+        roles = append(roles, message.Role)
+    }
+    if got, want := strings.Join(roles, ","), "system,user,assistant,tool,assistant,user,assistant"; got != want {
+        t.Errorf("history roles = %s, want %s", got, want)
+    }
+    for i, turn := range finished {
+        if turn.Usage.InputTokens == 0 || turn.Usage.OutputTokens == 0 {
+            t.Errorf("turn %d usage = %+v, want token counts", i+1, turn.Usage)
+        }
+    }
+}
@@ -0,0 +1,61 @@
+package agent
+
+import (
+    "encoding/json"
+    "os"
+    "strings"
+
+    "jkneen.ai-agent/tools"
+)
+
+// Session is the saved state of a conversation
+type Session struct {
+    Messages []Message  `json:"messages"`
+    Todos    []TodoItem `json:"todos,omitempty"`
+}
+
+// Store persists a session between runs
+type Store interface {
+    // Load returns the saved session, or nil if nothing has been saved yet
+    Load() (*Session, error)
+    Save(session *Session) error
+}
+
+// FileStore keeps a session in a JSON file
+type FileStore struct {
+    Path string
+}
+
+// Load reads the session file; a missing file is not an error
+func (s *FileStore) Load() (*Session, error) {
+    data, err := os.ReadFile(s.Path)
+    if os.IsNotExist(err) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    // Older sessions stored only the array of messages
+    if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+        var messages []Message
+        if err := json.Unmarshal(data, &messages); err != nil {
+            return nil, err
+        }
+        return &Session{Messages: messages}, nil
+    }
+    var saved Session
+    if err := json.Unmarshal(data, &saved); err != nil {
+        return nil, err
+    }
+    return &saved, nil
+}
+
+// Save writes the session file
+func (s *FileStore) Save(session *Session) error {
+    data, err := json.MarshalIndent(session, "", "  ")
+    if err != nil {
+        return err
+    }
+    // Written atomically, as SaveContext may run while another save is in progress
+    return tools.WriteFileAtomic(s.Path, data, 0644)
+}
@@ -0,0 +1,186 @@
+package agent
+
+import (
+    "encoding/json"
+    "fmt"
+    "sort"
+    "strings"
+
+    "jkneen.ai-agent/tools"
+)
+
+// SubAgentPrompt is the system prompt template for agents started by the task tool
+const SubAgentPrompt = `You are a sub-agent working on a single task delegated by another AI assistant. You have access to these tools:
+
+{{range .Tools}}- {{.Name}}: {{.Description}}
+{{end}}
+Work through the task on your own using the tools; you cannot ask the user or the other assistant questions. You can read and search but not modify anything. When you are done, reply with a concise final report containing only what the other assistant needs: findings, relevant file paths with line numbers, and any open questions. Your intermediate steps are not passed on.
+
+Environment:
+- Working directory: {{.WorkingDir}}
+- Platform: {{.OS}}/{{.Arch}}
+- Date: {{.Date}}
+{{- if .GitBranch}}
+- Git branch: {{.GitBranch}}
+{{- end}}
+{{.Instructions}}{{.Memories}}`
+
+// TaskRequest defines the structure for task operations
+type TaskRequest struct {
+    Description string   `json:"description"`     // Short label for the task
+    Prompt      string   `json:"prompt"`          // Full instructions for the sub-agent
+    Tools       []string `json:"tools,omitempty"` // Subset of read-only tools to allow; all of them by default
+}
+
+// TaskTool delegates a self-contained task to a sub-agent with a fresh
+// context and returns only the sub-agent's final report. Sub-agents may only
+// use read-only tools, so several tasks can run concurrently.
+type TaskTool struct {
+    parent *Agent
+}
+
+func (t *TaskTool) Execute(input string) (string, error) {
+    request := TaskRequest{Prompt: strings.TrimSpace(input)}
+    if decodeTaskRequest(input, &request) {
+        request.Prompt = strings.TrimSpace(request.Prompt)
+    }
+    if request.Prompt == "" {
+        return "", fmt.Errorf("prompt is required")
+    }
+
+    toolRegistry, err := t.toolSubset(request.Tools)
+    if err != nil {
+        return "", err
+    }
+    child, err := t.parent.newSubAgent(toolRegistry)
+    if err != nil {
+        return "", err
+    }
+    // The parent's subscribers see the sub-agent's progress, and its tokens
+    // count towards the parent's turn
+    child.Subscribe(func(event Event) {
+        if finished, ok := event.(TurnFinishedEvent); ok {
+            t.parent.addUsage(finished.Usage)
+        }
+        t.parent.emit(SubAgentEvent{Task: request.Description, Event: event})
+    })
+
+    report, err := child.Process(request.Prompt)
+    if err != nil {
+        return "", fmt.Errorf("sub-agent failed: %w", err)
+    }
+    if request.Description != "" {
+        return fmt.Sprintf("Report for task '%s':\n%s", request.Description, report), nil
+    }
+    return report, nil
+}
+
+// decodeTaskRequest parses JSON input; plain text input is the prompt itself
+func decodeTaskRequest(input string, request *TaskRequest) bool {
+    trimmed := strings.TrimSpace(input)
+    if !strings.HasPrefix(trimmed, "{") {
+        return false
+    }
+    return json.Unmarshal([]byte(trimmed), request) == nil
+}
+
+// availableTools returns the parent's tools a sub-agent may use: read-only
+// tools other than task itself, so sub-agents cannot nest, and those that
+// act on the parent's own state
+func (t *TaskTool) availableTools() map[string]tools.Tool {
+    available := make(map[string]tools.Tool)
+    for name, tool := range t.parent.toolRegistry {
+        switch tool.(type) {
+        case *TaskTool, *SubmitPlanTool, *TodoWriteTool:
+            continue
+        }
+        if !tools.HasReadOnlyCalls(tool) {
+            continue
+        }
+        available[name] = tool
+    }
+    return available
+}
+
+// toolSubset restricts the available tools to the requested names
+func (t *TaskTool) toolSubset(names []string) (map[string]tools.Tool, error) {
+    available := t.availableTools()
+    if len(names) == 0 {
+        return available, nil
+    }
+    subset := make(map[string]tools.Tool)
+    for _, name := range names {
+        tool, ok := available[name]
+        if !ok {
+            return nil, fmt.Errorf("tool %q is not available to sub-agents; choose from %s", name, strings.Join(sortedToolNames(available), ", "))
+        }
+        subset[name] = tool
+    }
+    return subset, nil
+}
+
+// sortedToolNames lists the names in a tool registry
+func sortedToolNames(toolRegistry map[string]tools.Tool) []string {
+    names := make([]string, 0, len(toolRegistry))
+    for name := range toolRegistry {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// newSubAgent creates an agent sharing a's model client and tool hooks but
+// with its own context and the given tools. Sub-agents are never saved to disk.
+func (a *Agent) newSubAgent(toolRegistry map[string]tools.Tool) (*Agent, error) {
+    data := a.promptData
+    data.Tools = make([]ToolInfo, 0, len(toolRegistry))
+    for _, name := range sortedToolNames(toolRegistry) {
+        data.Tools = append(data.Tools, ToolInfo{Name: name, Description: toolRegistry[name].GetDescription()})
+    }
+    systemMessage, err := renderTemplate("sub-agent", SubAgentPrompt, data)
+    if err != nil {
+        return nil, err
+    }
+
+    child := &Agent{
+        context:      []Message{{Role: "system", Content: systemMessage}},
+        llmClient:    a.llmClient,
+        toolRegistry: toolRegistry,
+        promptData:   a.promptData,
+
+        maxToolIterations: a.maxToolIterations,
+        maxParallelTools:  a.maxParallelTools,
+        readOnly:          true,
+        permissions:       DenyAll,
+    }
+    child.hooks.hooks = a.hooks.toolHooks()
+    return child, nil
+}
+
+func (t *TaskTool) GetName() string {
+    return "task"
+}
+
+func (t *TaskTool) GetDescription() string {
+    return "Delegate a self-contained research task (e.g. exploring a large part of the codebase) to a sub-agent with a fresh context and read-only tools; only its final report is returned. Run several at once for independent questions"
+}
+
+func (t *TaskTool) GetInputSchema() map[string]interface{} {
+    return map[string]interface{}{
+        "type": "object",
+        "properties": map[string]interface{}{
+            "description": map[string]interface{}{"type": "string", "description": "A short (3-5 word) label for the task"},
+            "prompt":      map[string]interface{}{"type": "string", "description": "Complete instructions for the sub-agent, including what to report back"},
+            "tools": map[string]interface{}{
+                "type":        "array",
+                "items":       map[string]interface{}{"type": "string"},
+                "description": "Names of the read-only tools the sub-agent may use (default: all of them)",
+            },
+        },
+        "required": []string{"prompt"},
+    }
+}
+
+func (t *TaskTool) IsReadOnly() bool {
+    return true
+}
@@ -0,0 +1,144 @@
+package agent
+
+import (
+    "encoding/json"
+    "fmt"
+    "strings"
+    "sync"
+)
+
+// Todo statuses
+const (
+    TodoPending    = "pending"
+    TodoInProgress = "in_progress"
+    TodoDone       = "done"
+)
+
+// TodoItem is a single entry of the agent's task list
+type TodoItem struct {
+    Content string `json:"content"`
+    Status  string `json:"status"`
+}
+
+// todoList holds the task list the model maintains with todo_write
+type todoList struct {
+    mu    sync.Mutex
+    items []TodoItem
+}
+
+// Todos returns a copy of the current task list
+func (a *Agent) Todos() []TodoItem {
+    a.todos.mu.Lock()
+    defer a.todos.mu.Unlock()
+    return append([]TodoItem(nil), a.todos.items...)
+}
+
+// setTodos replaces the task list and publishes a TodosUpdatedEvent
+func (a *Agent) setTodos(items []TodoItem) {
+    a.todos.mu.Lock()
+    a.todos.items = append([]TodoItem(nil), items...)
+    a.todos.mu.Unlock()
+
+    a.emit(TodosUpdatedEvent{Todos: append([]TodoItem(nil), items...)})
+}
+
+// FormatTodos renders a task list with a checkbox per item
+func FormatTodos(items []TodoItem) string {
+    if len(items) == 0 {
+        return "(no todos)"
+    }
+    var sb strings.Builder
+    done := 0
+    for _, item := range items {
+        box := "[ ]"
+        switch item.Status {
+        case TodoInProgress:
+            box = "[~]"
+        case TodoDone:
+            box = "[x]"
+            done++
+        }
+        sb.WriteString(fmt.Sprintf("%s %s\n", box, item.Content))
+    }
+    sb.WriteString(fmt.Sprintf("%d of %d done", done, len(items)))
+    return sb.String()
+}
+
+// TodoWriteRequest defines the structure for todo_write operations
+type TodoWriteRequest struct {
+    Todos []TodoItem `json:"todos"`
+}
+
+// TodoWriteTool lets the model maintain a task list for multi-step work.
+// Each call replaces the whole list.
+type TodoWriteTool struct {
+    agent *Agent
+}
+
+func (t *TodoWriteTool) Execute(input string) (string, error) {
+    var request TodoWriteRequest
+    if err := json.Unmarshal([]byte(input), &request); err != nil {
+        return "", fmt.Errorf("invalid JSON input: %w", err)
+    }
+
+    inProgress := 0
+    for i, item := range request.Todos {
+        item.Content = strings.TrimSpace(item.Content)
+        if item.Content == "" {
+            return "", fmt.Errorf("todo %d has no content", i+1)
+        }
+        switch item.Status {
+        case TodoPending, TodoDone:
+        case TodoInProgress:
+            inProgress++
+        case "":
+            item.Status = TodoPending
+        default:
+            return "", fmt.Errorf("todo %d has invalid status %q: use %s, %s or %s", i+1, item.Status, TodoPending, TodoInProgress, TodoDone)
+        }
+        request.Todos[i] = item
+    }
+
+    t.agent.setTodos(request.Todos)
+
+    result := "Todo list updated:\n" + FormatTodos(request.Todos)
+    if inProgress > 1 {
+        result += "\nNote: work on one item at a time; mark only the current item in_progress."
+    }
+    return result, nil
+}
+
+func (t *TodoWriteTool) GetName() string {
+    return "todo_write"
+}
+
+func (t *TodoWriteTool) GetDescription() string {
+    return "Create or update the task list for multi-step work. Pass the complete list each time; mark one item in_progress while working on it and done as soon as it is finished. The user sees the list after each update"
+}
+
+func (t *TodoWriteTool) GetInputSchema() map[string]interface{} {
+    return map[string]interface{}{
+        "type": "object",
+        "properties": map[string]interface{}{
+            "todos": map[string]interface{}{
+                "type":        "array",
+                "description": "The complete, updated task list",
+                "items": map[string]interface{}{
+                    "type": "object",
+                    "properties": map[string]interface{}{
+                        "content": map[string]interface{}{"type": "string", "description": "What needs doing"},
+                        "status":  map[string]interface{}{"type": "string", "enum": []string{TodoPending, TodoInProgress, TodoDone}},
+                    },
+                    "required": []string{"content", "status"},
+                },
+            },
+        },
+        "required": []string{"todos"},
+    }
+}
+
+// AllowedInPlanMode is true since the list lives in the agent, not on disk.
+// The tool is not read-only, so several calls never run at once.
+func (t *TodoWriteTool) AllowedInPlanMode() bool {
+    return true
+}
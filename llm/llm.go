@@ -11,55 +11,183 @@ import (
 
 // Message mirrors agent.Message for LLM requests
 type Message struct {
-    Role    string `json:"role"`
-    Content string `json:"content"`
+    Role        string       `json:"role"`
+    Content     string       `json:"content"`
+    ToolCalls   []ToolCall   `json:"tool_calls,omitempty"`   // Set on assistant messages requesting tools
+    ToolResults []ToolResult `json:"tool_results,omitempty"` // Set on tool messages answering ToolCalls
+}
+
+// ToolDefinition describes a tool the model may call
+type ToolDefinition struct {
+    Name        string                 `json:"name"`
+    Description string                 `json:"description"`
+    InputSchema map[string]interface{} `json:"input_schema"`
+}
+
+// ToolCall is a single tool invocation requested by the model
+type ToolCall struct {
+    ID    string          `json:"id"`
+    Name  string          `json:"name"`
+    Input json.RawMessage `json:"input"`
+}
+
+// ToolResult is the outcome of a ToolCall, sent back to the model
+type ToolResult struct {
+    ToolCallID string `json:"tool_call_id"`
+    Content    string `json:"content"`
+    IsError    bool   `json:"is_error,omitempty"`
+}
+
+// Usage counts the tokens consumed by one or more requests
+type Usage struct {
+    InputTokens  int `json:"input_tokens"`
+    OutputTokens int `json:"output_tokens"`
+}
+
+// Add accumulates the tokens of other into u
+func (u *Usage) Add(other Usage) {
+    u.InputTokens += other.InputTokens
+    u.OutputTokens += other.OutputTokens
+}
+
+// Response is a single model reply, possibly requesting tool calls
+type Response struct {
+    Text       string
+    ToolCalls  []ToolCall
+    StopReason string
+    Usage      Usage
+}
+
+// Provider is a model that answers a conversation, optionally calling the
+// offered tools. Client is the Anthropic implementation.
+type Provider interface {
+    Complete(messages []Message, toolDefs []ToolDefinition) (*Response, error)
 }
 
 // Client manages Anthropic Claude API interactions
 type Client struct {
-    apiKey   string
-    endpoint string
+    apiKey     string
+    endpoint   string
+    httpClient *http.Client
+}
+
+// ClientOption configures a Client created by NewClient
+type ClientOption func(*Client)
+
+// WithAPIKey sets the API key instead of reading ANTHROPIC_API_KEY
+func WithAPIKey(apiKey string) ClientOption {
+    return func(c *Client) { c.apiKey = apiKey }
 }
 
-// NewClient initializes an Anthropic Claude client
-func NewClient() *Client {
-    // Load .env for API key
-    _ = godotenv.Load()
-    apiKey := os.Getenv("ANTHROPIC_API_KEY")
+// WithEndpoint sets the messages endpoint instead of reading ANTHROPIC_ENDPOINT
+func WithEndpoint(endpoint string) ClientOption {
+    return func(c *Client) { c.endpoint = endpoint }
+}
+
+// WithHTTPClient sends requests through httpClient, e.g. one whose transport
+// records or replays traffic in tests
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+    return func(c *Client) { c.httpClient = httpClient }
+}
+
+// NewClient initializes an Anthropic Claude client. Settings not given as
+// options are read from the environment and .env.
+func NewClient(opts ...ClientOption) *Client {
+    c := &Client{}
+    for _, opt := range opts {
+        opt(c)
+    }
+    if c.apiKey == "" {
+        // Load .env for API key
+        _ = godotenv.Load()
+        c.apiKey = os.Getenv("ANTHROPIC_API_KEY")
+    }
+    if c.endpoint == "" {
+        c.endpoint = os.Getenv("ANTHROPIC_ENDPOINT")
+    }
     // Default to Anthropic API endpoint
-    endpoint := os.Getenv("ANTHROPIC_ENDPOINT")
-    if endpoint == "" {
-        endpoint = "https://api.anthropic.com/v1/messages"
+    if c.endpoint == "" {
+        c.endpoint = "https://api.anthropic.com/v1/messages"
     }
-    return &Client{
-        apiKey:   apiKey,
-        endpoint: endpoint,
+    if c.httpClient == nil {
+        c.httpClient = &http.Client{}
     }
+    return c
 }
 
-// Query sends a request to Claude and returns the response
+// Query sends a request to Claude and returns the response text
 func (c *Client) Query(messages []Message) (string, error) {
+    response, err := c.Complete(messages, nil)
+    if err != nil {
+        return "", err
+    }
+    return response.Text, nil
+}
+
+// Complete sends a request to Claude offering the given tools and returns the
+// full response, including any tool calls the model made
+func (c *Client) Complete(messages []Message, toolDefs []ToolDefinition) (*Response, error) {
     if c.apiKey == "" {
         // Mock response if no API key
-        return fmt.Sprintf("Mock Claude response to: %s", messages[len(messages)-1].Content), nil
+        return &Response{
+            Text:       fmt.Sprintf("Mock Claude response to: %s", messages[len(messages)-1].Content),
+            StopReason: "end_turn",
+        }, nil
     }
 
     // Extract system message if present
     var systemPrompt string
-    var apiMessages []map[string]string
+    var apiMessages []map[string]interface{}
     
     for _, msg := range messages {
         if msg.Role == "system" {
             systemPrompt = msg.Content
+        } else if msg.Role == "assistant" && len(msg.ToolCalls) > 0 {
+            // Assistant turns that requested tools are sent as content blocks
+            var blocks []map[string]interface{}
+            if msg.Content != "" {
+                blocks = append(blocks, map[string]interface{}{"type": "text", "text": msg.Content})
+            }
+            for _, call := range msg.ToolCalls {
+                input := call.Input
+                if len(input) == 0 {
+                    input = json.RawMessage("{}")
+                }
+                blocks = append(blocks, map[string]interface{}{
+                    "type":  "tool_use",
+                    "id":    call.ID,
+                    "name":  call.Name,
+                    "input": input,
+                })
+            }
+            apiMessages = append(apiMessages, map[string]interface{}{
+                "role":    "assistant",
+                "content": blocks,
+            })
         } else if msg.Role == "user" || msg.Role == "assistant" {
             // Keep original role for user and assistant
-            apiMessages = append(apiMessages, map[string]string{
+            apiMessages = append(apiMessages, map[string]interface{}{
                 "role":    msg.Role,
                 "content": msg.Content,
             })
+        } else if msg.Role == "tool" && len(msg.ToolResults) > 0 {
+            // Results of native tool calls go back as tool_result blocks
+            var blocks []map[string]interface{}
+            for _, result := range msg.ToolResults {
+                blocks = append(blocks, map[string]interface{}{
+                    "type":        "tool_result",
+                    "tool_use_id": result.ToolCallID,
+                    "content":     result.Content,
+                    "is_error":    result.IsError,
+                })
+            }
+            apiMessages = append(apiMessages, map[string]interface{}{
+                "role":    "user",
+                "content": blocks,
+            })
         } else if msg.Role == "tool" {
             // Convert tool messages to user messages for API compatibility
-            apiMessages = append(apiMessages, map[string]string{
+            apiMessages = append(apiMessages, map[string]interface{}{
                 "role":    "user",
                 "content": fmt.Sprintf("[Tool Output] %s", msg.Content),
             })
@@ -77,15 +205,18 @@ func (c *Client) Query(messages []Message) (string, error) {
     if systemPrompt != "" {
         payload["system"] = systemPrompt
     }
+    if len(toolDefs) > 0 {
+        payload["tools"] = toolDefs
+    }
     body, err := json.Marshal(payload)
     if err != nil {
-        return "", fmt.Errorf("failed to marshal payload: %v", err)
+        return nil, fmt.Errorf("failed to marshal payload: %v", err)
     }
 
     // Create HTTP request
     req, err := http.NewRequest("POST", c.endpoint, bytes.NewBuffer(body))
     if err != nil {
-        return "", fmt.Errorf("failed to create request: %v", err)
+        return nil, fmt.Errorf("failed to create request: %v", err)
     }
     req.Header.Set("Content-Type", "application/json")
     req.Header.Set("X-API-Key", c.apiKey)
@@ -94,10 +225,9 @@ func (c *Client) Query(messages []Message) (string, error) {
     req.Header.Set("Authorization", "Bearer "+c.apiKey)
 
     // Send request
-    client := &http.Client{}
-    resp, err := client.Do(req)
+    resp, err := c.httpClient.Do(req)
     if err != nil {
-        return "", fmt.Errorf("failed to send request: %v", err)
+        return nil, fmt.Errorf("failed to send request: %v", err)
     }
     defer resp.Body.Close()
     
@@ -105,36 +235,48 @@ func (c *Client) Query(messages []Message) (string, error) {
     if resp.StatusCode != http.StatusOK {
         var errorResponse map[string]interface{}
         if err := json.NewDecoder(resp.Body).Decode(&errorResponse); err != nil {
-            return "", fmt.Errorf("API error: status %d", resp.StatusCode)
+            return nil, fmt.Errorf("API error: status %d", resp.StatusCode)
         }
-        return "", fmt.Errorf("API error: status %d, message: %v", resp.StatusCode, errorResponse)
+        return nil, fmt.Errorf("API error: status %d, message: %v", resp.StatusCode, errorResponse)
     }
 
     // Parse response
     var result struct {
         Content []struct {
-            Type string `json:"type"`
-            Text string `json:"text"`
+            Type  string          `json:"type"`
+            Text  string          `json:"text"`
+            ID    string          `json:"id"`
+            Name  string          `json:"name"`
+            Input json.RawMessage `json:"input"`
         } `json:"content"`
+        StopReason string `json:"stop_reason"`
+        Usage      Usage  `json:"usage"`
     }
     if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-        return "", fmt.Errorf("failed to decode response: %v", err)
+        return nil, fmt.Errorf("failed to decode response: %v", err)
     }
     if len(result.Content) == 0 {
-        return "", fmt.Errorf("no response from Claude")
+        return nil, fmt.Errorf("no response from Claude")
     }
     
-    // Combine all text blocks
-    var responseText string
+    // Combine all text blocks and collect tool calls
+    response := &Response{StopReason: result.StopReason, Usage: result.Usage}
     for _, content := range result.Content {
-        if content.Type == "text" {
-            responseText += content.Text
+        switch content.Type {
+        case "text":
+            response.Text += content.Text
+        case "tool_use":
+            response.ToolCalls = append(response.ToolCalls, ToolCall{
+                ID:    content.ID,
+                Name:  content.Name,
+                Input: content.Input,
+            })
         }
     }
     
-    if responseText == "" {
-        return "", fmt.Errorf("no text content in response")
+    if response.Text == "" && len(response.ToolCalls) == 0 {
+        return nil, fmt.Errorf("no text content in response")
     }
     
-    return responseText, nil
+    return response, nil
 }
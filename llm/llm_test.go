@@ -0,0 +1,308 @@
+package llm_test
+
+import (
+    "encoding/json"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+
+    "jkneen.ai-agent/llm"
+    "jkneen.ai-agent/llm/llmtest"
+)
+
+var fileReadTool = llm.ToolDefinition{
+    Name:        "file_read",
+    Description: "Read the contents of a file",
+    InputSchema: map[string]interface{}{
+        "type":       "object",
+        "properties": map[string]interface{}{"path": map[string]interface{}{"type": "string"}},
+        "required":   []string{"path"},
+    },
+}
+
+// goMod is the tool result given for the model's file_read call
+const goMod = "module example.com/demo\n\ngo 1.22"
+
+// checkResponse checks what holds for any successful response, whatever
+// the model's wording
+func checkResponse(t *testing.T, response *llm.Response, wantStop string) {
+    t.Helper()
+    if response.StopReason != wantStop {
+        t.Errorf("StopReason = %q, want %q", response.StopReason, wantStop)
+    }
+    if response.Usage.InputTokens == 0 || response.Usage.OutputTokens == 0 {
+        t.Errorf("Usage = %+v, want token counts", response.Usage)
+    }
+}
+
+func TestClientComplete(t *testing.T) {
+    tests := []struct {
+        name     string
+        messages []llm.Message
+
+        wantText string // Expected in the reply, ignoring case
+        wantErr  string
+    }{
+        {
+            name: "text",
+            messages: []llm.Message{
+                {Role: "system", Content: "You are terse."},
+                {Role: "user", Content: "Reply with the single word: pong"},
+            },
+            wantText: "pong",
+        },
+        {
+            name: "invalid_request",
+            messages: []llm.Message{
+                {Role: "user", Content: ""},
+            },
+            wantErr: "API error: status 400",
+        },
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            client := llmtest.NewCassetteClient(t, filepath.Join("testdata", "cassettes", test.name+".json"))
+            response, err := client.Complete(test.messages, nil)
+            if test.wantErr != "" {
+                if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+                    t.Fatalf("Complete error = %v, want %q", err, test.wantErr)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("Complete: %v", err)
+            }
+            if !strings.Contains(strings.ToLower(response.Text), test.wantText) {
+                t.Errorf("Text = %q, want it to contain %q", response.Text, test.wantText)
+            }
+            if len(response.ToolCalls) != 0 {
+                t.Errorf("ToolCalls = %+v, want none", response.ToolCalls)
+            }
+            checkResponse(t, response, "end_turn")
+        })
+    }
+}
+
+// TestClientToolUse sends a tool call the model made back with its result,
+// so the recorded follow-up carries the real tool call ID
+func TestClientToolUse(t *testing.T) {
+    client := llmtest.NewCassetteClient(t, filepath.Join("testdata", "cassettes", "tool_use.json"))
+    messages := []llm.Message{
+        {Role: "system", Content: "You are a coding assistant. Read files with the file_read tool before answering questions about them."},
+        {Role: "user", Content: "What is the module path in go.mod?"},
+    }
+    tools := []llm.ToolDefinition{fileReadTool}
+
+    response, err := client.Complete(messages, tools)
+    if err != nil {
+        t.Fatalf("Complete: %v", err)
+    }
+    checkResponse(t, response, "tool_use")
+    if len(response.ToolCalls) != 1 {
+        t.Fatalf("ToolCalls = %+v, want one file_read call", response.ToolCalls)
+    }
+    call := response.ToolCalls[0]
+    var input struct {
+        Path string `json:"path"`
+    }
+    if err := json.Unmarshal(call.Input, &input); err != nil || call.Name != "file_read" || !strings.HasSuffix(input.Path, "go.mod") || call.ID == "" {
+        t.Fatalf("tool call = %+v, want file_read of go.mod with an ID", call)
+    }
+
+    messages = append(messages,
+        llm.Message{Role: "assistant", Content: response.Text, ToolCalls: response.ToolCalls},
+        llm.Message{Role: "tool", ToolResults: []llm.ToolResult{{ToolCallID: call.ID, Content: goMod}}},
+    )
+    response, err = client.Complete(messages, tools)
+    if err != nil {
+        t.Fatalf("Complete with the tool result: %v", err)
+    }
+    checkResponse(t, response, "end_turn")
+    if !strings.Contains(response.Text, "example.com/demo") {
+        t.Errorf("Text = %q, want it to name the module", response.Text)
+    }
+}
+
+// newMessagesServer serves reply to every request, passing each request's
+// headers and decoded body to inspect
+func newMessagesServer(t *testing.T, status int, reply string, inspect func(header http.Header, body map[string]interface{})) *llm.Client {
+    t.Helper()
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        var body map[string]interface{}
+        if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+            t.Errorf("request body is not JSON: %v", err)
+        }
+        if inspect != nil {
+            inspect(r.Header, body)
+        }
+        w.Header().Set("Content-Type", "application/json")
+        w.WriteHeader(status)
+        io.WriteString(w, reply)
+    }))
+    t.Cleanup(server.Close)
+    return llm.NewClient(llm.WithAPIKey("sk-ant-test-key"), llm.WithEndpoint(server.URL))
+}
+
+// jsonValue decodes JSON text for comparison with a decoded request body
+func jsonValue(t *testing.T, text string) interface{} {
+    t.Helper()
+    var value interface{}
+    if err := json.Unmarshal([]byte(text), &value); err != nil {
+        t.Fatalf("invalid JSON %s: %v", text, err)
+    }
+    return value
+}
+
+func TestClientCompleteEncodesToolBlocks(t *testing.T) {
+    var header http.Header
+    var body map[string]interface{}
+    client := newMessagesServer(t, http.StatusOK, `{
+        "id": "msg_01",
+        "type": "message",
+        "role": "assistant",
+        "content": [
+            {"type": "text", "text": "Checking both files."},
+            {"type": "tool_use", "id": "toolu_03", "name": "file_read", "input": {"path": "go.sum"}},
+            {"type": "tool_use", "id": "toolu_04", "name": "file_read", "input": {"path": "main.go"}}
+        ],
+        "stop_reason": "tool_use",
+        "usage": {"input_tokens": 412, "output_tokens": 57, "cache_read_input_tokens": 0}
+    }`, func(h http.Header, b map[string]interface{}) { header, body = h, b })
+
+    response, err := client.Complete([]llm.Message{
+        {Role: "system", Content: "You are a coding assistant."},
+        {Role: "user", Content: "What does this module depend on?"},
+        {Role: "assistant", Content: "Let me look.", ToolCalls: []llm.ToolCall{
+            {ID: "toolu_01", Name: "file_read", Input: json.RawMessage(`{"path":"go.mod"}`)},
+            {ID: "toolu_02", Name: "list_files"},
+        }},
+        {Role: "tool", ToolResults: []llm.ToolResult{
+            {ToolCallID: "toolu_01", Content: goMod},
+            {ToolCallID: "toolu_02", Content: "unknown tool list_files", IsError: true},
+        }},
+    }, []llm.ToolDefinition{fileReadTool})
+    if err != nil {
+        t.Fatalf("Complete: %v", err)
+    }
+
+    if header.Get("X-Api-Key") != "sk-ant-test-key" || header.Get("Anthropic-Version") != "2023-06-01" || header.Get("Content-Type") != "application/json" {
+        t.Errorf("request headers = %v", header)
+    }
+    if body["system"] != "You are a coding assistant." {
+        t.Errorf("system = %v", body["system"])
+    }
+    wantMessages := jsonValue(t, `[
+        {"role": "user", "content": "What does this module depend on?"},
+        {"role": "assistant", "content": [
+            {"type": "text", "text": "Let me look."},
+            {"type": "tool_use", "id": "toolu_01", "name": "file_read", "input": {"path": "go.mod"}},
+            {"type": "tool_use", "id": "toolu_02", "name": "list_files", "input": {}}
+        ]},
+        {"role": "user", "content": [
+            {"type": "tool_result", "tool_use_id": "toolu_01", "content": "module example.com/demo\n\ngo 1.22", "is_error": false},
+            {"type": "tool_result", "tool_use_id": "toolu_02", "content": "unknown tool list_files", "is_error": true}
+        ]}
+    ]`)
+    if !reflect.DeepEqual(body["messages"], wantMessages) {
+        got, _ := json.MarshalIndent(body["messages"], "", "  ")
+        t.Errorf("messages =\n%s", got)
+    }
+    wantTools := jsonValue(t, `[{
+        "name": "file_read",
+        "description": "Read the contents of a file",
+        "input_schema": {"type": "object", "properties": {"path": {"type": "string"}}, "required": ["path"]}
+    }]`)
+    if !reflect.DeepEqual(body["tools"], wantTools) {
+        t.Errorf("tools = %v", body["tools"])
+    }
+
+    if response.Text != "Checking both files." || response.StopReason != "tool_use" {
+        t.Errorf("Text %q, StopReason %q", response.Text, response.StopReason)
+    }
+    if response.Usage != (llm.Usage{InputTokens: 412, OutputTokens: 57}) {
+        t.Errorf("Usage = %+v", response.Usage)
+    }
+    if len(response.ToolCalls) != 2 {
+        t.Fatalf("ToolCalls = %+v, want two", response.ToolCalls)
+    }
+    for i, want := range []llm.ToolCall{
+        {ID: "toolu_03", Name: "file_read", Input: json.RawMessage(`{"path": "go.sum"}`)},
+        {ID: "toolu_04", Name: "file_read", Input: json.RawMessage(`{"path": "main.go"}`)},
+    } {
+        got := response.ToolCalls[i]
+        if got.ID != want.ID || got.Name != want.Name || string(got.Input) != string(want.Input) {
+            t.Errorf("ToolCalls[%d] = %+v, want %+v", i, got, want)
+        }
+    }
+}
+
+func TestClientCompleteResponses(t *testing.T) {
+    tests := []struct {
+        name   string
+        status int
+        reply  string
+
+        wantText  string
+        wantStop  string
+        wantUsage llm.Usage
+        wantErr   string
+    }{
+        {
+            name:      "text blocks are joined",
+            status:    http.StatusOK,
+            reply:     `{"content": [{"type": "text", "text": "Hello, "}, {"type": "text", "text": "world"}], "stop_reason": "end_turn", "usage": {"input_tokens": 12, "output_tokens": 4}}`,
+            wantText:  "Hello, world",
+            wantStop:  "end_turn",
+            wantUsage: llm.Usage{InputTokens: 12, OutputTokens: 4},
+        },
+        {
+            name:      "output limit",
+            status:    http.StatusOK,
+            reply:     `{"content": [{"type": "text", "text": "The first part"}], "stop_reason": "max_tokens", "usage": {"input_tokens": 30, "output_tokens": 1024}}`,
+            wantText:  "The first part",
+            wantStop:  "max_tokens",
+            wantUsage: llm.Usage{InputTokens: 30, OutputTokens: 1024},
+        },
+        {
+            name:    "API error",
+            status:  http.StatusTooManyRequests,
+            reply:   `{"type": "error", "error": {"type": "rate_limit_error", "message": "Number of requests has exceeded your rate limit"}}`,
+            wantErr: "API error: status 429, message: map[error:map[message:Number of requests has exceeded your rate limit type:rate_limit_error] type:error]",
+        },
+        {
+            name:    "error without a body",
+            status:  http.StatusBadGateway,
+            reply:   ``,
+            wantErr: "API error: status 502",
+        },
+        {
+            name:    "empty content",
+            status:  http.StatusOK,
+            reply:   `{"content": [], "stop_reason": "end_turn"}`,
+            wantErr: "no response from Claude",
+        },
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            client := newMessagesServer(t, test.status, test.reply, nil)
+            response, err := client.Complete([]llm.Message{{Role: "user", Content: "Hi"}}, nil)
+            if test.wantErr != "" {
+                if err == nil || err.Error() != test.wantErr {
+                    t.Fatalf("Complete error = %v, want %q", err, test.wantErr)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("Complete: %v", err)
+            }
+            if response.Text != test.wantText || response.StopReason != test.wantStop || response.Usage != test.wantUsage || len(response.ToolCalls) != 0 {
+                t.Errorf("response = %+v", response)
+            }
+        })
+    }
+}
@@ -0,0 +1,352 @@
+package llmtest
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "testing"
+
+    "jkneen.ai-agent/llm"
+)
+
+// RecordEnv is the environment variable that switches recorders from
+// replaying cassettes to recording them, e.g. LLMTEST_RECORD=1 go test ./...
+const RecordEnv = "LLMTEST_RECORD"
+
+// Recorder modes
+const (
+    ModeReplay = "replay" // Serve responses from the cassette; unknown requests fail
+    ModeRecord = "record" // Send requests to the real server and save them to the cassette
+)
+
+// redacted replaces secrets in recorded cassettes
+const redacted = "REDACTED"
+
+// recordedHeaders are the only headers kept in cassettes, so that new
+// credentials or tracking headers cannot leak into fixtures
+var recordedHeaders = []string{"Content-Type", "Anthropic-Version", "Request-Id"}
+
+// sensitiveHeaders hold credentials, whose values are scrubbed from recorded
+// URLs and bodies
+var sensitiveHeaders = []string{"Authorization", "X-Api-Key", "Api-Key", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// Cassette is a file of recorded HTTP interactions
+type Cassette struct {
+    Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a recorded request and the response it received
+type Interaction struct {
+    Request  RecordedRequest  `json:"request"`
+    Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is the part of a request kept in a cassette
+type RecordedRequest struct {
+    Method  string            `json:"method"`
+    URL     string            `json:"url"`
+    Headers map[string]string `json:"headers,omitempty"`
+    Body    json.RawMessage   `json:"body,omitempty"` // JSON bodies as they are, others as a JSON string
+}
+
+// RecordedResponse is the part of a response kept in a cassette
+type RecordedResponse struct {
+    Status  int               `json:"status"`
+    Headers map[string]string `json:"headers,omitempty"`
+    Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// Recorder is an http.RoundTripper that records traffic to a cassette file
+// or replays it from one. Requests are matched by method, URL path and body;
+// JSON bodies match regardless of formatting and key order. Identical
+// requests are answered by their recordings in order.
+type Recorder struct {
+    path      string
+    mode      string
+    transport http.RoundTripper
+
+    mu       sync.Mutex
+    cassette Cassette
+    used     []bool
+}
+
+// ModeFromEnv returns ModeRecord if RecordEnv is set, and ModeReplay otherwise
+func ModeFromEnv() string {
+    if os.Getenv(RecordEnv) != "" {
+        return ModeRecord
+    }
+    return ModeReplay
+}
+
+// NewRecorder creates a recorder for the cassette at path. In replay mode
+// the cassette must exist. In record mode requests are sent with transport,
+// or http.DefaultTransport if nil, and the cassette is rewritten from
+// scratch as they complete.
+func NewRecorder(path, mode string, transport http.RoundTripper) (*Recorder, error) {
+    r := &Recorder{path: path, mode: mode, transport: transport}
+    switch mode {
+    case ModeReplay:
+        data, err := os.ReadFile(path)
+        if err != nil {
+            return nil, fmt.Errorf("failed to read cassette (record it with %s=1): %w", RecordEnv, err)
+        }
+        if err := json.Unmarshal(data, &r.cassette); err != nil {
+            return nil, fmt.Errorf("invalid cassette %s: %w", path, err)
+        }
+        r.used = make([]bool, len(r.cassette.Interactions))
+    case ModeRecord:
+        if r.transport == nil {
+            r.transport = http.DefaultTransport
+        }
+    default:
+        return nil, fmt.Errorf("unknown recorder mode %q: use %s or %s", mode, ModeReplay, ModeRecord)
+    }
+    return r, nil
+}
+
+// NewCassetteClient returns a Claude client whose requests are replayed from
+// the cassette at path or, with RecordEnv set, recorded to it using the real
+// ANTHROPIC_API_KEY. A missing cassette fails the test unless recording, and
+// recording without a key skips it.
+func NewCassetteClient(tb testing.TB, path string) *llm.Client {
+    tb.Helper()
+    mode := ModeFromEnv()
+    apiKey := "test-key" // Anything non-empty; replayed requests match on their bodies only
+    if mode == ModeRecord {
+        if apiKey = os.Getenv("ANTHROPIC_API_KEY"); apiKey == "" {
+            tb.Skipf("%s is set but ANTHROPIC_API_KEY is not", RecordEnv)
+        }
+    }
+    if _, err := os.Stat(path); mode == ModeReplay && os.IsNotExist(err) {
+        tb.Fatalf("no cassette at %s; record it against the live API with %s=1", path, RecordEnv)
+    }
+    recorder, err := NewRecorder(path, mode, nil)
+    if err != nil {
+        tb.Fatal(err)
+    }
+    opts := []llm.ClientOption{llm.WithAPIKey(apiKey), llm.WithHTTPClient(recorder.Client())}
+    if mode == ModeReplay {
+        // Recordings match on the URL path, so ignore any ANTHROPIC_ENDPOINT override
+        opts = append(opts, llm.WithEndpoint("https://api.anthropic.com/v1/messages"))
+    }
+    return llm.NewClient(opts...)
+}
+
+// Client returns an HTTP client sending its requests through the recorder
+func (r *Recorder) Client() *http.Client {
+    return &http.Client{Transport: r}
+}
+
+// RoundTrip records or replays a single request
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+    var body []byte
+    if req.Body != nil {
+        var err error
+        if body, err = io.ReadAll(req.Body); err != nil {
+            return nil, err
+        }
+        req.Body.Close()
+        req.Body = io.NopCloser(bytes.NewReader(body))
+    }
+    if r.mode == ModeRecord {
+        return r.record(req, body)
+    }
+    return r.replay(req, body)
+}
+
+// replay answers req with the first unused recording that matches it
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    wantBody := canonicalBody(body)
+    for i, interaction := range r.cassette.Interactions {
+        recorded := interaction.Request
+        if r.used[i] || recorded.Method != req.Method || urlPath(recorded.URL) != req.URL.Path {
+            continue
+        }
+        if !bytes.Equal(canonicalBody(decodeBody(recorded.Body)), wantBody) {
+            continue
+        }
+        r.used[i] = true
+        return buildResponse(req, interaction.Response), nil
+    }
+    return nil, fmt.Errorf("llmtest: no unused recording in %s matches %s %s with body %s (re-record with %s=1)",
+        r.path, req.Method, req.URL.Path, truncate(string(body), 200), RecordEnv)
+}
+
+// record sends req to the real server and saves the interaction
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+    resp, err := r.transport.RoundTrip(req)
+    if err != nil {
+        return nil, err
+    }
+    responseBody, err := io.ReadAll(resp.Body)
+    resp.Body.Close()
+    if err != nil {
+        return nil, err
+    }
+    resp.Body = io.NopCloser(bytes.NewReader(responseBody))
+
+    secrets := secretValues(req.Header)
+    interaction := Interaction{
+        Request: RecordedRequest{
+            Method:  req.Method,
+            URL:     scrub(req.URL.String(), secrets),
+            Headers: recordHeaders(req.Header, secrets),
+            Body:    encodeBody(scrub(string(body), secrets)),
+        },
+        Response: RecordedResponse{
+            Status:  resp.StatusCode,
+            Headers: recordHeaders(resp.Header, secrets),
+            Body:    encodeBody(scrub(string(responseBody), secrets)),
+        },
+    }
+
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+    if err := r.save(); err != nil {
+        return nil, err
+    }
+    return resp, nil
+}
+
+// save writes the cassette; the caller holds r.mu
+func (r *Recorder) save() error {
+    data, err := json.MarshalIndent(r.cassette, "", "  ")
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+        return fmt.Errorf("failed to create cassette directory: %w", err)
+    }
+    return os.WriteFile(r.path, append(data, '\n'), 0644)
+}
+
+// buildResponse turns a recording into a response to req
+func buildResponse(req *http.Request, recorded RecordedResponse) *http.Response {
+    body := decodeBody(recorded.Body)
+    header := make(http.Header)
+    for name, value := range recorded.Headers {
+        header.Set(name, value)
+    }
+    return &http.Response{
+        Status:        fmt.Sprintf("%d %s", recorded.Status, http.StatusText(recorded.Status)),
+        StatusCode:    recorded.Status,
+        Proto:         "HTTP/1.1",
+        ProtoMajor:    1,
+        ProtoMinor:    1,
+        Header:        header,
+        Body:          io.NopCloser(bytes.NewReader(body)),
+        ContentLength: int64(len(body)),
+        Request:       req,
+    }
+}
+
+// secretValues collects the values of sensitive headers, including the
+// token part of "Bearer <token>"
+func secretValues(header http.Header) []string {
+    var secrets []string
+    for _, name := range sensitiveHeaders {
+        for _, value := range header.Values(name) {
+            if value == "" {
+                continue
+            }
+            secrets = append(secrets, value)
+            if _, token, ok := strings.Cut(value, " "); ok && token != "" {
+                secrets = append(secrets, token)
+            }
+        }
+    }
+    return secrets
+}
+
+// scrub replaces every secret in s
+func scrub(s string, secrets []string) string {
+    for _, secret := range secrets {
+        s = strings.ReplaceAll(s, secret, redacted)
+    }
+    return s
+}
+
+// recordHeaders flattens the allowed headers for a cassette
+func recordHeaders(header http.Header, secrets []string) map[string]string {
+    var recorded map[string]string
+    for _, name := range recordedHeaders {
+        values := header.Values(name)
+        if len(values) == 0 {
+            continue
+        }
+        if recorded == nil {
+            recorded = make(map[string]string)
+        }
+        recorded[name] = scrub(strings.Join(values, ", "), secrets)
+    }
+    return recorded
+}
+
+// encodeBody stores a body in a cassette: JSON as it is, for readable
+// fixtures, anything else as a JSON string
+func encodeBody(body string) json.RawMessage {
+    if body == "" {
+        return nil
+    }
+    var compact bytes.Buffer
+    if json.Compact(&compact, []byte(body)) == nil {
+        return compact.Bytes()
+    }
+    encoded, _ := json.Marshal(body)
+    return encoded
+}
+
+// decodeBody reverses encodeBody, compacting JSON that was indented when
+// the cassette was saved
+func decodeBody(body json.RawMessage) []byte {
+    var text string
+    if len(body) > 0 && body[0] == '"' && json.Unmarshal(body, &text) == nil {
+        return []byte(text)
+    }
+    var compact bytes.Buffer
+    if json.Compact(&compact, body) == nil {
+        return compact.Bytes()
+    }
+    return body
+}
+
+// canonicalBody normalizes JSON bodies so formatting and key order don't
+// affect matching
+func canonicalBody(body []byte) []byte {
+    var value interface{}
+    if json.Unmarshal(body, &value) != nil {
+        return body
+    }
+    canonical, err := json.Marshal(value)
+    if err != nil {
+        return body
+    }
+    return canonical
+}
+
+// urlPath returns the path of a recorded URL
+func urlPath(rawURL string) string {
+    parsed, err := url.Parse(rawURL)
+    if err != nil {
+        return rawURL
+    }
+    return parsed.Path
+}
+
+// truncate shortens s for error messages
+func truncate(s string, n int) string {
+    if len(s) <= n {
+        return s
+    }
+    return s[:n] + "..."
+}
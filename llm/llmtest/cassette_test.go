@@ -0,0 +1,151 @@
+package llmtest
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "sync/atomic"
+    "testing"
+)
+
+func post(t *testing.T, client *http.Client, url, body string, header map[string]string) (int, string, error) {
+    t.Helper()
+    req, err := http.NewRequest("POST", url, strings.NewReader(body))
+    if err != nil {
+        t.Fatal(err)
+    }
+    for name, value := range header {
+        req.Header.Set(name, value)
+    }
+    resp, err := client.Do(req)
+    if err != nil {
+        return 0, "", err
+    }
+    defer resp.Body.Close()
+    data, err := io.ReadAll(resp.Body)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return resp.StatusCode, string(data), nil
+}
+
+func TestRecordThenReplay(t *testing.T) {
+    var count atomic.Int32
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        body, _ := io.ReadAll(r.Body)
+        n := count.Add(1)
+        w.Header().Set("Set-Cookie", "session=cookie-secret")
+        w.Header().Set("X-Trace-Id", "trace-1")
+        w.Header().Set("Request-Id", "req_1")
+        w.Header().Set("Content-Type", "application/json")
+        fmt.Fprintf(w, `{"n":%d,"echo":%s,"key":%q}`, n, body, r.Header.Get("X-Api-Key"))
+    }))
+    defer server.Close()
+
+    path := filepath.Join(t.TempDir(), "cassettes", "echo.json")
+    recorder, err := NewRecorder(path, ModeRecord, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    header := map[string]string{"X-Api-Key": "sk-secret-key", "Authorization": "Bearer bearer-secret", "Anthropic-Version": "2023-06-01"}
+    for _, body := range []string{`{"a":1,"b":2}`, `{"a":1,"b":2}`, `not json`} {
+        if status, _, err := post(t, recorder.Client(), server.URL+"/v1/messages", body, header); err != nil || status != 200 {
+            t.Fatalf("recording %s: status %d, %v", body, status, err)
+        }
+    }
+
+    data, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, secret := range []string{"sk-secret-key", "bearer-secret", "cookie-secret"} {
+        if strings.Contains(string(data), secret) {
+            t.Errorf("cassette contains %q:\n%s", secret, data)
+        }
+    }
+    var cassette Cassette
+    if err := json.Unmarshal(data, &cassette); err != nil {
+        t.Fatal(err)
+    }
+    // Only allowed headers are recorded
+    interaction := cassette.Interactions[0]
+    if got, want := interaction.Request.Headers, map[string]string{"Anthropic-Version": "2023-06-01"}; !reflect.DeepEqual(got, want) {
+        t.Errorf("recorded request headers = %v, want %v", got, want)
+    }
+    if got, want := interaction.Response.Headers, map[string]string{"Content-Type": "application/json", "Request-Id": "req_1"}; !reflect.DeepEqual(got, want) {
+        t.Errorf("recorded response headers = %v, want %v", got, want)
+    }
+
+    replayer, err := NewRecorder(path, ModeReplay, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    client := replayer.Client()
+    tests := []struct {
+        name, body, want string
+    }{
+        // Identical requests get their recordings in order, whatever the
+        // formatting and key order of the JSON body
+        {"first of identical requests", `{"b":2, "a":1}`, `"n":1`},
+        {"second of identical requests", "{\n  \"a\": 1,\n  \"b\": 2\n}", `"n":2`},
+        {"non-JSON body", `not json`, `"echo":not json`},
+    }
+    for _, test := range tests {
+        status, body, err := post(t, client, "http://example.invalid/v1/messages", test.body, nil)
+        if err != nil || status != 200 || !strings.Contains(body, test.want) {
+            t.Errorf("%s: replayed %d %q, %v; want a body containing %s", test.name, status, body, err, test.want)
+        }
+        if !strings.Contains(body, `"key":"REDACTED"`) {
+            t.Errorf("%s: replayed body %q does not have the API key redacted", test.name, body)
+        }
+    }
+    if got := count.Load(); got != 3 {
+        t.Errorf("server received %d requests, want 3 from recording only", got)
+    }
+}
+
+func TestReplayMismatch(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "cassette.json")
+    cassette := `{"interactions":[{"request":{"method":"POST","url":"https://api.example.com/v1/messages","body":{"a":1}},"response":{"status":200,"body":{"ok":true}}}]}`
+    if err := os.WriteFile(path, []byte(cassette), 0644); err != nil {
+        t.Fatal(err)
+    }
+    recorder, err := NewRecorder(path, ModeReplay, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    client := recorder.Client()
+
+    tests := []struct {
+        name, path, body string
+        wantErr          bool
+    }{
+        {"different body", "/v1/messages", `{"a":2}`, true},
+        {"different path", "/v2/messages", `{"a":1}`, true},
+        {"match", "/v1/messages", `{"a":1}`, false},
+        {"recording already used", "/v1/messages", `{"a":1}`, true},
+    }
+    for _, test := range tests {
+        status, _, err := post(t, client, "https://api.example.com"+test.path, test.body, nil)
+        if test.wantErr {
+            if err == nil || !strings.Contains(err.Error(), RecordEnv) {
+                t.Errorf("%s: got status %d, %v; want an error suggesting %s", test.name, status, err, RecordEnv)
+            }
+        } else if err != nil || status != 200 {
+            t.Errorf("%s: got status %d, %v", test.name, status, err)
+        }
+    }
+}
+
+func TestReplayMissingCassette(t *testing.T) {
+    _, err := NewRecorder(filepath.Join(t.TempDir(), "missing.json"), ModeReplay, nil)
+    if err == nil || !strings.Contains(err.Error(), RecordEnv) {
+        t.Errorf("NewRecorder error = %v, want one suggesting %s", err, RecordEnv)
+    }
+}
@@ -0,0 +1,158 @@
+// Package llmtest provides a scripted llm.Provider for testing agent flows
+// without a network connection or API key.
+package llmtest
+
+import (
+    "encoding/json"
+    "fmt"
+    "sync"
+    "time"
+
+    "jkneen.ai-agent/llm"
+)
+
+// Step is one scripted model reply: a response, or an error to return instead
+type Step struct {
+    Text      string
+    ToolCalls []llm.ToolCall
+    Usage     llm.Usage
+    Err       error
+    Delay     time.Duration // Waited before replying
+}
+
+// Reply returns a step answering with text and, optionally, tool calls
+func Reply(text string, calls ...llm.ToolCall) Step {
+    return Step{Text: text, ToolCalls: calls}
+}
+
+// Fail returns a step that makes Complete return err
+func Fail(err error) Step {
+    return Step{Err: err}
+}
+
+// After returns a copy of s that replies only after delay
+func (s Step) After(delay time.Duration) Step {
+    s.Delay = delay
+    return s
+}
+
+// WithUsage returns a copy of s reporting the given token counts
+func (s Step) WithUsage(inputTokens, outputTokens int) Step {
+    s.Usage = llm.Usage{InputTokens: inputTokens, OutputTokens: outputTokens}
+    return s
+}
+
+// Call builds a tool call for a step. input may be a JSON string or any
+// value, which is marshalled to JSON. Calls without an ID are numbered by the
+// provider in the order it returns them: call-1, call-2 and so on.
+func Call(name string, input interface{}) llm.ToolCall {
+    var raw json.RawMessage
+    switch v := input.(type) {
+    case string:
+        raw = json.RawMessage(v)
+    case json.RawMessage:
+        raw = v
+    default:
+        data, err := json.Marshal(v)
+        if err != nil {
+            panic(fmt.Sprintf("llmtest: cannot marshal input for %s: %v", name, err))
+        }
+        raw = data
+    }
+    return llm.ToolCall{Name: name, Input: raw}
+}
+
+// Request is a call to Complete as received by the provider
+type Request struct {
+    Messages []llm.Message
+    Tools    []llm.ToolDefinition
+}
+
+// LastMessage returns the final message of the request
+func (r Request) LastMessage() llm.Message {
+    if len(r.Messages) == 0 {
+        return llm.Message{}
+    }
+    return r.Messages[len(r.Messages)-1]
+}
+
+// ToolNames lists the names of the tools offered in the request
+func (r Request) ToolNames() []string {
+    names := make([]string, len(r.Tools))
+    for i, tool := range r.Tools {
+        names[i] = tool.Name
+    }
+    return names
+}
+
+// Provider plays back a script of steps, one per call to Complete, and
+// records the requests it receives. It is safe for concurrent use.
+type Provider struct {
+    mu       sync.Mutex
+    steps    []Step
+    requests []Request
+    nextCall int
+}
+
+// NewProvider creates a provider that replies with steps in order
+func NewProvider(steps ...Step) *Provider {
+    return &Provider{steps: steps}
+}
+
+// Add appends steps to the script
+func (p *Provider) Add(steps ...Step) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    p.steps = append(p.steps, steps...)
+}
+
+// Complete records the request and plays the next step. It fails once the
+// script is exhausted.
+func (p *Provider) Complete(messages []llm.Message, toolDefs []llm.ToolDefinition) (*llm.Response, error) {
+    p.mu.Lock()
+    p.requests = append(p.requests, Request{
+        Messages: append([]llm.Message(nil), messages...),
+        Tools:    append([]llm.ToolDefinition(nil), toolDefs...),
+    })
+    if len(p.steps) == 0 {
+        count := len(p.requests)
+        p.mu.Unlock()
+        return nil, fmt.Errorf("llmtest: script exhausted at request %d", count)
+    }
+    step := p.steps[0]
+    p.steps = p.steps[1:]
+
+    calls := make([]llm.ToolCall, len(step.ToolCalls))
+    for i, call := range step.ToolCalls {
+        if call.ID == "" {
+            p.nextCall++
+            call.ID = fmt.Sprintf("call-%d", p.nextCall)
+        }
+        calls[i] = call
+    }
+    p.mu.Unlock()
+
+    time.Sleep(step.Delay)
+    if step.Err != nil {
+        return nil, step.Err
+    }
+    response := &llm.Response{Text: step.Text, ToolCalls: calls, StopReason: "end_turn", Usage: step.Usage}
+    if len(calls) > 0 {
+        response.StopReason = "tool_use"
+    }
+    return response, nil
+}
+
+// Requests returns the requests received so far
+func (p *Provider) Requests() []Request {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    return append([]Request(nil), p.requests...)
+}
+
+// Remaining reports how many scripted steps have not been played yet
+func (p *Provider) Remaining() int {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    return len(p.steps)
+}
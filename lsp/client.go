@@ -0,0 +1,678 @@
+// Package lsp is a minimal Language Server Protocol client used to give the
+// agent compiler diagnostics and code navigation from servers such as gopls.
+package lsp
+
+import (
+    "bufio"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "os/exec"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+    "unicode/utf16"
+    "unicode/utf8"
+)
+
+// DefaultRequestTimeout bounds how long a single request may take
+const DefaultRequestTimeout = 30 * time.Second
+
+// DefaultDiagnosticsTimeout bounds how long Diagnose waits for the server
+const DefaultDiagnosticsTimeout = 5 * time.Second
+
+// diagnosticsSettle is how long Diagnose waits for follow-up diagnostics,
+// since servers often publish parse errors before type errors
+const diagnosticsSettle = 300 * time.Millisecond
+
+// ErrDiagnosticsTimeout is returned by Diagnostics when the server publishes
+// nothing for the file within DiagnosticsTimeout
+var ErrDiagnosticsTimeout = errors.New("the language server published no diagnostics")
+
+// Client talks to a language server process over stdio. The server is
+// started lazily on first use.
+type Client struct {
+    Command            []string // Server command and arguments, e.g. ["gopls"]
+    RootDir            string   // Workspace root; the working directory when empty
+    Languages          []string // Language IDs the server checks, e.g. ["go"]; guessed from Command when empty
+    DiagnosticsTimeout time.Duration
+
+    // dial, if set, connects to a server in place of running Command
+    dial func() (io.WriteCloser, io.Reader, error)
+
+    startOnce sync.Once
+    startErr  error
+
+    writeMu sync.Mutex
+
+    mu          sync.Mutex
+    cmd         *exec.Cmd
+    stdin       io.WriteCloser
+    nextID      int
+    pending     map[string]chan *message
+    diagnostics map[string][]Diagnostic
+    published   map[string]int // Count of publishDiagnostics notifications per URI
+    versions    map[string]int // Open documents and their versions
+    notify      chan struct{}  // Closed and replaced whenever diagnostics arrive
+    closed      bool
+}
+
+// NewClient creates a client for the given server command
+func NewClient(command []string, rootDir string) *Client {
+    return &Client{Command: command, RootDir: rootDir}
+}
+
+// start launches and initializes the server once
+func (c *Client) start() error {
+    c.startOnce.Do(func() {
+        c.startErr = c.launch()
+    })
+    return c.startErr
+}
+
+func (c *Client) launch() error {
+    if len(c.Command) == 0 && c.dial == nil {
+        return fmt.Errorf("no language server command configured")
+    }
+    root := c.RootDir
+    if root == "" {
+        wd, err := os.Getwd()
+        if err != nil {
+            return err
+        }
+        root = wd
+    }
+
+    // Close may run concurrently, and must either see the process or stop
+    // it from being started
+    c.mu.Lock()
+    if c.closed {
+        c.mu.Unlock()
+        return fmt.Errorf("language server client is closed")
+    }
+    c.pending = make(map[string]chan *message)
+    c.diagnostics = make(map[string][]Diagnostic)
+    c.published = make(map[string]int)
+    c.versions = make(map[string]int)
+    c.notify = make(chan struct{})
+
+    stdin, stdout, err := c.open(root)
+    if err != nil {
+        c.mu.Unlock()
+        return err
+    }
+    c.stdin = stdin
+    c.mu.Unlock()
+    go c.readLoop(bufio.NewReader(stdout))
+
+    params := map[string]interface{}{
+        "processId": os.Getpid(),
+        "rootUri":   PathToURI(root),
+        "workspaceFolders": []map[string]string{
+            {"uri": PathToURI(root), "name": root},
+        },
+        "capabilities": map[string]interface{}{
+            "textDocument": map[string]interface{}{
+                "synchronization":    map[string]interface{}{"didSave": true},
+                "publishDiagnostics": map[string]interface{}{"relatedInformation": false},
+                "hover":              map[string]interface{}{"contentFormat": []string{"markdown", "plaintext"}},
+                "definition":         map[string]interface{}{},
+                "references":         map[string]interface{}{},
+            },
+            "workspace": map[string]interface{}{
+                "symbol":           map[string]interface{}{},
+                "workspaceFolders": true,
+                "configuration":    true,
+            },
+        },
+    }
+    if _, err := c.call("initialize", params); err != nil {
+        c.Close()
+        return fmt.Errorf("failed to initialize language server: %w", err)
+    }
+    return c.send(&message{Method: "initialized", Params: json.RawMessage("{}")})
+}
+
+// open starts the server and returns its stdin and stdout; the caller holds c.mu
+func (c *Client) open(root string) (io.WriteCloser, io.Reader, error) {
+    if c.dial != nil {
+        return c.dial()
+    }
+    cmd := exec.Command(c.Command[0], c.Command[1:]...)
+    cmd.Dir = root
+    stdin, err := cmd.StdinPipe()
+    if err != nil {
+        return nil, nil, err
+    }
+    stdout, err := cmd.StdoutPipe()
+    if err != nil {
+        return nil, nil, err
+    }
+    if err := cmd.Start(); err != nil {
+        return nil, nil, fmt.Errorf("failed to start language server %s: %w", c.Command[0], err)
+    }
+    c.cmd = cmd
+    return stdin, stdout, nil
+}
+
+// Close shuts the server down if it was started. The client cannot be
+// used afterwards.
+func (c *Client) Close() error {
+    c.mu.Lock()
+    if c.closed {
+        c.mu.Unlock()
+        return nil
+    }
+    c.closed = true
+    cmd, stdin := c.cmd, c.stdin
+    c.mu.Unlock()
+    if stdin == nil {
+        return nil
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+    defer cancel()
+    c.callContext(ctx, "shutdown", nil)
+    c.send(&message{Method: "exit"})
+    stdin.Close()
+    if cmd == nil {
+        return nil
+    }
+
+    done := make(chan error, 1)
+    go func() { done <- cmd.Wait() }()
+    select {
+    case <-done:
+    case <-time.After(2 * time.Second):
+        cmd.Process.Kill()
+    }
+    return nil
+}
+
+// send writes a message with LSP's Content-Length framing
+func (c *Client) send(msg *message) error {
+    msg.JSONRPC = "2.0"
+    data, err := json.Marshal(msg)
+    if err != nil {
+        return err
+    }
+    c.mu.Lock()
+    stdin := c.stdin
+    c.mu.Unlock()
+    if stdin == nil {
+        return fmt.Errorf("language server is not running")
+    }
+    c.writeMu.Lock()
+    defer c.writeMu.Unlock()
+    if _, err := fmt.Fprintf(stdin, "Content-Length: %d\r\n\r\n", len(data)); err != nil {
+        return err
+    }
+    _, err = stdin.Write(data)
+    return err
+}
+
+// call sends a request and waits for its response
+func (c *Client) call(method string, params interface{}) (json.RawMessage, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), DefaultRequestTimeout)
+    defer cancel()
+    return c.callContext(ctx, method, params)
+}
+
+func (c *Client) callContext(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+    var rawParams json.RawMessage
+    if params != nil {
+        data, err := json.Marshal(params)
+        if err != nil {
+            return nil, err
+        }
+        rawParams = data
+    }
+
+    c.mu.Lock()
+    c.nextID++
+    id := strconv.Itoa(c.nextID)
+    response := make(chan *message, 1)
+    c.pending[id] = response
+    c.mu.Unlock()
+    defer func() {
+        c.mu.Lock()
+        delete(c.pending, id)
+        c.mu.Unlock()
+    }()
+
+    rawID := json.RawMessage(id)
+    if err := c.send(&message{ID: &rawID, Method: method, Params: rawParams}); err != nil {
+        return nil, fmt.Errorf("failed to send %s: %w", method, err)
+    }
+
+    select {
+    case msg, ok := <-response:
+        if !ok {
+            return nil, fmt.Errorf("language server exited during %s", method)
+        }
+        if msg.Error != nil {
+            return nil, msg.Error
+        }
+        return msg.Result, nil
+    case <-ctx.Done():
+        return nil, fmt.Errorf("%s timed out", method)
+    }
+}
+
+// readLoop dispatches responses, notifications and server requests until
+// the server's output ends
+func (c *Client) readLoop(reader *bufio.Reader) {
+    defer func() {
+        c.mu.Lock()
+        for id, ch := range c.pending {
+            close(ch)
+            delete(c.pending, id)
+        }
+        c.mu.Unlock()
+    }()
+
+    for {
+        msg, err := readMessage(reader)
+        if err != nil {
+            return
+        }
+        switch {
+        case msg.Method != "" && msg.ID != nil:
+            c.handleServerRequest(msg)
+        case msg.Method != "":
+            c.handleNotification(msg)
+        case msg.ID != nil:
+            c.mu.Lock()
+            ch := c.pending[string(*msg.ID)]
+            c.mu.Unlock()
+            if ch != nil {
+                ch <- msg
+            }
+        }
+    }
+}
+
+// readMessage reads one Content-Length framed message
+func readMessage(reader *bufio.Reader) (*message, error) {
+    length := -1
+    for {
+        line, err := reader.ReadString('\n')
+        if err != nil {
+            return nil, err
+        }
+        line = strings.TrimSpace(line)
+        if line == "" {
+            break
+        }
+        if value, ok := strings.CutPrefix(line, "Content-Length:"); ok {
+            length, err = strconv.Atoi(strings.TrimSpace(value))
+            if err != nil {
+                return nil, fmt.Errorf("invalid Content-Length %q", value)
+            }
+        }
+    }
+    if length < 0 {
+        return nil, fmt.Errorf("message without Content-Length")
+    }
+    body := make([]byte, length)
+    if _, err := io.ReadFull(reader, body); err != nil {
+        return nil, err
+    }
+    var msg message
+    if err := json.Unmarshal(body, &msg); err != nil {
+        return nil, err
+    }
+    return &msg, nil
+}
+
+// handleServerRequest answers requests the server makes of the client
+func (c *Client) handleServerRequest(msg *message) {
+    result := json.RawMessage("null")
+    if msg.Method == "workspace/configuration" {
+        // One empty settings object per requested item
+        var params struct {
+            Items []json.RawMessage `json:"items"`
+        }
+        json.Unmarshal(msg.Params, &params)
+        items := make([]map[string]interface{}, len(params.Items))
+        for i := range items {
+            items[i] = map[string]interface{}{}
+        }
+        result, _ = json.Marshal(items)
+    }
+    c.send(&message{ID: msg.ID, Result: result})
+}
+
+// handleNotification records diagnostics published by the server
+func (c *Client) handleNotification(msg *message) {
+    if msg.Method != "textDocument/publishDiagnostics" {
+        return
+    }
+    var params struct {
+        URI         string       `json:"uri"`
+        Diagnostics []Diagnostic `json:"diagnostics"`
+    }
+    if err := json.Unmarshal(msg.Params, &params); err != nil {
+        return
+    }
+    c.mu.Lock()
+    c.diagnostics[params.URI] = params.Diagnostics
+    c.published[params.URI]++
+    close(c.notify)
+    c.notify = make(chan struct{})
+    c.mu.Unlock()
+}
+
+// syncDocument sends the file's current content to the server, opening it
+// on first use
+func (c *Client) syncDocument(path string) (string, error) {
+    content, err := os.ReadFile(path)
+    if err != nil {
+        return "", err
+    }
+    uri := PathToURI(path)
+
+    c.mu.Lock()
+    version, open := c.versions[uri]
+    version++
+    c.versions[uri] = version
+    c.mu.Unlock()
+
+    if !open {
+        params, _ := json.Marshal(map[string]interface{}{
+            "textDocument": map[string]interface{}{
+                "uri":        uri,
+                "languageId": languageID(path),
+                "version":    version,
+                "text":       string(content),
+            },
+        })
+        return uri, c.send(&message{Method: "textDocument/didOpen", Params: params})
+    }
+
+    params, _ := json.Marshal(map[string]interface{}{
+        "textDocument":   map[string]interface{}{"uri": uri, "version": version},
+        "contentChanges": []map[string]string{{"text": string(content)}},
+    })
+    if err := c.send(&message{Method: "textDocument/didChange", Params: params}); err != nil {
+        return "", err
+    }
+    params, _ = json.Marshal(map[string]interface{}{"textDocument": map[string]string{"uri": uri}})
+    return uri, c.send(&message{Method: "textDocument/didSave", Params: params})
+}
+
+// Handles reports whether the server checks files like path: those whose
+// language is in Languages, or in the languages known for the server command
+// when Languages is empty. Servers that aren't known are assumed to check
+// every file.
+func (c *Client) Handles(path string) bool {
+    languages := c.Languages
+    if len(languages) == 0 {
+        if languages = serverLanguages(c.Command); languages == nil {
+            return true
+        }
+    }
+    language := languageID(path)
+    for _, handled := range languages {
+        if handled == language {
+            return true
+        }
+    }
+    return false
+}
+
+// Diagnostics syncs the file with the server and returns the diagnostics it
+// publishes for it. It returns ErrDiagnosticsTimeout if nothing is published
+// within DiagnosticsTimeout.
+func (c *Client) Diagnostics(path string) ([]Diagnostic, error) {
+    if err := c.start(); err != nil {
+        return nil, err
+    }
+
+    uri := PathToURI(path)
+    c.mu.Lock()
+    before := c.published[uri]
+    c.mu.Unlock()
+
+    if _, err := c.syncDocument(path); err != nil {
+        return nil, err
+    }
+
+    timeout := c.DiagnosticsTimeout
+    if timeout <= 0 {
+        timeout = DefaultDiagnosticsTimeout
+    }
+    deadline := time.After(timeout)
+    var settle <-chan time.Time
+    for {
+        c.mu.Lock()
+        count := c.published[uri]
+        diagnostics := c.diagnostics[uri]
+        notify := c.notify
+        c.mu.Unlock()
+
+        if count > before && settle == nil {
+            settle = time.After(diagnosticsSettle)
+        }
+        select {
+        case <-notify:
+            if settle != nil {
+                // Restart the settle period on each further update
+                settle = time.After(diagnosticsSettle)
+            }
+        case <-settle:
+            return diagnostics, nil
+        case <-deadline:
+            if settle == nil {
+                return nil, fmt.Errorf("%w after %s", ErrDiagnosticsTimeout, timeout)
+            }
+            return diagnostics, nil
+        }
+    }
+}
+
+// Diagnose returns a human-readable summary of the file's diagnostics, or
+// "" when there are none
+func (c *Client) Diagnose(path string) (string, error) {
+    diagnostics, err := c.Diagnostics(path)
+    if err != nil {
+        return "", err
+    }
+    return FormatDiagnostics(path, diagnostics), nil
+}
+
+// FormatDiagnostics renders diagnostics as file:line:col lines, errors first
+func FormatDiagnostics(path string, diagnostics []Diagnostic) string {
+    if len(diagnostics) == 0 {
+        return ""
+    }
+    sorted := append([]Diagnostic(nil), diagnostics...)
+    sort.SliceStable(sorted, func(i, j int) bool {
+        si, sj := sorted[i].Severity, sorted[j].Severity
+        if si == 0 {
+            si = SeverityError
+        }
+        if sj == 0 {
+            sj = SeverityError
+        }
+        if si != sj {
+            return si < sj
+        }
+        return sorted[i].Range.Start.Line < sorted[j].Range.Start.Line
+    })
+    var sb strings.Builder
+    for _, diagnostic := range sorted {
+        source := ""
+        if diagnostic.Source != "" {
+            source = " (" + diagnostic.Source + ")"
+        }
+        sb.WriteString(fmt.Sprintf("%s:%d:%d: %s: %s%s\n", path, diagnostic.Range.Start.Line+1, diagnostic.Range.Start.Character+1,
+            severityName(diagnostic.Severity), diagnostic.Message, source))
+    }
+    return sb.String()
+}
+
+// textPosition converts a 1-based line and rune column in path to an LSP position
+func textPosition(path string, line, column int) (Position, error) {
+    content, err := os.ReadFile(path)
+    if err != nil {
+        return Position{}, err
+    }
+    lines := strings.Split(string(content), "\n")
+    if line < 1 || line > len(lines) {
+        return Position{}, fmt.Errorf("line %d out of range: %s has %d lines", line, path, len(lines))
+    }
+    text := lines[line-1]
+    if column < 1 {
+        column = 1
+    }
+    // LSP counts UTF-16 code units
+    character := 0
+    for i, r := range text {
+        if utf8.RuneCountInString(text[:i]) >= column-1 {
+            break
+        }
+        character += len(utf16.Encode([]rune{r}))
+    }
+    return Position{Line: line - 1, Character: character}, nil
+}
+
+// positionParams builds the common textDocument/position request parameters
+func (c *Client) positionParams(path string, line, column int) (map[string]interface{}, error) {
+    if err := c.start(); err != nil {
+        return nil, err
+    }
+    position, err := textPosition(path, line, column)
+    if err != nil {
+        return nil, err
+    }
+    uri, err := c.syncDocument(path)
+    if err != nil {
+        return nil, err
+    }
+    return map[string]interface{}{
+        "textDocument": map[string]string{"uri": uri},
+        "position":     position,
+    }, nil
+}
+
+// Definition returns the locations defining the symbol at a 1-based line and column
+func (c *Client) Definition(path string, line, column int) ([]Location, error) {
+    params, err := c.positionParams(path, line, column)
+    if err != nil {
+        return nil, err
+    }
+    result, err := c.call("textDocument/definition", params)
+    if err != nil {
+        return nil, err
+    }
+    return decodeLocations(result)
+}
+
+// References returns the locations referring to the symbol at a 1-based line and column
+func (c *Client) References(path string, line, column int) ([]Location, error) {
+    params, err := c.positionParams(path, line, column)
+    if err != nil {
+        return nil, err
+    }
+    params["context"] = map[string]bool{"includeDeclaration": false}
+    result, err := c.call("textDocument/references", params)
+    if err != nil {
+        return nil, err
+    }
+    return decodeLocations(result)
+}
+
+// Hover returns the hover text for the symbol at a 1-based line and column
+func (c *Client) Hover(path string, line, column int) (string, error) {
+    params, err := c.positionParams(path, line, column)
+    if err != nil {
+        return "", err
+    }
+    result, err := c.call("textDocument/hover", params)
+    if err != nil {
+        return "", err
+    }
+    var hover struct {
+        Contents json.RawMessage `json:"contents"`
+    }
+    if string(result) == "null" || json.Unmarshal(result, &hover) != nil {
+        return "", nil
+    }
+    return decodeMarkup(hover.Contents), nil
+}
+
+// WorkspaceSymbols searches the workspace for symbols matching query
+func (c *Client) WorkspaceSymbols(query string) ([]SymbolInformation, error) {
+    if err := c.start(); err != nil {
+        return nil, err
+    }
+    result, err := c.call("workspace/symbol", map[string]string{"query": query})
+    if err != nil {
+        return nil, err
+    }
+    var symbols []SymbolInformation
+    if string(result) != "null" {
+        if err := json.Unmarshal(result, &symbols); err != nil {
+            return nil, fmt.Errorf("unexpected workspace/symbol result: %w", err)
+        }
+    }
+    return symbols, nil
+}
+
+// decodeLocations accepts a Location, a list of Locations or LocationLinks
+func decodeLocations(result json.RawMessage) ([]Location, error) {
+    if string(result) == "null" {
+        return nil, nil
+    }
+    var single Location
+    if err := json.Unmarshal(result, &single); err == nil && single.URI != "" {
+        return []Location{single}, nil
+    }
+    var raw []struct {
+        Location
+        TargetURI            string `json:"targetUri"`
+        TargetSelectionRange Range  `json:"targetSelectionRange"`
+    }
+    if err := json.Unmarshal(result, &raw); err != nil {
+        return nil, fmt.Errorf("unexpected location result: %w", err)
+    }
+    locations := make([]Location, 0, len(raw))
+    for _, item := range raw {
+        if item.TargetURI != "" {
+            locations = append(locations, Location{URI: item.TargetURI, Range: item.TargetSelectionRange})
+        } else {
+            locations = append(locations, item.Location)
+        }
+    }
+    return locations, nil
+}
+
+// decodeMarkup flattens the several shapes hover contents may take
+func decodeMarkup(contents json.RawMessage) string {
+    var text string
+    if json.Unmarshal(contents, &text) == nil {
+        return text
+    }
+    var markup struct {
+        Kind  string `json:"kind"`
+        Value string `json:"value"`
+    }
+    if json.Unmarshal(contents, &markup) == nil && markup.Value != "" {
+        return markup.Value
+    }
+    var list []json.RawMessage
+    if json.Unmarshal(contents, &list) == nil {
+        var parts []string
+        for _, item := range list {
+            parts = append(parts, decodeMarkup(item))
+        }
+        return strings.Join(parts, "\n\n")
+    }
+    return ""
+}
@@ -0,0 +1,256 @@
+package lsp
+
+import (
+    "bufio"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "sync"
+    "testing"
+    "time"
+)
+
+// fakeServer is a language server speaking JSON-RPC over pipes. It answers
+// initialize, definition and shutdown requests, records the messages it
+// receives and publishes diagnostics for each document the client syncs.
+type fakeServer struct {
+    in  *bufio.Reader
+    out io.WriteCloser
+
+    // publish returns the diagnostics to publish for a document's new text;
+    // when it is nil nothing is published
+    publish func(text string) []Diagnostic
+
+    mu       sync.Mutex
+    received []*message
+}
+
+// newFakeClient returns a client connected to a new fakeServer
+func newFakeClient(t *testing.T, publish func(text string) []Diagnostic) (*Client, *fakeServer) {
+    t.Helper()
+    serverIn, clientOut := io.Pipe()
+    clientIn, serverOut := io.Pipe()
+    server := &fakeServer{in: bufio.NewReader(serverIn), out: serverOut, publish: publish}
+    client := &Client{
+        RootDir: t.TempDir(),
+        dial: func() (io.WriteCloser, io.Reader, error) {
+            return clientOut, clientIn, nil
+        },
+    }
+    go server.serve()
+    t.Cleanup(func() { client.Close() })
+    return client, server
+}
+
+func (s *fakeServer) serve() {
+    defer s.out.Close()
+    for {
+        msg, err := readMessage(s.in)
+        if err != nil {
+            return
+        }
+        s.mu.Lock()
+        s.received = append(s.received, msg)
+        s.mu.Unlock()
+
+        var params struct {
+            TextDocument struct {
+                URI  string `json:"uri"`
+                Text string `json:"text"`
+            } `json:"textDocument"`
+            ContentChanges []struct {
+                Text string `json:"text"`
+            } `json:"contentChanges"`
+        }
+        json.Unmarshal(msg.Params, &params)
+
+        switch msg.Method {
+        case "initialize":
+            s.reply(msg, map[string]interface{}{"capabilities": map[string]interface{}{"definitionProvider": true}})
+        case "shutdown":
+            s.reply(msg, nil)
+        case "textDocument/definition":
+            s.reply(msg, []Location{{URI: params.TextDocument.URI, Range: Range{Start: Position{Line: 2, Character: 5}}}})
+        case "textDocument/didOpen":
+            s.diagnose(params.TextDocument.URI, params.TextDocument.Text)
+        case "textDocument/didChange":
+            s.diagnose(params.TextDocument.URI, params.ContentChanges[0].Text)
+        }
+    }
+}
+
+// reply answers a request
+func (s *fakeServer) reply(request *message, result interface{}) {
+    data, _ := json.Marshal(result)
+    s.write(&message{ID: request.ID, Result: data})
+}
+
+// diagnose publishes diagnostics for a synced document
+func (s *fakeServer) diagnose(uri, text string) {
+    if s.publish == nil {
+        return
+    }
+    diagnostics := s.publish(text)
+    if diagnostics == nil {
+        diagnostics = []Diagnostic{}
+    }
+    params, _ := json.Marshal(map[string]interface{}{"uri": uri, "diagnostics": diagnostics})
+    s.write(&message{Method: "textDocument/publishDiagnostics", Params: params})
+}
+
+func (s *fakeServer) write(msg *message) {
+    msg.JSONRPC = "2.0"
+    data, _ := json.Marshal(msg)
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n", len(data))
+    s.out.Write(data)
+}
+
+// messages returns the messages received so far
+func (s *fakeServer) messages() []*message {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return append([]*message(nil), s.received...)
+}
+
+// writeFile creates path with content
+func writeFile(t *testing.T, path, content string) {
+    t.Helper()
+    if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func TestClientDiagnostics(t *testing.T) {
+    // The server reports problems until the undefined name is removed
+    client, server := newFakeClient(t, func(text string) []Diagnostic {
+        if text != "package main\n\nvar x = undefined\n" {
+            return nil
+        }
+        return []Diagnostic{
+            {Range: Range{Start: Position{Line: 2, Character: 8}}, Severity: SeverityWarning, Message: "unused", Source: "vet"},
+            {Range: Range{Start: Position{Line: 2, Character: 8}}, Severity: SeverityError, Message: "undefined: undefined", Source: "compiler"},
+        }
+    })
+    path := filepath.Join(client.RootDir, "main.go")
+
+    writeFile(t, path, "package main\n\nvar x = undefined\n")
+    report, err := client.Diagnose(path)
+    if err != nil {
+        t.Fatalf("Diagnose: %v", err)
+    }
+    want := path + ":3:9: error: undefined: undefined (compiler)\n" + path + ":3:9: warning: unused (vet)\n"
+    if report != want {
+        t.Errorf("Diagnose = %q, want %q", report, want)
+    }
+
+    // An empty publication clears the diagnostics
+    writeFile(t, path, "package main\n")
+    if report, err := client.Diagnose(path); err != nil || report != "" {
+        t.Errorf("Diagnose after the fix = %q, %v; want no diagnostics", report, err)
+    }
+
+    var methods []string
+    for _, msg := range server.messages() {
+        methods = append(methods, msg.Method)
+    }
+    wantMethods := []string{"initialize", "initialized", "textDocument/didOpen", "textDocument/didChange", "textDocument/didSave"}
+    if len(methods) != len(wantMethods) {
+        t.Fatalf("server received %v, want %v", methods, wantMethods)
+    }
+    for i := range wantMethods {
+        if methods[i] != wantMethods[i] {
+            t.Fatalf("server received %v, want %v", methods, wantMethods)
+        }
+    }
+
+    messages := server.messages()
+    var initialize struct {
+        RootURI string `json:"rootUri"`
+    }
+    json.Unmarshal(messages[0].Params, &initialize)
+    if initialize.RootURI != PathToURI(client.RootDir) || messages[0].ID == nil {
+        t.Errorf("initialize request = %s", messages[0].Params)
+    }
+    if messages[1].ID != nil {
+        t.Error("initialized was sent as a request, want a notification")
+    }
+
+    var open struct {
+        TextDocument struct {
+            URI        string `json:"uri"`
+            LanguageID string `json:"languageId"`
+            Version    int    `json:"version"`
+        } `json:"textDocument"`
+    }
+    json.Unmarshal(messages[2].Params, &open)
+    if open.TextDocument.URI != PathToURI(path) || open.TextDocument.LanguageID != "go" || open.TextDocument.Version != 1 {
+        t.Errorf("didOpen = %s", messages[2].Params)
+    }
+    var change struct {
+        TextDocument struct {
+            Version int `json:"version"`
+        } `json:"textDocument"`
+        ContentChanges []struct {
+            Text string `json:"text"`
+        } `json:"contentChanges"`
+    }
+    json.Unmarshal(messages[3].Params, &change)
+    if change.TextDocument.Version != 2 || len(change.ContentChanges) != 1 || change.ContentChanges[0].Text != "package main\n" {
+        t.Errorf("didChange = %s", messages[3].Params)
+    }
+}
+
+func TestClientDiagnosticsTimeout(t *testing.T) {
+    client, _ := newFakeClient(t, nil)
+    client.DiagnosticsTimeout = 100 * time.Millisecond
+    path := filepath.Join(client.RootDir, "main.go")
+    writeFile(t, path, "package main\n")
+
+    report, err := client.Diagnose(path)
+    if !errors.Is(err, ErrDiagnosticsTimeout) {
+        t.Errorf("Diagnose with a silent server = %q, %v; want ErrDiagnosticsTimeout", report, err)
+    }
+}
+
+func TestClientDefinition(t *testing.T) {
+    client, _ := newFakeClient(t, nil)
+    path := filepath.Join(client.RootDir, "main.go")
+    writeFile(t, path, "package main\n\nfunc main() {}\n")
+
+    locations, err := client.Definition(path, 3, 6)
+    if err != nil {
+        t.Fatalf("Definition: %v", err)
+    }
+    if len(locations) != 1 || URIToPath(locations[0].URI) != path || locations[0].Range.Start.Line != 2 {
+        t.Errorf("Definition = %+v", locations)
+    }
+}
+
+func TestClientHandles(t *testing.T) {
+    tests := []struct {
+        command   []string
+        languages []string
+        path      string
+        want      bool
+    }{
+        {command: []string{"gopls"}, path: "main.go", want: true},
+        {command: []string{"/usr/local/bin/gopls", "serve"}, path: "README.md", want: false},
+        {command: []string{"typescript-language-server", "--stdio"}, path: "app.tsx", want: true},
+        {command: []string{"rust-analyzer"}, path: "main.go", want: false},
+        {command: []string{"my-server"}, path: "notes.txt", want: true},
+        {command: []string{"my-server"}, languages: []string{"zig"}, path: "build.zig", want: true},
+        {command: []string{"my-server"}, languages: []string{"zig"}, path: "main.go", want: false},
+        {command: []string{"gopls"}, languages: []string{"go", "python"}, path: "setup.py", want: true},
+    }
+    for _, test := range tests {
+        client := &Client{Command: test.command, Languages: test.languages}
+        if got := client.Handles(test.path); got != test.want {
+            t.Errorf("%v with languages %v handles %s = %v, want %v", test.command, test.languages, test.path, got, test.want)
+        }
+    }
+}
@@ -0,0 +1,175 @@
+package lsp
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/url"
+    "path/filepath"
+    "strings"
+)
+
+// Position is a zero-based line and UTF-16 character offset, as defined by LSP
+type Position struct {
+    Line      int `json:"line"`
+    Character int `json:"character"`
+}
+
+// Range is a span between two positions
+type Range struct {
+    Start Position `json:"start"`
+    End   Position `json:"end"`
+}
+
+// Location is a range within a document
+type Location struct {
+    URI   string `json:"uri"`
+    Range Range  `json:"range"`
+}
+
+// Diagnostic is a compiler error, warning or hint reported by the server
+type Diagnostic struct {
+    Range    Range  `json:"range"`
+    Severity int    `json:"severity,omitempty"`
+    Source   string `json:"source,omitempty"`
+    Message  string `json:"message"`
+}
+
+// SymbolInformation is a workspace symbol search result
+type SymbolInformation struct {
+    Name          string   `json:"name"`
+    Kind          int      `json:"kind"`
+    Location      Location `json:"location"`
+    ContainerName string   `json:"containerName,omitempty"`
+}
+
+// Diagnostic severities
+const (
+    SeverityError       = 1
+    SeverityWarning     = 2
+    SeverityInformation = 3
+    SeverityHint        = 4
+)
+
+// message is a JSON-RPC 2.0 request, response or notification
+type message struct {
+    JSONRPC string           `json:"jsonrpc"`
+    ID      *json.RawMessage `json:"id,omitempty"`
+    Method  string           `json:"method,omitempty"`
+    Params  json.RawMessage  `json:"params,omitempty"`
+    Result  json.RawMessage  `json:"result,omitempty"`
+    Error   *responseError   `json:"error,omitempty"`
+}
+
+// responseError is a JSON-RPC error object
+type responseError struct {
+    Code    int    `json:"code"`
+    Message string `json:"message"`
+}
+
+func (e *responseError) Error() string {
+    return fmt.Sprintf("language server error %d: %s", e.Code, e.Message)
+}
+
+// PathToURI converts a file path to a file:// URI
+func PathToURI(path string) string {
+    absPath, err := filepath.Abs(path)
+    if err != nil {
+        absPath = path
+    }
+    return (&url.URL{Scheme: "file", Path: filepath.ToSlash(absPath)}).String()
+}
+
+// URIToPath converts a file:// URI to a file path
+func URIToPath(uri string) string {
+    parsed, err := url.Parse(uri)
+    if err != nil || parsed.Scheme != "file" {
+        return strings.TrimPrefix(uri, "file://")
+    }
+    return filepath.FromSlash(parsed.Path)
+}
+
+// severityName names a diagnostic severity
+func severityName(severity int) string {
+    switch severity {
+    case SeverityError:
+        return "error"
+    case SeverityWarning:
+        return "warning"
+    case SeverityInformation:
+        return "info"
+    case SeverityHint:
+        return "hint"
+    default:
+        return "error"
+    }
+}
+
+// SymbolKindName names the common LSP symbol kinds
+func SymbolKindName(kind int) string {
+    names := map[int]string{
+        1: "file", 2: "module", 3: "namespace", 4: "package", 5: "class", 6: "method",
+        7: "property", 8: "field", 9: "constructor", 10: "enum", 11: "interface",
+        12: "function", 13: "variable", 14: "constant", 22: "enum member", 23: "struct",
+        26: "type parameter",
+    }
+    if name, ok := names[kind]; ok {
+        return name
+    }
+    return "symbol"
+}
+
+// serverLanguages returns the language IDs checked by well-known servers,
+// or nil when the command is not one of them
+func serverLanguages(command []string) []string {
+    if len(command) == 0 {
+        return nil
+    }
+    switch strings.TrimSuffix(filepath.Base(command[0]), ".exe") {
+    case "gopls":
+        return []string{"go"}
+    case "typescript-language-server", "vtsls":
+        return []string{"typescript", "typescriptreact", "javascript", "javascriptreact"}
+    case "pyright-langserver", "basedpyright-langserver", "pylsp", "jedi-language-server":
+        return []string{"python"}
+    case "rust-analyzer":
+        return []string{"rust"}
+    case "clangd", "ccls":
+        return []string{"c", "cpp"}
+    case "jdtls":
+        return []string{"java"}
+    case "solargraph", "ruby-lsp":
+        return []string{"ruby"}
+    default:
+        return nil
+    }
+}
+
+// languageID guesses the LSP language identifier from a file extension
+func languageID(path string) string {
+    switch strings.ToLower(filepath.Ext(path)) {
+    case ".go":
+        return "go"
+    case ".ts":
+        return "typescript"
+    case ".tsx":
+        return "typescriptreact"
+    case ".js":
+        return "javascript"
+    case ".jsx":
+        return "javascriptreact"
+    case ".py":
+        return "python"
+    case ".rs":
+        return "rust"
+    case ".c", ".h":
+        return "c"
+    case ".cc", ".cpp", ".hpp":
+        return "cpp"
+    case ".java":
+        return "java"
+    case ".rb":
+        return "ruby"
+    default:
+        return strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+    }
+}
@@ -4,7 +4,9 @@ import (
     "bufio"
     "fmt"
     "os"
+    "strconv"
     "strings"
+    "time"
     
     "jkneen.ai-agent/agent"
 )
@@ -16,11 +18,27 @@ func main() {
         fmt.Fprintf(os.Stderr, "Failed to initialize agent: %v\n", err)
         os.Exit(1)
     }
+    for _, warning := range ag.Warnings() {
+        fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+    }
+    // Show tool activity and the task list as the agent works
+    ag.Subscribe(printEvent)
+    defer ag.Close()       // Stop the language server, if one was started
     defer ag.SaveContext() // Save context on exit
 
     fmt.Println("Welcome to the AI Agent (powered by Claude)! Type 'exit' to quitPo.")
     scanner := bufio.NewScanner(os.Stdin)
 
+    // Ask before running tool calls that need approval, such as git commits
+    ag.SetPermissionPolicy(agent.PermissionFunc(func(request agent.PermissionRequest) bool {
+        fmt.Printf("Allow %s? [y/N] ", request.Summary)
+        if !scanner.Scan() {
+            return false
+        }
+        answer := strings.ToLower(strings.TrimSpace(scanner.Text()))
+        return answer == "y" || answer == "yes"
+    }))
+
     for {
         fmt.Print("> ")
         if !scanner.Scan() {
@@ -33,17 +51,161 @@ func main() {
         if input == "" {
             continue
         }
+        if strings.HasPrefix(input, "/") {
+            handleCommand(ag, input)
+            continue
+        }
 
         // Process the input
-        response, err := ag.Process(input)
+        respond(ag, input)
+    }
+
+    if err := scanner.Err(); err != nil {
+        fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
+    }
+}
+
+// handleCommand runs a REPL slash command
+func handleCommand(ag *agent.Agent, input string) {
+    fields := strings.Fields(input)
+    switch fields[0] {
+    case "/undo":
+        checkpoint, err := ag.Undo()
         if err != nil {
             fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-            continue
+            return
         }
-        fmt.Println(response)
+        fmt.Printf("Reverted %d file(s) changed by turn %d\n", len(checkpoint.Files), checkpoint.Turn)
+
+    case "/checkpoints":
+        checkpoints := ag.Checkpoints()
+        if len(checkpoints) == 0 {
+            fmt.Println("No checkpoints yet")
+            return
+        }
+        for _, checkpoint := range checkpoints {
+            fmt.Printf("%d  %s  turn %d  %d file(s)  %q\n", checkpoint.ID, checkpoint.Created.Format("15:04:05"),
+                checkpoint.Turn, len(checkpoint.Files), truncate(checkpoint.Prompt, 60))
+            for _, file := range checkpoint.Files {
+                fmt.Printf("      %s\n", file.Path)
+            }
+        }
+
+    case "/restore":
+        if len(fields) < 2 {
+            fmt.Fprintln(os.Stderr, "Usage: /restore <id> [--conversation]")
+            return
+        }
+        id, err := strconv.Atoi(fields[1])
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Invalid checkpoint id: %s\n", fields[1])
+            return
+        }
+        rewind := len(fields) > 2 && fields[2] == "--conversation"
+        checkpoint, err := ag.Restore(id, rewind)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+            return
+        }
+        fmt.Printf("Restored files to their state before checkpoint %d (turn %d)\n", checkpoint.ID, checkpoint.Turn)
+        if rewind {
+            fmt.Println("Conversation rewound to before that turn")
+        }
+
+    case "/todos":
+        fmt.Println(agent.FormatTodos(ag.Todos()))
+
+    case "/plan":
+        task := strings.TrimSpace(strings.TrimPrefix(input, fields[0]))
+        if task == "off" {
+            ag.ExitPlanMode()
+            fmt.Println("Plan mode off; all tools are enabled")
+            return
+        }
+        ag.EnterPlanMode()
+        fmt.Println("Plan mode on: only read-only tools until you /approve a plan (/plan off to leave)")
+        if task != "" {
+            respond(ag, task)
+        }
+
+    case "/approve":
+        if _, err := ag.ApprovePlan(); err != nil {
+            fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+            return
+        }
+        fmt.Println("Plan approved; all tools are enabled")
+        respond(ag, "The plan is approved. Implement it now.")
+
+    case "/reject":
+        if err := ag.RejectPlan(); err != nil {
+            fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+            return
+        }
+        feedback := strings.TrimSpace(strings.TrimPrefix(input, fields[0]))
+        if feedback == "" {
+            feedback = "Please revise it."
+        }
+        respond(ag, "I don't approve the plan. "+feedback)
+
+    default:
+        fmt.Fprintf(os.Stderr, "Unknown command: %s (available: /undo, /checkpoints, /todos, /restore <id> [--conversation], /plan [task|off], /approve, /reject [feedback])\n", fields[0])
     }
+}
 
-    if err := scanner.Err(); err != nil {
-        fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
+// respond processes input as a user message; the reply is printed by printEvent
+func respond(ag *agent.Agent, input string) {
+    if _, err := ag.Process(input); err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        return
+    }
+    showPendingPlan(ag)
+}
+
+// printEvent shows the agent's progress and replies
+func printEvent(event agent.Event) {
+    switch e := event.(type) {
+    case agent.ToolCallEvent:
+        fmt.Printf("[%s] %s\n", e.Name, truncate(strings.Join(strings.Fields(e.Input), " "), 100))
+    case agent.ToolResultEvent:
+        if e.IsError {
+            fmt.Printf("[%s] failed: %s\n", e.Name, truncate(e.Output, 200))
+        }
+    case agent.TodosUpdatedEvent:
+        fmt.Printf("\nTodos:\n%s\n\n", agent.FormatTodos(e.Todos))
+    case agent.SubAgentEvent:
+        // Only a sub-agent's tool calls are shown; its report is the task's result
+        switch inner := e.Event.(type) {
+        case agent.ToolCallEvent:
+            fmt.Printf("  [task > %s] %s\n", inner.Name, truncate(strings.Join(strings.Fields(inner.Input), " "), 100))
+        case agent.ToolResultEvent:
+            if inner.IsError {
+                fmt.Printf("  [task > %s] failed: %s\n", inner.Name, truncate(inner.Output, 200))
+            }
+        }
+    case agent.TurnFinishedEvent:
+        if e.Response != "" {
+            fmt.Println(e.Response)
+        }
+        if e.Usage.InputTokens > 0 || e.Usage.OutputTokens > 0 {
+            fmt.Printf("(%d input, %d output tokens in %s)\n", e.Usage.InputTokens, e.Usage.OutputTokens, e.Duration.Round(100*time.Millisecond))
+        }
+    }
+}
+
+// showPendingPlan prints a plan submitted in plan mode for the user to review
+func showPendingPlan(ag *agent.Agent) {
+    plan := ag.PendingPlan()
+    if plan == nil {
+        return
+    }
+    fmt.Printf("\nProposed plan:\n%s\n\nType /approve to let the agent make changes, or /reject <feedback> to ask for a revision.\n", plan)
+}
+
+// truncate shortens s to at most n runes for display
+func truncate(s string, n int) string {
+    runes := []rune(s)
+    if len(runes) <= n {
+        return s
     }
+    return string(runes[:n]) + "..."
 }
@@ -0,0 +1,210 @@
+package tools
+
+import (
+    "crypto/sha256"
+    "fmt"
+    "hash"
+    "os"
+    "path/filepath"
+    "sync"
+    "time"
+)
+
+// WriteFileAtomic writes data to path by writing a temporary file in the same
+// directory and renaming it into place, so readers never observe a partially
+// written file. The file ends up with the given permissions. If path is a
+// symlink, its target is written and the link is kept.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+    path, err := resolveSymlinks(path)
+    if err != nil {
+        return err
+    }
+    dir := filepath.Dir(path)
+    tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+    if err != nil {
+        return err
+    }
+    tmpName := tmp.Name()
+
+    // Remove the temporary file on any failure before the rename
+    success := false
+    defer func() {
+        if !success {
+            tmp.Close()
+            os.Remove(tmpName)
+        }
+    }()
+
+    if _, err := tmp.Write(data); err != nil {
+        return err
+    }
+    if err := tmp.Sync(); err != nil {
+        return err
+    }
+    if err := tmp.Close(); err != nil {
+        return err
+    }
+    if err := os.Chmod(tmpName, perm); err != nil {
+        return err
+    }
+    if err := os.Rename(tmpName, path); err != nil {
+        return err
+    }
+    success = true
+    return nil
+}
+
+// resolveSymlinks returns the file that writing to path should replace:
+// path with symlinks resolved, including a final link to a file that does
+// not exist yet
+func resolveSymlinks(path string) (string, error) {
+    for i := 0; i < 255; i++ {
+        resolved, err := filepath.EvalSymlinks(path)
+        if err == nil {
+            return resolved, nil
+        }
+        info, lstatErr := os.Lstat(path)
+        if os.IsNotExist(lstatErr) {
+            return path, nil // A new file
+        }
+        if lstatErr != nil || info.Mode()&os.ModeSymlink == 0 {
+            return "", err
+        }
+        // A dangling link: follow it to the file it will create
+        target, err := os.Readlink(path)
+        if err != nil {
+            return "", err
+        }
+        if !filepath.IsAbs(target) {
+            target = filepath.Join(filepath.Dir(path), target)
+        }
+        path = target
+    }
+    return "", fmt.Errorf("too many levels of symbolic links: %s", path)
+}
+
+// fileState is what FileTracker remembers about a file the model has seen
+type fileState struct {
+    modTime time.Time
+    size    int64
+    hash    [sha256.Size]byte
+}
+
+// FileTracker remembers the state of files as the model last saw them, so
+// edits can be refused when a file changed on disk in the meantime
+type FileTracker struct {
+    mu    sync.Mutex
+    files map[string]fileState
+}
+
+// NewFileTracker creates an empty FileTracker
+func NewFileTracker() *FileTracker {
+    return &FileTracker{files: make(map[string]fileState)}
+}
+
+// Record notes the content of path as seen by the model
+func (t *FileTracker) Record(path string, content []byte) {
+    if t == nil {
+        return
+    }
+    t.record(path, sha256.Sum256(content))
+}
+
+// recordHash is Record for content that was streamed through hash
+func (t *FileTracker) recordHash(path string, hash hash.Hash) {
+    if t == nil {
+        return
+    }
+    var sum [sha256.Size]byte
+    copy(sum[:], hash.Sum(nil))
+    t.record(path, sum)
+}
+
+// record notes the state of path, whose content has the given hash
+func (t *FileTracker) record(path string, sum [sha256.Size]byte) {
+    absPath, err := filepath.Abs(path)
+    if err != nil {
+        return
+    }
+    info, err := os.Stat(absPath)
+    if err != nil {
+        return
+    }
+
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    t.files[absPath] = fileState{
+        modTime: info.ModTime(),
+        size:    info.Size(),
+        hash:    sum,
+    }
+}
+
+// Forget stops tracking path, e.g. after it was deleted
+func (t *FileTracker) Forget(path string) {
+    if t == nil {
+        return
+    }
+    absPath, err := filepath.Abs(path)
+    if err != nil {
+        return
+    }
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    delete(t.files, absPath)
+}
+
+// Refresh re-records a tracked file from its current content, for changes
+// made on the model's behalf such as a formatter run after an edit. Untracked
+// files are left alone and files that no longer exist are forgotten.
+func (t *FileTracker) Refresh(path string) {
+    if t == nil {
+        return
+    }
+    absPath, err := filepath.Abs(path)
+    if err != nil {
+        return
+    }
+    t.mu.Lock()
+    _, tracked := t.files[absPath]
+    t.mu.Unlock()
+    if !tracked {
+        return
+    }
+    content, err := os.ReadFile(absPath)
+    if err != nil {
+        t.Forget(absPath)
+        return
+    }
+    t.Record(absPath, content)
+}
+
+// Check returns an error if path has changed on disk since it was last
+// recorded. Files the model has never read are not checked. content is the
+// file's current content, which is hashed when the modification time differs.
+func (t *FileTracker) Check(path string, info os.FileInfo, content []byte) error {
+    if t == nil {
+        return nil
+    }
+    absPath, err := filepath.Abs(path)
+    if err != nil {
+        return nil
+    }
+
+    t.mu.Lock()
+    state, tracked := t.files[absPath]
+    t.mu.Unlock()
+    if !tracked {
+        return nil
+    }
+
+    if info.ModTime().Equal(state.modTime) && info.Size() == state.size {
+        return nil
+    }
+    // A touched but otherwise unchanged file is still safe to edit
+    if sha256.Sum256(content) == state.hash {
+        return nil
+    }
+    return fmt.Errorf("%s has been modified on disk since it was last read (modified %s); read it again before editing so changes are not overwritten",
+        path, info.ModTime().Format(time.RFC3339))
+}
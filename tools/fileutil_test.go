@@ -0,0 +1,192 @@
+package tools
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+    dir := t.TempDir()
+    path := filepath.Join(dir, "a.txt")
+
+    for _, perm := range []os.FileMode{0600, 0755} {
+        if err := WriteFileAtomic(path, []byte("content"), perm); err != nil {
+            t.Fatalf("WriteFileAtomic: %v", err)
+        }
+        info, err := os.Stat(path)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if info.Mode().Perm() != perm {
+            t.Errorf("mode = %v, want %v", info.Mode().Perm(), perm)
+        }
+    }
+    if got := readFile(t, path); got != "content" {
+        t.Errorf("content = %q", got)
+    }
+}
+
+func TestWriteFileAtomicSymlinks(t *testing.T) {
+    dir := t.TempDir()
+    writeTree(t, dir, map[string]string{"real/target.txt": "old"})
+    tests := []struct {
+        name   string
+        link   string // Link to create, relative to dir
+        target string // What it points to
+        want   string // The file that must receive the content, relative to dir
+    }{
+        {name: "absolute link", link: "abs.txt", target: filepath.Join(dir, "real/target.txt"), want: "real/target.txt"},
+        {name: "relative link", link: "rel.txt", target: "real/target.txt", want: "real/target.txt"},
+        {name: "link in a linked directory", link: "linkdir", target: "real", want: "real/target.txt"},
+        {name: "dangling link", link: "dangling.txt", target: "real/created.txt", want: "real/created.txt"},
+        {name: "chain", link: "chain.txt", target: "rel.txt", want: "real/target.txt"},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            link := filepath.Join(dir, test.link)
+            if err := os.Symlink(test.target, link); err != nil {
+                t.Skipf("symlinks unavailable: %v", err)
+            }
+            path := link
+            if test.link == "linkdir" {
+                path = filepath.Join(link, "target.txt")
+            }
+            if err := WriteFileAtomic(path, []byte(test.name), 0644); err != nil {
+                t.Fatalf("WriteFileAtomic: %v", err)
+            }
+            if got := readFile(t, filepath.Join(dir, test.want)); got != test.name {
+                t.Errorf("%s = %q, want %q", test.want, got, test.name)
+            }
+            if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+                t.Errorf("%s is no longer a symlink", test.link)
+            }
+        })
+    }
+}
+
+func TestWriteFileAtomicRemovesTempFileOnFailure(t *testing.T) {
+    dir := t.TempDir()
+    // Renaming a file over a non-empty directory fails after the temporary file is written
+    writeTree(t, dir, map[string]string{"target/inside.txt": "x"})
+    if err := WriteFileAtomic(filepath.Join(dir, "target"), []byte("data"), 0644); err == nil {
+        t.Fatal("WriteFileAtomic over a directory succeeded")
+    }
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, entry := range entries {
+        if strings.Contains(entry.Name(), ".tmp-") {
+            t.Errorf("temporary file %s was left behind", entry.Name())
+        }
+    }
+}
+
+func TestFileTracker(t *testing.T) {
+    dir := t.TempDir()
+    path := filepath.Join(dir, "a.txt")
+    past := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+    // write sets the content and modification time of path
+    write := func(content string, modTime time.Time) {
+        t.Helper()
+        if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+            t.Fatal(err)
+        }
+        if err := os.Chtimes(path, modTime, modTime); err != nil {
+            t.Fatal(err)
+        }
+    }
+    check := func(tracker *FileTracker) error {
+        t.Helper()
+        info, err := os.Stat(path)
+        if err != nil {
+            t.Fatal(err)
+        }
+        return tracker.Check(path, info, []byte(readFile(t, path)))
+    }
+
+    tests := []struct {
+        name    string
+        change  func()
+        wantErr bool
+    }{
+        {name: "unchanged", change: func() {}},
+        {name: "touched", change: func() { write("hello", past.Add(time.Minute)) }},
+        {name: "same size, new time", change: func() { write("HELLO", past.Add(time.Minute)) }, wantErr: true},
+        {name: "new size", change: func() { write("hello world", past) }, wantErr: true},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            write("hello", past)
+            tracker := NewFileTracker()
+            tracker.Record(path, []byte("hello"))
+            test.change()
+            if err := check(tracker); (err != nil) != test.wantErr {
+                t.Errorf("Check = %v, want error: %v", err, test.wantErr)
+            }
+        })
+    }
+
+    t.Run("untracked and forgotten files", func(t *testing.T) {
+        write("hello", past)
+        tracker := NewFileTracker()
+        write("changed", time.Now())
+        if err := check(tracker); err != nil {
+            t.Errorf("Check of an untracked file = %v, want nil", err)
+        }
+        tracker.Record(path, []byte("changed"))
+        write("changed again", past)
+        tracker.Forget(path)
+        if err := check(tracker); err != nil {
+            t.Errorf("Check of a forgotten file = %v, want nil", err)
+        }
+    })
+
+    t.Run("relative and absolute paths", func(t *testing.T) {
+        chdirTemp(t)
+        writeTree(t, ".", map[string]string{"b.txt": "b"})
+        tracker := NewFileTracker()
+        tracker.Record("b.txt", []byte("b"))
+        abs, err := filepath.Abs("b.txt")
+        if err != nil {
+            t.Fatal(err)
+        }
+        if err := os.WriteFile(abs, []byte("bigger"), 0644); err != nil {
+            t.Fatal(err)
+        }
+        info, _ := os.Stat(abs)
+        if err := tracker.Check(abs, info, []byte("bigger")); err == nil {
+            t.Error("Check by absolute path missed a change recorded by relative path")
+        }
+    })
+
+    t.Run("refresh", func(t *testing.T) {
+        write("hello", past)
+        tracker := NewFileTracker()
+        tracker.Record(path, []byte("hello"))
+        write("formatted", past.Add(time.Minute))
+        tracker.Refresh(path)
+        if err := check(tracker); err != nil {
+            t.Errorf("Check after Refresh = %v, want nil", err)
+        }
+
+        // Refresh doesn't start tracking files the model hasn't seen
+        untracked := NewFileTracker()
+        untracked.Refresh(path)
+        write("changed", past.Add(2*time.Minute))
+        if err := check(untracked); err != nil {
+            t.Errorf("Check of an untracked file = %v, want nil", err)
+        }
+    })
+
+    var nilTracker *FileTracker
+    nilTracker.Record(path, nil)
+    nilTracker.Refresh(path)
+    if err := check(nilTracker); err != nil {
+        t.Errorf("nil tracker Check = %v, want nil", err)
+    }
+}
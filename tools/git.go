@@ -0,0 +1,491 @@
+package tools
+
+import (
+    "bytes"
+    "fmt"
+    "os"
+    "os/exec"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// maxGitOutput truncates large diffs and logs returned to the model
+const maxGitOutput = 50000
+
+// GitRequest defines the structure for git operations
+type GitRequest struct {
+    Action    string   `json:"action"`               // status, diff, log, blame, show, branch or commit
+    Paths     []string `json:"paths,omitempty"`      // Limit diff/log to these paths, or files to stage for commit
+    Staged    bool     `json:"staged,omitempty"`     // diff: show staged rather than unstaged changes
+    Ref       string   `json:"ref,omitempty"`        // diff/log/blame/show: revision to use
+    MaxCount  int      `json:"max_count,omitempty"`  // log: number of commits (default 20)
+    File      string   `json:"file,omitempty"`       // blame: file to annotate
+    StartLine int      `json:"start_line,omitempty"` // blame: first line
+    EndLine   int      `json:"end_line,omitempty"`   // blame: last line
+    Message   string   `json:"message,omitempty"`    // commit: message; generated from the staged changes if empty
+    All       bool     `json:"all,omitempty"`        // commit: stage all changes first
+}
+
+// GitTool exposes common git operations by running the local git binary
+type GitTool struct {
+    // Dir is the repository directory; the working directory when empty
+    Dir string
+}
+
+func (t *GitTool) Execute(input string) (string, error) {
+    var request GitRequest
+    if !decodeInput(input, &request) {
+        // Plain text input names the action, e.g. "status"
+        fields := strings.Fields(input)
+        if len(fields) > 0 {
+            request.Action = fields[0]
+        }
+    }
+
+    if err := checkGitArgs(request); err != nil {
+        return "", err
+    }
+
+    switch request.Action {
+    case "status":
+        return t.status()
+    case "diff":
+        return t.diff(request)
+    case "log":
+        return t.log(request)
+    case "blame":
+        return t.blame(request)
+    case "show":
+        return t.show(request)
+    case "branch":
+        return t.branch()
+    case "commit":
+        return t.commit(request)
+    case "":
+        return "", fmt.Errorf("action is required")
+    default:
+        return "", fmt.Errorf("unsupported action: %s. Use status, diff, log, blame, show, branch or commit", request.Action)
+    }
+}
+
+// checkGitArgs refuses revisions and paths that git would parse as options,
+// such as "--output=<file>"
+func checkGitArgs(request GitRequest) error {
+    if strings.HasPrefix(request.Ref, "-") {
+        return fmt.Errorf("invalid ref %q: refs cannot start with '-'", request.Ref)
+    }
+    if strings.HasPrefix(request.File, "-") {
+        return fmt.Errorf("invalid file %q: use ./%s for files starting with '-'", request.File, request.File)
+    }
+    for _, path := range request.Paths {
+        if strings.HasPrefix(path, "-") {
+            return fmt.Errorf("invalid path %q: use ./%s for paths starting with '-'", path, path)
+        }
+    }
+    return nil
+}
+
+// run executes git with args and returns its stdout
+func (t *GitTool) run(args ...string) (string, error) {
+    cmd := exec.Command("git", args...)
+    cmd.Dir = t.Dir
+    cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "LC_ALL=C", "GIT_PAGER=cat")
+    var stdout, stderr bytes.Buffer
+    cmd.Stdout = &stdout
+    cmd.Stderr = &stderr
+    if err := cmd.Run(); err != nil {
+        message := strings.TrimSpace(stderr.String())
+        if message == "" {
+            message = err.Error()
+        }
+        return "", fmt.Errorf("git %s failed: %s", args[0], message)
+    }
+    return stdout.String(), nil
+}
+
+// truncateOutput limits output to maxGitOutput bytes with a notice
+func truncateOutput(output string) string {
+    if len(output) <= maxGitOutput {
+        return output
+    }
+    return output[:maxGitOutput] + fmt.Sprintf("\n[output truncated: %d of %d bytes shown; narrow the request with paths or ref]", maxGitOutput, len(output))
+}
+
+// status summarises the working tree using porcelain output
+func (t *GitTool) status() (string, error) {
+    output, err := t.run("status", "--porcelain=v1", "--branch", "-z")
+    if err != nil {
+        return "", err
+    }
+
+    var branch string
+    var staged, unstaged, untracked, conflicted []string
+    entries := strings.Split(output, "\x00")
+    for i := 0; i < len(entries); i++ {
+        entry := entries[i]
+        if strings.HasPrefix(entry, "## ") {
+            branch = strings.TrimPrefix(entry, "## ")
+            continue
+        }
+        if len(entry) < 4 {
+            continue
+        }
+        x, y, path := entry[0], entry[1], entry[3:]
+        stagedPath := path
+        // Renames and copies are followed by the original path
+        if x == 'R' || x == 'C' {
+            if i+1 < len(entries) {
+                stagedPath = entries[i+1] + " -> " + path
+                i++
+            }
+        }
+        switch {
+        case x == '?' && y == '?':
+            untracked = append(untracked, path)
+        case x == 'U' || y == 'U' || (x == 'A' && y == 'A') || (x == 'D' && y == 'D'):
+            conflicted = append(conflicted, path)
+        default:
+            if x != ' ' {
+                staged = append(staged, describeStatus(x)+" "+stagedPath)
+            }
+            if y != ' ' {
+                unstaged = append(unstaged, describeStatus(y)+" "+path)
+            }
+        }
+    }
+
+    var sb strings.Builder
+    sb.WriteString(fmt.Sprintf("Branch: %s\n", branch))
+    writeSection := func(title string, items []string) {
+        if len(items) == 0 {
+            return
+        }
+        sb.WriteString(fmt.Sprintf("\n%s:\n", title))
+        for _, item := range items {
+            sb.WriteString("  " + item + "\n")
+        }
+    }
+    writeSection("Staged changes", staged)
+    writeSection("Unstaged changes", unstaged)
+    writeSection("Untracked files", untracked)
+    writeSection("Conflicts", conflicted)
+    if len(staged)+len(unstaged)+len(untracked)+len(conflicted) == 0 {
+        sb.WriteString("Working tree clean\n")
+    }
+    return sb.String(), nil
+}
+
+// describeStatus names a porcelain status letter
+func describeStatus(code byte) string {
+    switch code {
+    case 'M':
+        return "modified:"
+    case 'A':
+        return "added:   "
+    case 'D':
+        return "deleted: "
+    case 'R':
+        return "renamed: "
+    case 'C':
+        return "copied:  "
+    case 'T':
+        return "typechange:"
+    default:
+        return string(code) + ":"
+    }
+}
+
+func (t *GitTool) diff(request GitRequest) (string, error) {
+    args := []string{"diff", "--no-color", "--no-ext-diff"}
+    if request.Staged {
+        args = append(args, "--cached")
+    }
+    if request.Ref != "" {
+        args = append(args, request.Ref)
+    }
+    args = append(args, "--")
+    args = append(args, request.Paths...)
+    output, err := t.run(args...)
+    if err != nil {
+        return "", err
+    }
+    if strings.TrimSpace(output) == "" {
+        return "No differences", nil
+    }
+    return truncateOutput(output), nil
+}
+
+func (t *GitTool) log(request GitRequest) (string, error) {
+    count := request.MaxCount
+    if count <= 0 {
+        count = 20
+    }
+    // Unit and record separators keep subjects containing any text parseable
+    args := []string{"log", "-n", strconv.Itoa(count), "--date=short", "--format=%h%x1f%ad%x1f%an%x1f%s%x1e"}
+    if request.Ref != "" {
+        args = append(args, request.Ref)
+    }
+    args = append(args, "--")
+    args = append(args, request.Paths...)
+    output, err := t.run(args...)
+    if err != nil {
+        return "", err
+    }
+
+    var sb strings.Builder
+    for _, record := range strings.Split(output, "\x1e") {
+        fields := strings.Split(strings.TrimSpace(record), "\x1f")
+        if len(fields) != 4 {
+            continue
+        }
+        sb.WriteString(fmt.Sprintf("%s %s %s: %s\n", fields[0], fields[1], fields[2], fields[3]))
+    }
+    if sb.Len() == 0 {
+        return "No commits found", nil
+    }
+    return sb.String(), nil
+}
+
+func (t *GitTool) blame(request GitRequest) (string, error) {
+    if request.File == "" {
+        return "", fmt.Errorf("file is required for blame")
+    }
+    args := []string{"blame", "--porcelain"}
+    if request.StartLine > 0 {
+        end := ""
+        if request.EndLine >= request.StartLine {
+            end = strconv.Itoa(request.EndLine)
+        }
+        args = append(args, "-L", fmt.Sprintf("%d,%s", request.StartLine, end))
+    }
+    if request.Ref != "" {
+        args = append(args, request.Ref)
+    }
+    args = append(args, "--", request.File)
+    output, err := t.run(args...)
+    if err != nil {
+        return "", err
+    }
+
+    // Porcelain output lists commit details only the first time a commit appears
+    authors := make(map[string]string)
+    dates := make(map[string]string)
+    var sb strings.Builder
+    var sha string
+    var lineNumber string
+    for _, line := range strings.Split(output, "\n") {
+        switch {
+        case strings.HasPrefix(line, "\t"):
+            short := sha
+            if len(short) > 8 {
+                short = short[:8]
+            }
+            sb.WriteString(fmt.Sprintf("%s %-12s %s %5s| %s\n", short, truncateField(authors[sha], 12), dates[sha], lineNumber, line[1:]))
+        case strings.HasPrefix(line, "author "):
+            authors[sha] = strings.TrimPrefix(line, "author ")
+        case strings.HasPrefix(line, "author-time "):
+            if seconds, err := strconv.ParseInt(strings.TrimPrefix(line, "author-time "), 10, 64); err == nil {
+                dates[sha] = time.Unix(seconds, 0).Format("2006-01-02")
+            }
+        default:
+            fields := strings.Fields(line)
+            if len(fields) >= 3 && len(fields[0]) == 40 {
+                sha = fields[0]
+                lineNumber = fields[2]
+            }
+        }
+    }
+    return truncateOutput(sb.String()), nil
+}
+
+func truncateField(s string, n int) string {
+    if len(s) <= n {
+        return s
+    }
+    return s[:n]
+}
+
+func (t *GitTool) show(request GitRequest) (string, error) {
+    ref := request.Ref
+    if ref == "" {
+        ref = "HEAD"
+    }
+    output, err := t.run("show", "--no-color", "--stat", "--patch", "--format=commit %H%nAuthor: %an <%ae>%nDate:   %ad%n%n%B", ref, "--")
+    if err != nil {
+        return "", err
+    }
+    return truncateOutput(output), nil
+}
+
+func (t *GitTool) branch() (string, error) {
+    output, err := t.run("for-each-ref", "--sort=-committerdate", "--format=%(HEAD)%1f%(refname:short)%1f%(objectname:short)%1f%(upstream:short)%1f%(upstream:track)%1f%(contents:subject)", "refs/heads")
+    if err != nil {
+        return "", err
+    }
+    var sb strings.Builder
+    for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+        fields := strings.Split(line, "\x1f")
+        if len(fields) != 6 {
+            continue
+        }
+        marker := " "
+        if fields[0] == "*" {
+            marker = "*"
+        }
+        upstream := ""
+        if fields[3] != "" {
+            upstream = fmt.Sprintf(" [%s%s]", fields[3], strings.TrimSpace(" "+fields[4]))
+        }
+        sb.WriteString(fmt.Sprintf("%s %s %s%s %s\n", marker, fields[1], fields[2], upstream, fields[5]))
+    }
+    if sb.Len() == 0 {
+        return "No branches yet", nil
+    }
+    return sb.String(), nil
+}
+
+func (t *GitTool) commit(request GitRequest) (string, error) {
+    if request.All {
+        if _, err := t.run("add", "--all"); err != nil {
+            return "", err
+        }
+    } else if len(request.Paths) > 0 {
+        args := append([]string{"add", "--"}, request.Paths...)
+        if _, err := t.run(args...); err != nil {
+            return "", err
+        }
+    }
+
+    nameStatus, err := t.run("diff", "--cached", "--name-status")
+    if err != nil {
+        return "", err
+    }
+    if strings.TrimSpace(nameStatus) == "" {
+        return "", fmt.Errorf("nothing to commit: no staged changes (pass paths or all to stage changes)")
+    }
+
+    message := strings.TrimSpace(request.Message)
+    if message == "" {
+        message = generateCommitMessage(nameStatus)
+    }
+    if _, err := t.run("commit", "--quiet", "-m", message); err != nil {
+        return "", err
+    }
+
+    summary, err := t.run("log", "-1", "--format=%h %s", "--stat")
+    if err != nil {
+        return "", err
+    }
+    return "Committed " + summary, nil
+}
+
+// generateCommitMessage derives a short message from `git diff --name-status` output
+func generateCommitMessage(nameStatus string) string {
+    var added, modified, deleted, renamed []string
+    for _, line := range strings.Split(strings.TrimSpace(nameStatus), "\n") {
+        fields := strings.Split(line, "\t")
+        if len(fields) < 2 {
+            continue
+        }
+        path := fields[len(fields)-1]
+        switch fields[0][0] {
+        case 'A':
+            added = append(added, path)
+        case 'D':
+            deleted = append(deleted, path)
+        case 'R':
+            renamed = append(renamed, path)
+        default:
+            modified = append(modified, path)
+        }
+    }
+
+    var parts []string
+    describe := func(verb string, paths []string) {
+        if len(paths) == 0 {
+            return
+        }
+        if len(paths) <= 3 {
+            parts = append(parts, verb+" "+strings.Join(paths, ", "))
+        } else {
+            parts = append(parts, fmt.Sprintf("%s %d files", verb, len(paths)))
+        }
+    }
+    describe("Update", modified)
+    describe("Add", added)
+    describe("Remove", deleted)
+    describe("Rename to", renamed)
+
+    message := strings.Join(parts, "; ")
+    if message == "" {
+        return "Update files"
+    }
+    return message
+}
+
+func (t *GitTool) GetName() string {
+    return "git"
+}
+
+func (t *GitTool) GetDescription() string {
+    return "Inspect and commit to the git repository: status, diff (staged or unstaged), log, blame, show, branch, and commit (requires user approval; the message is generated from the staged changes if omitted)"
+}
+
+func (t *GitTool) GetInputSchema() map[string]interface{} {
+    return objectSchema(map[string]interface{}{
+        "action":     map[string]interface{}{"type": "string", "enum": []string{"status", "diff", "log", "blame", "show", "branch", "commit"}, "description": "The git operation to run"},
+        "paths":      map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}, "description": "diff/log: limit to these paths; commit: files to stage"},
+        "staged":     property("boolean", "diff: show staged instead of unstaged changes"),
+        "ref":        property("string", "diff/log/blame/show: revision, e.g. HEAD~1 or main"),
+        "max_count":  property("integer", "log: number of commits to show (default 20)"),
+        "file":       property("string", "blame: file to annotate"),
+        "start_line": property("integer", "blame: first line to annotate"),
+        "end_line":   property("integer", "blame: last line to annotate"),
+        "message":    property("string", "commit: commit message"),
+        "all":        property("boolean", "commit: stage all changes, including untracked files"),
+    }, "action")
+}
+
+// IsReadOnlyInput is true for every action except commit
+func (t *GitTool) IsReadOnlyInput(input string) bool {
+    return gitAction(input) != "commit"
+}
+
+// gitAction returns the action named by a JSON or plain text input
+func gitAction(input string) string {
+    var request GitRequest
+    if !decodeInput(input, &request) {
+        if fields := strings.Fields(input); len(fields) > 0 {
+            request.Action = fields[0]
+        }
+    }
+    return request.Action
+}
+
+// NeedsApproval gates commits behind the user's permission
+func (t *GitTool) NeedsApproval(input string) (bool, string) {
+    var request GitRequest
+    if !decodeInput(input, &request) {
+        fields := strings.Fields(input)
+        if len(fields) > 0 {
+            request.Action = fields[0]
+        }
+    }
+    if request.Action != "commit" {
+        return false, ""
+    }
+    summary := "git commit"
+    switch {
+    case request.All:
+        summary += " of all changes"
+    case len(request.Paths) > 0:
+        summary += " of " + strings.Join(request.Paths, ", ")
+    default:
+        summary += " of staged changes"
+    }
+    if request.Message != "" {
+        summary += fmt.Sprintf(" with message %q", request.Message)
+    }
+    return true, summary
+}
@@ -0,0 +1,115 @@
+package tools
+
+import (
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+// newTestRepo creates a repository with one commit of hello.txt
+func newTestRepo(t *testing.T) string {
+    t.Helper()
+    if _, err := exec.LookPath("git"); err != nil {
+        t.Skip("git is not installed")
+    }
+    dir := t.TempDir()
+    t.Setenv("GIT_AUTHOR_NAME", "Test")
+    t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+    t.Setenv("GIT_COMMITTER_NAME", "Test")
+    t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+    t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+    t.Setenv("HOME", dir)
+
+    gitIn := func(args ...string) {
+        cmd := exec.Command("git", args...)
+        cmd.Dir = dir
+        if output, err := cmd.CombinedOutput(); err != nil {
+            t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, output)
+        }
+    }
+    gitIn("init", "--quiet", "--initial-branch=main")
+    if err := os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("hello\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    gitIn("add", "hello.txt")
+    gitIn("commit", "--quiet", "-m", "Add hello")
+    return dir
+}
+
+func TestGitTool(t *testing.T) {
+    dir := newTestRepo(t)
+    if err := os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("hello\nworld\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    outside := filepath.Join(t.TempDir(), "written")
+    tool := &GitTool{Dir: dir}
+
+    tests := []struct {
+        name    string
+        input   string
+        want    string
+        wantErr string
+    }{
+        {name: "status", input: `{"action":"status"}`, want: "modified: hello.txt"},
+        {name: "diff", input: `{"action":"diff"}`, want: "+world"},
+        {name: "diff of a path", input: `{"action":"diff","paths":["hello.txt"],"ref":"HEAD"}`, want: "+world"},
+        {name: "log", input: `{"action":"log"}`, want: "Add hello"},
+        {name: "blame", input: `{"action":"blame","file":"hello.txt","ref":"HEAD"}`, want: "| hello"},
+        {name: "show", input: `{"action":"show"}`, want: "Add hello"},
+        {name: "plain text action", input: "branch", want: "* main"},
+
+        // Values starting with "-" would be read by git as options
+        {name: "diff ref option", input: `{"action":"diff","ref":"--output=` + outside + `"}`, wantErr: "invalid ref"},
+        {name: "log ref option", input: `{"action":"log","ref":"--output=` + outside + `"}`, wantErr: "invalid ref"},
+        {name: "show ref option", input: `{"action":"show","ref":"--output=` + outside + `"}`, wantErr: "invalid ref"},
+        {name: "blame ref option", input: `{"action":"blame","file":"hello.txt","ref":"--output=` + outside + `"}`, wantErr: "invalid ref"},
+        {name: "blame file option", input: `{"action":"blame","file":"--output=` + outside + `"}`, wantErr: "invalid file"},
+        {name: "path option", input: `{"action":"diff","paths":["--output=` + outside + `"]}`, wantErr: "invalid path"},
+        {name: "commit path option", input: `{"action":"commit","paths":["--chmod=+x"]}`, wantErr: "invalid path"},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            output, err := tool.Execute(test.input)
+            if test.wantErr != "" {
+                if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+                    t.Fatalf("Execute error = %v, want %q", err, test.wantErr)
+                }
+            } else if err != nil {
+                t.Fatalf("Execute: %v", err)
+            } else if !strings.Contains(output, test.want) {
+                t.Errorf("output does not contain %q:\n%s", test.want, output)
+            }
+            if _, err := os.Stat(outside); err == nil {
+                t.Fatalf("git wrote %s", outside)
+            }
+        })
+    }
+}
+
+func TestGitToolCommit(t *testing.T) {
+    dir := newTestRepo(t)
+    if err := os.WriteFile(filepath.Join(dir, "new.txt"), []byte("new\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    tool := &GitTool{Dir: dir}
+
+    input := `{"action":"commit","paths":["new.txt"]}`
+    if tool.IsReadOnlyInput(input) || !tool.IsReadOnlyInput(`{"action":"diff"}`) || !tool.IsReadOnlyInput("status") {
+        t.Error("IsReadOnlyInput should be false for commit only")
+    }
+    if needs, summary := tool.NeedsApproval(input); !needs || !strings.Contains(summary, "new.txt") {
+        t.Errorf("NeedsApproval = %v, %q; want approval for new.txt", needs, summary)
+    }
+    output, err := tool.Execute(input)
+    if err != nil {
+        t.Fatalf("Execute: %v", err)
+    }
+    if !strings.Contains(output, "Add new.txt") {
+        t.Errorf("commit output = %q, want the generated message", output)
+    }
+    if _, err := tool.Execute(`{"action":"commit"}`); err == nil || !strings.Contains(err.Error(), "nothing to commit") {
+        t.Errorf("empty commit error = %v, want nothing to commit", err)
+    }
+}
@@ -0,0 +1,475 @@
+package tools
+
+import (
+    "fmt"
+    "go/ast"
+    "go/token"
+    "go/types"
+    "os"
+    "sort"
+    "strings"
+
+    "golang.org/x/tools/go/packages"
+)
+
+// maxSymbolResults caps the number of declarations or references returned
+const maxSymbolResults = 200
+
+// GoSymbolsRequest defines the structure for go_symbols operations
+type GoSymbolsRequest struct {
+    Action  string `json:"action"`            // declarations, definition, references or signature
+    Package string `json:"package,omitempty"` // Package pattern, e.g. ./agent or ./...
+    Symbol  string `json:"symbol,omitempty"`  // Name, Type.Method, Type.Field or pkg.Name
+}
+
+// GoSymbolsTool navigates Go code using the type checker
+type GoSymbolsTool struct {
+    // Dir is the directory packages are loaded from; the working directory when empty
+    Dir string
+}
+
+// loadedSymbol is a resolved symbol and the package that declares it
+type loadedSymbol struct {
+    object types.Object
+    pkg    *packages.Package
+}
+
+func (t *GoSymbolsTool) Execute(input string) (string, error) {
+    var request GoSymbolsRequest
+    if !decodeInput(input, &request) {
+        return "", fmt.Errorf("input must be a JSON object with action, package and symbol")
+    }
+
+    switch request.Action {
+    case "declarations":
+        pattern := request.Package
+        if pattern == "" {
+            pattern = "."
+        }
+        pkgs, err := t.load(pattern)
+        if err != nil {
+            return "", err
+        }
+        return declarations(pkgs), nil
+    case "definition", "references", "signature":
+        if request.Symbol == "" {
+            return "", fmt.Errorf("symbol is required for %s", request.Action)
+        }
+        pattern := request.Package
+        if pattern == "" {
+            pattern = "./..."
+        }
+        pkgs, err := t.load(pattern)
+        if err != nil {
+            return "", err
+        }
+        symbols := findSymbol(pkgs, request.Symbol)
+        if len(symbols) == 0 {
+            return "", fmt.Errorf("symbol %s not found in %s", request.Symbol, pattern)
+        }
+        switch request.Action {
+        case "definition":
+            return definitions(symbols), nil
+        case "references":
+            return references(pkgs, symbols), nil
+        default:
+            return signatures(symbols), nil
+        }
+    case "":
+        return "", fmt.Errorf("action is required")
+    default:
+        return "", fmt.Errorf("unsupported action: %s. Use declarations, definition, references or signature", request.Action)
+    }
+}
+
+// load type-checks the packages matching pattern
+func (t *GoSymbolsTool) load(pattern string) ([]*packages.Package, error) {
+    config := &packages.Config{
+        Mode: packages.NeedName | packages.NeedFiles | packages.NeedSyntax |
+            packages.NeedTypes | packages.NeedTypesInfo | packages.NeedImports | packages.NeedDeps,
+        Dir:   t.Dir,
+        Tests: true,
+    }
+    pkgs, err := packages.Load(config, pattern)
+    if err != nil {
+        return nil, fmt.Errorf("failed to load %s: %w", pattern, err)
+    }
+    if len(pkgs) == 0 {
+        return nil, fmt.Errorf("no packages match %s", pattern)
+    }
+
+    // With Tests set, a package appears both alone and as its test variant;
+    // keep only the most complete variant of each
+    byPath := make(map[string]*packages.Package)
+    var order []string
+    for _, pkg := range pkgs {
+        if strings.HasSuffix(pkg.ID, ".test") {
+            continue
+        }
+        existing, seen := byPath[pkg.PkgPath]
+        if !seen {
+            order = append(order, pkg.PkgPath)
+        }
+        if !seen || len(pkg.Syntax) > len(existing.Syntax) {
+            byPath[pkg.PkgPath] = pkg
+        }
+    }
+    sort.Strings(order)
+    result := make([]*packages.Package, 0, len(order))
+    for _, path := range order {
+        result = append(result, byPath[path])
+    }
+    return result, nil
+}
+
+// declarations lists the top-level declarations of each package
+func declarations(pkgs []*packages.Package) string {
+    var sb strings.Builder
+    count := 0
+    for _, pkg := range pkgs {
+        sb.WriteString(fmt.Sprintf("package %s (%s)\n", pkg.Name, pkg.PkgPath))
+        for _, problem := range pkg.Errors {
+            sb.WriteString(fmt.Sprintf("  error: %s\n", problem))
+        }
+        for _, file := range pkg.Syntax {
+            for _, decl := range file.Decls {
+                for _, ident := range declIdents(decl) {
+                    object := pkg.TypesInfo.Defs[ident]
+                    if object == nil {
+                        continue
+                    }
+                    if count == maxSymbolResults {
+                        sb.WriteString(fmt.Sprintf("... (more than %d declarations; narrow the package pattern)\n", maxSymbolResults))
+                        return sb.String()
+                    }
+                    count++
+                    position := pkg.Fset.Position(ident.Pos())
+                    sb.WriteString(fmt.Sprintf("  %s:%d  %s\n", relativePath(position.Filename), position.Line, describeObject(object, pkg.Types)))
+                }
+            }
+        }
+    }
+    return sb.String()
+}
+
+// declIdents returns the names declared by a top-level declaration
+func declIdents(decl ast.Decl) []*ast.Ident {
+    var idents []*ast.Ident
+    switch d := decl.(type) {
+    case *ast.FuncDecl:
+        idents = append(idents, d.Name)
+    case *ast.GenDecl:
+        for _, spec := range d.Specs {
+            switch s := spec.(type) {
+            case *ast.TypeSpec:
+                idents = append(idents, s.Name)
+            case *ast.ValueSpec:
+                idents = append(idents, s.Names...)
+            }
+        }
+    }
+    return idents
+}
+
+// describeObject renders an object the way go doc would, qualified relative to pkg
+func describeObject(object types.Object, pkg *types.Package) string {
+    qualifier := types.RelativeTo(pkg)
+    if typeName, ok := object.(*types.TypeName); ok {
+        // Show the kind of type rather than its full structure
+        kind := "type"
+        switch typeName.Type().Underlying().(type) {
+        case *types.Struct:
+            kind = "struct"
+        case *types.Interface:
+            kind = "interface"
+        }
+        return fmt.Sprintf("type %s %s", typeName.Name(), kind)
+    }
+    return types.ObjectString(object, qualifier)
+}
+
+// findSymbol resolves a symbol name against the loaded packages. Names may
+// be plain (Foo), qualified by type (Type.Method, Type.Field) or by package
+// name (pkg.Foo, pkg.Type.Method).
+func findSymbol(pkgs []*packages.Package, symbol string) []loadedSymbol {
+    parts := strings.Split(symbol, ".")
+    var found []loadedSymbol
+    for _, pkg := range pkgs {
+        if pkg.Types == nil {
+            continue
+        }
+        names := parts
+        if len(parts) > 1 && parts[0] == pkg.Name && pkg.Types.Scope().Lookup(parts[0]) == nil {
+            names = parts[1:]
+        }
+
+        object := pkg.Types.Scope().Lookup(names[0])
+        if object == nil {
+            continue
+        }
+        if len(names) == 2 {
+            typeName, ok := object.(*types.TypeName)
+            if !ok {
+                continue
+            }
+            member, _, _ := types.LookupFieldOrMethod(types.NewPointer(typeName.Type()), true, pkg.Types, names[1])
+            if member == nil {
+                continue
+            }
+            object = member
+        } else if len(names) > 2 {
+            continue
+        }
+        found = append(found, loadedSymbol{object: object, pkg: pkg})
+    }
+    return found
+}
+
+// definitions shows where each symbol is declared along with its source
+func definitions(symbols []loadedSymbol) string {
+    var sb strings.Builder
+    for _, symbol := range symbols {
+        position := symbol.pkg.Fset.Position(symbol.object.Pos())
+        sb.WriteString(fmt.Sprintf("%s:%d:%d  %s\n", relativePath(position.Filename), position.Line, position.Column, describeObject(symbol.object, symbol.pkg.Types)))
+        if node := enclosingDecl(symbol.pkg, symbol.object.Pos()); node != nil {
+            sb.WriteString(indentBlock(nodeSource(symbol.pkg.Fset, node), "    "))
+        }
+    }
+    return sb.String()
+}
+
+// signatures shows each symbol's signature and doc comment
+func signatures(symbols []loadedSymbol) string {
+    var sb strings.Builder
+    for i, symbol := range symbols {
+        if i > 0 {
+            sb.WriteString("\n")
+        }
+        position := symbol.pkg.Fset.Position(symbol.object.Pos())
+        sb.WriteString(fmt.Sprintf("%s\n", types.ObjectString(symbol.object, types.RelativeTo(symbol.pkg.Types))))
+        sb.WriteString(fmt.Sprintf("    declared at %s:%d in %s\n", relativePath(position.Filename), position.Line, symbol.pkg.PkgPath))
+        if doc := docComment(symbol.pkg, symbol.object.Pos()); doc != "" {
+            sb.WriteString(indentBlock(doc, "    // "))
+        } else {
+            sb.WriteString("    (no doc comment)\n")
+        }
+    }
+    return sb.String()
+}
+
+// references lists every use of the symbols within the loaded packages
+func references(pkgs []*packages.Package, symbols []loadedSymbol) string {
+    wanted := make(map[string]bool)
+    for _, symbol := range symbols {
+        wanted[objectKey(symbol.object)] = true
+    }
+
+    type reference struct {
+        position token.Position
+        text     string
+    }
+    var refs []reference
+    lines := make(map[string][]string)
+    for _, pkg := range pkgs {
+        if pkg.TypesInfo == nil {
+            continue
+        }
+        for ident, object := range pkg.TypesInfo.Uses {
+            if object == nil || !wanted[objectKey(object)] {
+                continue
+            }
+            position := pkg.Fset.Position(ident.Pos())
+            if _, ok := lines[position.Filename]; !ok {
+                data, _ := os.ReadFile(position.Filename)
+                lines[position.Filename] = strings.Split(string(data), "\n")
+            }
+            text := ""
+            if fileLines := lines[position.Filename]; position.Line-1 < len(fileLines) {
+                text = strings.TrimSpace(fileLines[position.Line-1])
+            }
+            refs = append(refs, reference{position: position, text: text})
+        }
+    }
+
+    if len(refs) == 0 {
+        return "No references found"
+    }
+    sort.Slice(refs, func(i, j int) bool {
+        if refs[i].position.Filename != refs[j].position.Filename {
+            return refs[i].position.Filename < refs[j].position.Filename
+        }
+        if refs[i].position.Line != refs[j].position.Line {
+            return refs[i].position.Line < refs[j].position.Line
+        }
+        return refs[i].position.Column < refs[j].position.Column
+    })
+
+    var sb strings.Builder
+    sb.WriteString(fmt.Sprintf("%d reference(s):\n", len(refs)))
+    for i, ref := range refs {
+        if i == maxSymbolResults {
+            sb.WriteString(fmt.Sprintf("... (%d more)\n", len(refs)-maxSymbolResults))
+            break
+        }
+        sb.WriteString(fmt.Sprintf("%s:%d:%d  %s\n", relativePath(ref.position.Filename), ref.position.Line, ref.position.Column, ref.text))
+    }
+    return sb.String()
+}
+
+// objectKey identifies an object across separately type-checked packages,
+// where the same declaration may be represented by different objects
+func objectKey(object types.Object) string {
+    if object.Pkg() == nil {
+        return object.Name()
+    }
+    key := object.Pkg().Path() + "." + object.Name()
+    switch obj := object.(type) {
+    case *types.Func:
+        if signature, ok := obj.Type().(*types.Signature); ok && signature.Recv() != nil {
+            key = object.Pkg().Path() + "." + receiverName(signature.Recv().Type()) + "." + object.Name()
+        }
+    case *types.Var:
+        if obj.IsField() || obj.Parent() != object.Pkg().Scope() {
+            // Fields and locals are only distinguishable by position
+            key += fmt.Sprintf("@%d", obj.Pos())
+        }
+    }
+    return key
+}
+
+// receiverName returns the name of a method receiver's base type
+func receiverName(receiver types.Type) string {
+    if pointer, ok := receiver.(*types.Pointer); ok {
+        receiver = pointer.Elem()
+    }
+    if named, ok := receiver.(*types.Named); ok {
+        return named.Obj().Name()
+    }
+    return receiver.String()
+}
+
+// enclosingDecl returns the top-level declaration containing pos
+func enclosingDecl(pkg *packages.Package, pos token.Pos) ast.Node {
+    for _, file := range pkg.Syntax {
+        if pos < file.Pos() || pos > file.End() {
+            continue
+        }
+        for _, decl := range file.Decls {
+            if pos >= decl.Pos() && pos <= decl.End() {
+                return decl
+            }
+        }
+    }
+    return nil
+}
+
+// docComment returns the doc comment attached to the declaration at pos
+func docComment(pkg *packages.Package, pos token.Pos) string {
+    var doc *ast.CommentGroup
+    for _, file := range pkg.Syntax {
+        if pos < file.Pos() || pos > file.End() {
+            continue
+        }
+        ast.Inspect(file, func(node ast.Node) bool {
+            if node == nil || doc != nil || pos < node.Pos() || pos > node.End() {
+                return false
+            }
+            switch n := node.(type) {
+            case *ast.FuncDecl:
+                if n.Name.Pos() == pos {
+                    doc = n.Doc
+                }
+            case *ast.GenDecl:
+                // A lone spec's comment sits on the GenDecl
+                if len(n.Specs) == 1 && n.Doc != nil {
+                    if specContains(n.Specs[0], pos) {
+                        doc = n.Doc
+                        return false
+                    }
+                }
+            case *ast.TypeSpec:
+                if n.Name.Pos() == pos {
+                    doc = n.Doc
+                }
+            case *ast.ValueSpec:
+                for _, name := range n.Names {
+                    if name.Pos() == pos {
+                        doc = n.Doc
+                    }
+                }
+            case *ast.Field:
+                for _, name := range n.Names {
+                    if name.Pos() == pos {
+                        doc = n.Doc
+                        if doc == nil {
+                            doc = n.Comment
+                        }
+                    }
+                }
+            }
+            return true
+        })
+    }
+    if doc == nil {
+        return ""
+    }
+    return strings.TrimSpace(doc.Text())
+}
+
+// specContains reports whether pos names the identifier declared by spec
+func specContains(spec ast.Spec, pos token.Pos) bool {
+    switch s := spec.(type) {
+    case *ast.TypeSpec:
+        return s.Name.Pos() == pos
+    case *ast.ValueSpec:
+        for _, name := range s.Names {
+            if name.Pos() == pos {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// nodeSource returns the source text of node, truncated for long declarations
+func nodeSource(fset *token.FileSet, node ast.Node) string {
+    start := fset.Position(node.Pos())
+    end := fset.Position(node.End())
+    data, err := os.ReadFile(start.Filename)
+    if err != nil || end.Offset > len(data) {
+        return ""
+    }
+    lines := strings.Split(string(data[start.Offset:end.Offset]), "\n")
+    if len(lines) > 60 {
+        lines = append(lines[:60], fmt.Sprintf("... (%d more lines; use file_read with offset %d)", len(lines)-60, start.Line+60))
+    }
+    return strings.Join(lines, "\n")
+}
+
+// relativePath shortens path relative to the working directory where possible
+func relativePath(path string) string {
+    if wd, err := os.Getwd(); err == nil && strings.HasPrefix(path, wd+string(os.PathSeparator)) {
+        return path[len(wd)+1:]
+    }
+    return path
+}
+
+func (t *GoSymbolsTool) GetName() string {
+    return "go_symbols"
+}
+
+func (t *GoSymbolsTool) GetDescription() string {
+    return "Navigate Go code with the type checker: list a package's declarations, find a symbol's definition, list its references, or show its signature and doc comment"
+}
+
+func (t *GoSymbolsTool) GetInputSchema() map[string]interface{} {
+    return objectSchema(map[string]interface{}{
+        "action":  map[string]interface{}{"type": "string", "enum": []string{"declarations", "definition", "references", "signature"}, "description": "The lookup to perform"},
+        "package": property("string", "Package pattern to search, e.g. ./agent (default . for declarations, ./... otherwise)"),
+        "symbol":  property("string", "Symbol name: Name, Type.Method, Type.Field or pkg.Name"),
+    }, "action")
+}
+
+func (t *GoSymbolsTool) IsReadOnly() bool {
+    return true
+}
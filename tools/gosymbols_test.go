@@ -0,0 +1,150 @@
+package tools
+
+import (
+    "strings"
+    "testing"
+)
+
+// newSymbolsModule writes a small module with two packages and returns a
+// tool that loads from it
+func newSymbolsModule(t *testing.T) *GoSymbolsTool {
+    t.Helper()
+    dir := chdirTemp(t)
+    writeTree(t, dir, map[string]string{
+        "go.mod": "module example.com/shapes\n\ngo 1.21\n",
+        "geom/geom.go": `package geom
+
+// Point is a position on the plane
+type Point struct {
+    X, Y int
+}
+
+// Shape is anything with an area
+type Shape interface {
+    Area() int
+}
+
+// Rect is an axis-aligned rectangle
+type Rect struct {
+    Min, Max Point // Opposite corners
+}
+
+// Area returns the rectangle's area
+func (r Rect) Area() int {
+    return (r.Max.X - r.Min.X) * (r.Max.Y - r.Min.Y)
+}
+
+// Origin is the zero point
+var Origin = Point{}
+`,
+        "main.go": `package main
+
+import "example.com/shapes/geom"
+
+func main() {
+    r := geom.Rect{Max: geom.Point{X: 2, Y: 3}}
+    println(r.Area(), geom.Origin.X)
+}
+`,
+    })
+    return &GoSymbolsTool{Dir: dir}
+}
+
+func TestGoSymbolsTool(t *testing.T) {
+    tool := newSymbolsModule(t)
+    tests := []struct {
+        name  string
+        input string
+        want  []string
+        not   []string
+    }{
+        {
+            name:  "declarations",
+            input: `{"action":"declarations","package":"./geom"}`,
+            want: []string{
+                "package geom (example.com/shapes/geom)",
+                "geom/geom.go:4  type Point struct",
+                "geom/geom.go:9  type Shape interface",
+                "geom/geom.go:14  type Rect struct",
+                "geom/geom.go:19  func (Rect).Area() int",
+                "geom/geom.go:24  var Origin Point",
+            },
+        },
+        {
+            name:  "definition of a method",
+            input: `{"action":"definition","symbol":"Rect.Area"}`,
+            want: []string{
+                "geom/geom.go:19:15  func (Rect).Area() int",
+                "    func (r Rect) Area() int {",
+                "(r.Max.Y - r.Min.Y)",
+            },
+        },
+        {
+            name:  "package-qualified signature",
+            input: `{"action":"signature","symbol":"geom.Point"}`,
+            want: []string{
+                "type Point struct{X int; Y int}",
+                "declared at geom/geom.go:4 in example.com/shapes/geom",
+                "    // Point is a position on the plane",
+            },
+        },
+        {
+            name:  "field comment as documentation",
+            input: `{"action":"signature","symbol":"Rect.Min"}`,
+            want:  []string{"field Min Point", "    // Opposite corners"},
+        },
+        {
+            name:  "references across packages",
+            input: `{"action":"references","symbol":"Point"}`,
+            want: []string{
+                "3 reference(s):",
+                "geom/geom.go:15:14  Min, Max Point // Opposite corners",
+                "geom/geom.go:24:14  var Origin = Point{}",
+                "main.go:6:30  r := geom.Rect{Max: geom.Point{X: 2, Y: 3}}",
+            },
+        },
+        {
+            name:  "method references",
+            input: `{"action":"references","symbol":"Rect.Area"}`,
+            want:  []string{"1 reference(s):", "main.go:7:15  println(r.Area(), geom.Origin.X)"},
+            not:   []string{"geom/geom.go"},
+        },
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            output, err := tool.Execute(test.input)
+            if err != nil {
+                t.Fatalf("Execute: %v", err)
+            }
+            for _, want := range test.want {
+                if !strings.Contains(output, want) {
+                    t.Errorf("output lacks %q:\n%s", want, output)
+                }
+            }
+            for _, not := range test.not {
+                if strings.Contains(output, not) {
+                    t.Errorf("output contains %q:\n%s", not, output)
+                }
+            }
+        })
+    }
+}
+
+func TestGoSymbolsToolErrors(t *testing.T) {
+    tool := newSymbolsModule(t)
+    tests := []struct {
+        input   string
+        wantErr string
+    }{
+        {input: `{"action":"definition","symbol":"Circle"}`, wantErr: "symbol Circle not found in ./..."},
+        {input: `{"action":"definition","symbol":"Rect.Perimeter"}`, wantErr: "not found"},
+        {input: `{"action":"references"}`, wantErr: "symbol is required for references"},
+        {input: `{"action":"rename","symbol":"Rect"}`, wantErr: "unsupported action: rename"},
+        {input: `{}`, wantErr: "action is required"},
+    }
+    for _, test := range tests {
+        if _, err := tool.Execute(test.input); err == nil || !strings.Contains(err.Error(), test.wantErr) {
+            t.Errorf("Execute(%s) error = %v, want %q", test.input, err, test.wantErr)
+        }
+    }
+}
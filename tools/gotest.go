@@ -0,0 +1,372 @@
+package tools
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "os/exec"
+    "regexp"
+    "sort"
+    "strings"
+    "time"
+)
+
+// DefaultGoTestTimeout bounds a go_test run when no timeout is requested
+const DefaultGoTestTimeout = 5 * time.Minute
+
+// maxFailureLines caps the output shown for each failing test
+const maxFailureLines = 40
+
+// goFileLocation matches file:line references in test and compiler output
+var goFileLocation = regexp.MustCompile(`([\w./\\-]+\.go):(\d+)(?::\d+)?`)
+
+// GoTestRequest defines the structure for go_test operations
+type GoTestRequest struct {
+    Packages []string `json:"packages,omitempty"` // Package patterns, default ./...
+    Run      string   `json:"run,omitempty"`      // -run regular expression
+    Short    bool     `json:"short,omitempty"`
+    Race     bool     `json:"race,omitempty"`
+    Timeout  string   `json:"timeout,omitempty"` // e.g. "2m"
+}
+
+// GoTestTool runs go test -json and summarises the results
+type GoTestTool struct {
+    // Dir is the directory go test runs in; the working directory when empty
+    Dir string
+}
+
+// testEvent is a single line of go test -json output (see go doc test2json)
+type testEvent struct {
+    Action     string
+    Package    string
+    ImportPath string // Set on build-output and build-fail events
+    Test       string
+    Output     string
+    Elapsed    float64
+}
+
+// testKey identifies a test within its package
+type testKey struct {
+    pkg  string
+    test string
+}
+
+// goTestRun accumulates the event stream of a go test run
+type goTestRun struct {
+    output      map[testKey]*strings.Builder
+    failed      []testKey
+    passed      int
+    skipped     int
+    packages    map[string]string // Package -> "ok", "FAIL" or "no test files"
+    elapsed     map[string]float64
+    buildOutput map[string]*strings.Builder
+    stray       strings.Builder // Lines that were not JSON, such as build errors from older toolchains
+}
+
+func (t *GoTestTool) Execute(input string) (string, error) {
+    var request GoTestRequest
+    if !decodeInput(input, &request) {
+        request.Packages = strings.Fields(input)
+    }
+    if len(request.Packages) == 0 {
+        request.Packages = []string{"./..."}
+    }
+    // Patterns starting with '-' would be read as flags such as -exec
+    for _, pkg := range request.Packages {
+        if strings.HasPrefix(pkg, "-") {
+            return "", fmt.Errorf("invalid package %q: package patterns cannot start with '-'", pkg)
+        }
+    }
+
+    timeout := DefaultGoTestTimeout
+    if request.Timeout != "" {
+        parsed, err := time.ParseDuration(request.Timeout)
+        if err != nil {
+            return "", fmt.Errorf("invalid timeout %q: %w", request.Timeout, err)
+        }
+        if parsed <= 0 {
+            return "", fmt.Errorf("invalid timeout %q: must be positive", request.Timeout)
+        }
+        timeout = parsed
+    }
+
+    args := []string{"test", "-json"}
+    if request.Run != "" {
+        args = append(args, "-run", request.Run)
+    }
+    if request.Short {
+        args = append(args, "-short")
+    }
+    if request.Race {
+        args = append(args, "-race")
+    }
+    args = append(args, "-timeout", timeout.String())
+    args = append(args, request.Packages...)
+
+    // Allow a little longer than the test timeout so go test can report it
+    ctx, cancel := context.WithTimeout(context.Background(), timeout+30*time.Second)
+    defer cancel()
+    cmd := exec.CommandContext(ctx, "go", args...)
+    cmd.Dir = t.Dir
+    var stdout, stderr bytes.Buffer
+    cmd.Stdout = &stdout
+    cmd.Stderr = &stderr
+    start := time.Now()
+    runErr := cmd.Run()
+    duration := time.Since(start)
+
+    if ctx.Err() == context.DeadlineExceeded {
+        return "", fmt.Errorf("go test did not finish within %s", timeout)
+    }
+    if _, ok := runErr.(*exec.ExitError); runErr != nil && !ok {
+        return "", fmt.Errorf("failed to run go test: %w", runErr)
+    }
+
+    run := parseTestEvents(stdout.String())
+    run.stray.WriteString(stderr.String())
+    return run.summary(duration), nil
+}
+
+// parseTestEvents reads a go test -json stream
+func parseTestEvents(stream string) *goTestRun {
+    run := &goTestRun{
+        output:      make(map[testKey]*strings.Builder),
+        packages:    make(map[string]string),
+        elapsed:     make(map[string]float64),
+        buildOutput: make(map[string]*strings.Builder),
+    }
+
+    scanner := bufio.NewScanner(strings.NewReader(stream))
+    scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+    for scanner.Scan() {
+        line := scanner.Text()
+        var event testEvent
+        if !strings.HasPrefix(line, "{") || json.Unmarshal([]byte(line), &event) != nil {
+            run.stray.WriteString(line + "\n")
+            continue
+        }
+
+        key := testKey{pkg: event.Package, test: event.Test}
+        switch event.Action {
+        case "build-output":
+            builder := run.buildOutput[event.ImportPath]
+            if builder == nil {
+                builder = &strings.Builder{}
+                run.buildOutput[event.ImportPath] = builder
+            }
+            builder.WriteString(event.Output)
+        case "output":
+            builder := run.output[key]
+            if builder == nil {
+                builder = &strings.Builder{}
+                run.output[key] = builder
+            }
+            builder.WriteString(event.Output)
+        case "pass":
+            if event.Test != "" {
+                run.passed++
+            } else {
+                run.packages[event.Package] = "ok"
+                run.elapsed[event.Package] = event.Elapsed
+            }
+        case "skip":
+            if event.Test != "" {
+                run.skipped++
+            } else {
+                run.packages[event.Package] = "no test files"
+            }
+        case "fail":
+            if event.Test != "" {
+                run.failed = append(run.failed, key)
+            } else {
+                run.packages[event.Package] = "FAIL"
+                run.elapsed[event.Package] = event.Elapsed
+            }
+        }
+    }
+    return run
+}
+
+// summary renders a compact report of the run for the model
+func (r *goTestRun) summary(duration time.Duration) string {
+    var sb strings.Builder
+
+    failedPackages := 0
+    for _, status := range r.packages {
+        if status == "FAIL" {
+            failedPackages++
+        }
+    }
+    buildErrors := r.buildErrors()
+
+    verdict := "PASS"
+    if len(r.failed) > 0 || failedPackages > 0 || len(buildErrors) > 0 {
+        verdict = "FAIL"
+    }
+    sb.WriteString(fmt.Sprintf("%s: %d passed, %d failed, %d skipped across %d package(s) in %.1fs\n",
+        verdict, r.passed, len(r.failed), r.skipped, len(r.packages), duration.Seconds()))
+
+    if len(buildErrors) > 0 {
+        sb.WriteString("\nBuild errors:\n")
+        for _, buildError := range buildErrors {
+            sb.WriteString(indentBlock(buildError, "  "))
+        }
+    }
+
+    if len(r.failed) > 0 {
+        sb.WriteString("\nFailures:\n")
+        for _, key := range r.failed {
+            output := ""
+            if builder := r.output[key]; builder != nil {
+                output = builder.String()
+            }
+            // Parent tests fail when a subtest does; their output repeats the subtest's
+            if r.hasFailedSubtest(key) {
+                continue
+            }
+            sb.WriteString(fmt.Sprintf("--- FAIL: %s (%s)", key.test, key.pkg))
+            if locations := findLocations(output); len(locations) > 0 {
+                sb.WriteString(" at " + strings.Join(locations, ", "))
+            }
+            sb.WriteString("\n")
+            sb.WriteString(indentBlock(trimFailureOutput(output), "    "))
+        }
+    }
+
+    // Packages that failed without a failing test, e.g. a panic in init or TestMain
+    var packages []string
+    for pkg := range r.packages {
+        packages = append(packages, pkg)
+    }
+    sort.Strings(packages)
+    for _, pkg := range packages {
+        if r.packages[pkg] != "FAIL" || r.packageHasFailedTest(pkg) {
+            continue
+        }
+        if builder := r.output[testKey{pkg: pkg}]; builder != nil {
+            if output := trimFailureOutput(builder.String()); strings.TrimSpace(output) != "" {
+                sb.WriteString(fmt.Sprintf("\nPackage %s failed:\n", pkg))
+                sb.WriteString(indentBlock(output, "    "))
+            }
+        }
+    }
+
+    sb.WriteString("\nPackages:\n")
+    for _, pkg := range packages {
+        switch status := r.packages[pkg]; status {
+        case "no test files":
+            sb.WriteString(fmt.Sprintf("  ?    %s [no test files]\n", pkg))
+        default:
+            sb.WriteString(fmt.Sprintf("  %-4s %s (%.2fs)\n", status, pkg, r.elapsed[pkg]))
+        }
+    }
+    return sb.String()
+}
+
+// buildErrors collects compiler output from build-output events and stray lines
+func (r *goTestRun) buildErrors() []string {
+    var errors []string
+    var importPaths []string
+    for importPath := range r.buildOutput {
+        importPaths = append(importPaths, importPath)
+    }
+    sort.Strings(importPaths)
+    for _, importPath := range importPaths {
+        errors = append(errors, strings.TrimRight(r.buildOutput[importPath].String(), "\n"))
+    }
+
+    stray := strings.TrimSpace(r.stray.String())
+    if stray != "" {
+        var lines []string
+        for _, line := range strings.Split(stray, "\n") {
+            // Drop go test's own status lines, which duplicate the package list
+            if strings.HasPrefix(line, "FAIL") || strings.HasPrefix(line, "ok ") {
+                continue
+            }
+            lines = append(lines, line)
+        }
+        if len(lines) > 0 {
+            errors = append(errors, strings.Join(lines, "\n"))
+        }
+    }
+    return errors
+}
+
+// hasFailedSubtest reports whether a failed test has a failed subtest
+func (r *goTestRun) hasFailedSubtest(key testKey) bool {
+    for _, other := range r.failed {
+        if other.pkg == key.pkg && strings.HasPrefix(other.test, key.test+"/") {
+            return true
+        }
+    }
+    return false
+}
+
+func (r *goTestRun) packageHasFailedTest(pkg string) bool {
+    for _, key := range r.failed {
+        if key.pkg == pkg {
+            return true
+        }
+    }
+    return false
+}
+
+// findLocations returns the distinct file:line references in output
+func findLocations(output string) []string {
+    var locations []string
+    seen := make(map[string]bool)
+    for _, match := range goFileLocation.FindAllStringSubmatch(output, -1) {
+        location := match[1] + ":" + match[2]
+        if !seen[location] {
+            seen[location] = true
+            locations = append(locations, location)
+        }
+    }
+    return locations
+}
+
+// trimFailureOutput drops the test framework's RUN/FAIL lines and caps the length
+func trimFailureOutput(output string) string {
+    var lines []string
+    for _, line := range strings.Split(strings.TrimRight(output, "\n"), "\n") {
+        trimmed := strings.TrimSpace(line)
+        if strings.HasPrefix(trimmed, "=== ") || strings.HasPrefix(trimmed, "--- FAIL") || trimmed == "FAIL" || strings.HasPrefix(trimmed, "FAIL\t") {
+            continue
+        }
+        lines = append(lines, line)
+    }
+    if len(lines) > maxFailureLines {
+        omitted := len(lines) - maxFailureLines
+        lines = append(lines[:maxFailureLines], fmt.Sprintf("... (%d more lines)", omitted))
+    }
+    return strings.Join(lines, "\n")
+}
+
+// indentBlock prefixes every line of text and ends it with a newline
+func indentBlock(text, prefix string) string {
+    if strings.TrimSpace(text) == "" {
+        return ""
+    }
+    lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+    return prefix + strings.Join(lines, "\n"+prefix) + "\n"
+}
+
+func (t *GoTestTool) GetName() string {
+    return "go_test"
+}
+
+func (t *GoTestTool) GetDescription() string {
+    return "Run Go tests with go test -json and get a compact summary of passes, failures (with output and file:line locations) and build errors"
+}
+
+func (t *GoTestTool) GetInputSchema() map[string]interface{} {
+    return objectSchema(map[string]interface{}{
+        "packages": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}, "description": "Package patterns to test (default ./...)"},
+        "run":      property("string", "Only run tests matching this regular expression (go test -run)"),
+        "short":    property("boolean", "Pass -short"),
+        "race":     property("boolean", "Enable the race detector"),
+        "timeout":  property("string", fmt.Sprintf("Test timeout such as '30s' or '2m' (default %s)", DefaultGoTestTimeout)),
+    })
+}
@@ -0,0 +1,75 @@
+package tools
+
+import (
+    "os"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestGoTestToolRejectsInput(t *testing.T) {
+    tool := &GoTestTool{Dir: t.TempDir()}
+    tests := []struct {
+        name    string
+        input   string
+        wantErr string
+    }{
+        {name: "flag as package", input: `{"packages":["-exec=sh"]}`, wantErr: "cannot start with '-'"},
+        {name: "output flag as package", input: `{"packages":["./...","-o=/tmp/x"]}`, wantErr: "cannot start with '-'"},
+        {name: "flag in plain text", input: `./... -exec=sh`, wantErr: "cannot start with '-'"},
+        {name: "zero timeout", input: `{"timeout":"0s"}`, wantErr: "must be positive"},
+        {name: "negative timeout", input: `{"timeout":"-1m"}`, wantErr: "must be positive"},
+        {name: "malformed timeout", input: `{"timeout":"soon"}`, wantErr: "invalid timeout"},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            _, err := tool.Execute(test.input)
+            if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+                t.Errorf("Execute(%s) error = %v, want %q", test.input, err, test.wantErr)
+            }
+        })
+    }
+}
+
+// testdata/gotest.json was recorded from go test -json ./... in a module
+// with a passing, a failing, a skipped test and a package that fails to build
+func TestParseTestEvents(t *testing.T) {
+    stream, err := os.ReadFile("testdata/gotest.json")
+    if err != nil {
+        t.Fatal(err)
+    }
+    run := parseTestEvents(string(stream))
+
+    if run.passed != 1 || run.skipped != 1 {
+        t.Errorf("passed %d, skipped %d; want 1 and 1", run.passed, run.skipped)
+    }
+    wantFailed := []testKey{{pkg: "example.com/gt/calc", test: "TestSub/negative"}, {pkg: "example.com/gt/calc", test: "TestSub"}}
+    if !reflect.DeepEqual(run.failed, wantFailed) {
+        t.Errorf("failed = %+v, want %+v", run.failed, wantFailed)
+    }
+    wantPackages := map[string]string{
+        "example.com/gt/broken": "FAIL",
+        "example.com/gt/calc":   "FAIL",
+        "example.com/gt/empty":  "no test files",
+    }
+    if !reflect.DeepEqual(run.packages, wantPackages) {
+        t.Errorf("packages = %v, want %v", run.packages, wantPackages)
+    }
+
+    summary := run.summary(0)
+    for _, want := range []string{
+        "FAIL: 1 passed, 2 failed, 1 skipped across 3 package(s)",
+        `broken/broken.go:3:23: cannot use "x"`,
+        "--- FAIL: TestSub/negative (example.com/gt/calc) at calc_test.go:14",
+        "Add(1, -2) = -1, want 1",
+        "?    example.com/gt/empty [no test files]",
+    } {
+        if !strings.Contains(summary, want) {
+            t.Errorf("summary lacks %q:\n%s", want, summary)
+        }
+    }
+    // The parent's failure only repeats its subtest's
+    if strings.Contains(summary, "--- FAIL: TestSub (") {
+        t.Errorf("summary reports the parent test as well:\n%s", summary)
+    }
+}
@@ -0,0 +1,223 @@
+package tools
+
+import (
+    "bufio"
+    "os"
+    "path"
+    "path/filepath"
+    "strings"
+)
+
+// ignoreFileNames are the per-directory ignore files honoured by FileSearchTool
+var ignoreFileNames = []string{".gitignore", ".ignore"}
+
+// defaultIgnoredDirs are skipped during searches even without an ignore file
+var defaultIgnoredDirs = map[string]bool{
+    ".git":         true,
+    ".hg":          true,
+    ".svn":         true,
+    "node_modules": true,
+    "vendor":       true,
+}
+
+// ignoreRule is a single pattern line from a .gitignore style file
+type ignoreRule struct {
+    base     string // Slash-separated directory of the ignore file, relative to the top ignore directory
+    pattern  string
+    negate   bool
+    dirOnly  bool
+    anchored bool // Pattern contains a slash and matches relative to base
+}
+
+// ignoreMatcher accumulates ignore rules while walking a directory tree
+type ignoreMatcher struct {
+    rules []ignoreRule
+}
+
+// loadDir reads the ignore files of dir, whose path relative to the top
+// ignore directory is rel, and returns a matcher including their rules. The receiver is
+// left unchanged so sibling directories do not see each other's rules.
+func (m *ignoreMatcher) loadDir(dir, rel string) *ignoreMatcher {
+    var added []ignoreRule
+    for _, name := range ignoreFileNames {
+        added = append(added, readIgnoreFile(filepath.Join(dir, name), rel)...)
+    }
+    if len(added) == 0 {
+        return m
+    }
+    rules := make([]ignoreRule, 0, len(m.rules)+len(added))
+    rules = append(rules, m.rules...)
+    rules = append(rules, added...)
+    return &ignoreMatcher{rules: rules}
+}
+
+// ancestorMatcher loads the ignore files that apply to dir from the
+// directories above it, starting at the repository root or, outside a
+// repository, at top. It returns the rules and dir's slash-separated path
+// relative to where they were loaded from, which is "" if dir is that
+// directory itself; dir's own ignore files are left to the caller.
+func ancestorMatcher(top, dir string) (*ignoreMatcher, string) {
+    absDir, err := filepath.Abs(dir)
+    if err != nil {
+        return &ignoreMatcher{}, ""
+    }
+    base := repositoryRoot(absDir)
+    if base == "" {
+        if base, err = filepath.Abs(top); err != nil {
+            return &ignoreMatcher{}, ""
+        }
+    }
+    rel, err := filepath.Rel(base, absDir)
+    if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+        return &ignoreMatcher{}, ""
+    }
+
+    prefix := filepath.ToSlash(rel)
+    matcher := &ignoreMatcher{}
+    current, currentRel := base, ""
+    for _, segment := range strings.Split(prefix, "/") {
+        matcher = matcher.loadDir(current, currentRel)
+        current = filepath.Join(current, segment)
+        currentRel = joinRel(currentRel, segment)
+    }
+    return matcher, prefix
+}
+
+// repositoryRoot returns the nearest directory at or above dir holding a
+// .git entry, or "" if there is none
+func repositoryRoot(dir string) string {
+    for {
+        if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+            return dir
+        }
+        parent := filepath.Dir(dir)
+        if parent == dir {
+            return ""
+        }
+        dir = parent
+    }
+}
+
+// joinRel joins slash-separated relative paths, where "" and "." stand for
+// the directory itself; the result is "" for the directory itself
+func joinRel(prefix, rel string) string {
+    if prefix == "." {
+        prefix = ""
+    }
+    if rel == "." {
+        rel = ""
+    }
+    switch {
+    case prefix == "":
+        return rel
+    case rel == "":
+        return prefix
+    }
+    return prefix + "/" + rel
+}
+
+// readIgnoreFile parses an ignore file, returning no rules if it cannot be read
+func readIgnoreFile(filename, base string) []ignoreRule {
+    file, err := os.Open(filename)
+    if err != nil {
+        return nil
+    }
+    defer file.Close()
+
+    var rules []ignoreRule
+    scanner := bufio.NewScanner(file)
+    for scanner.Scan() {
+        line := strings.TrimRight(scanner.Text(), " \t\r")
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+        rule := ignoreRule{base: base}
+        if strings.HasPrefix(line, "!") {
+            rule.negate = true
+            line = line[1:]
+        }
+        line = strings.TrimPrefix(line, "\\")
+        if strings.HasSuffix(line, "/") {
+            rule.dirOnly = true
+            line = strings.TrimRight(line, "/")
+        }
+        if strings.Contains(line, "/") {
+            rule.anchored = true
+            line = strings.TrimPrefix(line, "/")
+        }
+        if line == "" {
+            continue
+        }
+        rule.pattern = line
+        rules = append(rules, rule)
+    }
+    return rules
+}
+
+// ignored reports whether the slash-separated path rel (relative to the
+// top ignore directory) is excluded. The last matching rule wins, as in git.
+func (m *ignoreMatcher) ignored(rel string, isDir bool) bool {
+    result := false
+    for _, rule := range m.rules {
+        if rule.dirOnly && !isDir {
+            continue
+        }
+        target := rel
+        if rule.base != "" {
+            if !strings.HasPrefix(rel, rule.base+"/") {
+                continue
+            }
+            target = strings.TrimPrefix(rel, rule.base+"/")
+        }
+        var matched bool
+        if rule.anchored {
+            matched = matchGlob(rule.pattern, target)
+        } else {
+            matched = matchGlob(rule.pattern, path.Base(target))
+        }
+        if matched {
+            result = !rule.negate
+        }
+    }
+    return result
+}
+
+// hasGlobMeta reports whether pattern uses glob syntax rather than a plain name
+func hasGlobMeta(pattern string) bool {
+    return strings.ContainsAny(pattern, "*?[")
+}
+
+// matchGlob matches a slash-separated path against a glob pattern in which
+// "**" matches any number of path segments, including none
+func matchGlob(pattern, name string) bool {
+    return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+    for len(pattern) > 0 {
+        if pattern[0] == "**" {
+            // Collapse repeated ** and try every possible split point
+            for len(pattern) > 0 && pattern[0] == "**" {
+                pattern = pattern[1:]
+            }
+            if len(pattern) == 0 {
+                return true
+            }
+            for i := 0; i <= len(name); i++ {
+                if matchSegments(pattern, name[i:]) {
+                    return true
+                }
+            }
+            return false
+        }
+        if len(name) == 0 {
+            return false
+        }
+        if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+            return false
+        }
+        pattern = pattern[1:]
+        name = name[1:]
+    }
+    return len(name) == 0
+}
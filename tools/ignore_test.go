@@ -0,0 +1,150 @@
+package tools
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+// writeTree creates files under dir; names ending in "/" are directories
+func writeTree(t *testing.T, dir string, files map[string]string) {
+    t.Helper()
+    for name, content := range files {
+        path := filepath.Join(dir, filepath.FromSlash(name))
+        if strings.HasSuffix(name, "/") {
+            if err := os.MkdirAll(path, 0755); err != nil {
+                t.Fatal(err)
+            }
+            continue
+        }
+        if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+            t.Fatal(err)
+        }
+        if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+}
+
+func TestMatchGlob(t *testing.T) {
+    tests := []struct {
+        pattern, name string
+        want          bool
+    }{
+        {"*.go", "main.go", true},
+        {"*.go", "main.txt", false},
+        {"*.go", "cmd/main.go", false},
+        {"cmd/*.go", "cmd/main.go", true},
+        {"**/*.go", "main.go", true},
+        {"**/*.go", "a/b/c/main.go", true},
+        {"a/**/c", "a/c", true},
+        {"a/**/c", "a/b/d/c", true},
+        {"a/**", "a/b/c", true},
+        {"a/**/c", "a/b/d", false},
+        {"file?.txt", "file1.txt", true},
+        {"file[0-9].txt", "filex.txt", false},
+    }
+    for _, test := range tests {
+        if got := matchGlob(test.pattern, test.name); got != test.want {
+            t.Errorf("matchGlob(%q, %q) = %v, want %v", test.pattern, test.name, got, test.want)
+        }
+    }
+}
+
+func TestIgnoreMatcher(t *testing.T) {
+    dir := t.TempDir()
+    writeTree(t, dir, map[string]string{
+        ".gitignore":  "# build output\n*.log\n!keep.log\nbuild/\n/root-only.txt\ndocs/*.html\n",
+        "sub/.ignore": "secret.txt\n!debug.log\n",
+    })
+    matcher := (&ignoreMatcher{}).loadDir(dir, "").loadDir(filepath.Join(dir, "sub"), "sub")
+
+    tests := []struct {
+        rel   string
+        isDir bool
+        want  bool
+    }{
+        {"app.log", false, true},
+        {"sub/deep/app.log", false, true},
+        {"keep.log", false, false},
+        {"build", true, true},
+        {"build", false, false},
+        {"root-only.txt", false, true},
+        {"sub/root-only.txt", false, false},
+        {"docs/index.html", false, true},
+        {"docs/api/index.html", false, false},
+        {"sub/secret.txt", false, true},
+        {"secret.txt", false, false},
+        // A nested file's negation only applies below its directory
+        {"sub/debug.log", false, false},
+        {"debug.log", false, true},
+        {"main.go", false, false},
+    }
+    for _, test := range tests {
+        if got := matcher.ignored(test.rel, test.isDir); got != test.want {
+            t.Errorf("ignored(%q, dir=%v) = %v, want %v", test.rel, test.isDir, got, test.want)
+        }
+    }
+}
+
+func TestFileSearchToolIgnoreFiles(t *testing.T) {
+    dir := t.TempDir()
+    writeTree(t, dir, map[string]string{
+        ".git/":                   "",
+        ".gitignore":              "*.log\ngenerated/\n/pkg/api/skip.go\n",
+        "main.go":                 "",
+        "app.log":                 "",
+        "pkg/.gitignore":          "*.tmp\n",
+        "pkg/api/api.go":          "",
+        "pkg/api/skip.go":         "",
+        "pkg/api/trace.log":       "",
+        "pkg/api/scratch.tmp":     "",
+        "pkg/api/generated/x.go":  "",
+        "node_modules/dep/dep.go": "",
+    })
+    tool := &FileSearchTool{RootDir: dir}
+
+    tests := []struct {
+        name    string
+        input   string
+        want    []string
+        notWant []string
+    }{
+        {
+            name:    "whole tree",
+            input:   `{"pattern":"*"}`,
+            want:    []string{"main.go", "api.go"},
+            notWant: []string{"app.log", "trace.log", "scratch.tmp", "skip.go", "x.go", "dep.go"},
+        },
+        {
+            name:    "subdirectory",
+            input:   `{"pattern":"*","path":"pkg/api"}`,
+            want:    []string{"api.go"},
+            notWant: []string{"trace.log", "scratch.tmp", "skip.go", "x.go"},
+        },
+        {
+            name:  "subdirectory including ignored files",
+            input: `{"pattern":"*","path":"pkg/api","include_ignored":true}`,
+            want:  []string{"api.go", "trace.log", "scratch.tmp", "skip.go", "x.go"},
+        },
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            output, err := tool.Execute(test.input)
+            if err != nil {
+                t.Fatalf("Execute: %v", err)
+            }
+            for _, name := range test.want {
+                if !strings.Contains(output, name) {
+                    t.Errorf("output lacks %s:\n%s", name, output)
+                }
+            }
+            for _, name := range test.notWant {
+                if strings.Contains(output, name) {
+                    t.Errorf("output includes ignored %s:\n%s", name, output)
+                }
+            }
+        })
+    }
+}
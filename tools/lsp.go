@@ -0,0 +1,153 @@
+package tools
+
+import (
+    "fmt"
+    "strings"
+
+    "jkneen.ai-agent/lsp"
+)
+
+// maxLSPResults caps the number of locations or symbols returned
+const maxLSPResults = 200
+
+// LSPRequest defines the structure for lsp operations
+type LSPRequest struct {
+    Action   string `json:"action"`              // definition, references, hover, symbols or diagnostics
+    FilePath string `json:"file_path,omitempty"` // File for position-based actions and diagnostics
+    Line     int    `json:"line,omitempty"`      // 1-based line, as shown by file_read
+    Column   int    `json:"column,omitempty"`    // 1-based column; defaults to 1
+    Query    string `json:"query,omitempty"`     // Workspace symbol query
+}
+
+// LSPTool exposes code navigation and diagnostics from a language server
+type LSPTool struct {
+    Client *lsp.Client
+}
+
+func (t *LSPTool) Execute(input string) (string, error) {
+    if t.Client == nil {
+        return "", fmt.Errorf("no language server is configured")
+    }
+    var request LSPRequest
+    if !decodeInput(input, &request) {
+        return "", fmt.Errorf("input must be a JSON object with an action")
+    }
+
+    switch request.Action {
+    case "definition", "references", "hover":
+        if request.FilePath == "" || request.Line < 1 {
+            return "", fmt.Errorf("%s requires file_path and line", request.Action)
+        }
+    case "diagnostics":
+        if request.FilePath == "" {
+            return "", fmt.Errorf("diagnostics requires file_path")
+        }
+    }
+
+    switch request.Action {
+    case "definition":
+        locations, err := t.Client.Definition(request.FilePath, request.Line, request.Column)
+        if err != nil {
+            return "", fmt.Errorf("definition lookup failed: %w", err)
+        }
+        return formatLocations("definition", locations), nil
+    case "references":
+        locations, err := t.Client.References(request.FilePath, request.Line, request.Column)
+        if err != nil {
+            return "", fmt.Errorf("references lookup failed: %w", err)
+        }
+        return formatLocations("reference", locations), nil
+    case "hover":
+        text, err := t.Client.Hover(request.FilePath, request.Line, request.Column)
+        if err != nil {
+            return "", fmt.Errorf("hover failed: %w", err)
+        }
+        if strings.TrimSpace(text) == "" {
+            return "No hover information at that position", nil
+        }
+        return text, nil
+    case "symbols":
+        if request.Query == "" {
+            return "", fmt.Errorf("symbols requires query")
+        }
+        symbols, err := t.Client.WorkspaceSymbols(request.Query)
+        if err != nil {
+            return "", fmt.Errorf("symbol search failed: %w", err)
+        }
+        if len(symbols) == 0 {
+            return fmt.Sprintf("No symbols matching %q", request.Query), nil
+        }
+        var sb strings.Builder
+        sb.WriteString(fmt.Sprintf("%d symbol(s) matching %q:\n", len(symbols), request.Query))
+        for i, symbol := range symbols {
+            if i == maxLSPResults {
+                sb.WriteString(fmt.Sprintf("... (%d more)\n", len(symbols)-maxLSPResults))
+                break
+            }
+            name := symbol.Name
+            if symbol.ContainerName != "" {
+                name += " in " + symbol.ContainerName
+            }
+            sb.WriteString(fmt.Sprintf("%s %s  %s\n", lsp.SymbolKindName(symbol.Kind), name, formatLocation(symbol.Location)))
+        }
+        return sb.String(), nil
+    case "diagnostics":
+        diagnostics, err := t.Client.Diagnose(request.FilePath)
+        if err != nil {
+            return "", fmt.Errorf("diagnostics failed: %w", err)
+        }
+        if diagnostics == "" {
+            return fmt.Sprintf("No diagnostics for %s", request.FilePath), nil
+        }
+        return diagnostics, nil
+    case "":
+        return "", fmt.Errorf("action is required")
+    default:
+        return "", fmt.Errorf("unsupported action: %s. Use definition, references, hover, symbols or diagnostics", request.Action)
+    }
+}
+
+// formatLocations lists locations as path:line:col, relative to the working directory
+func formatLocations(kind string, locations []lsp.Location) string {
+    if len(locations) == 0 {
+        return fmt.Sprintf("No %s found", kind)
+    }
+    var sb strings.Builder
+    sb.WriteString(fmt.Sprintf("%d %s(s):\n", len(locations), kind))
+    for i, location := range locations {
+        if i == maxLSPResults {
+            sb.WriteString(fmt.Sprintf("... (%d more)\n", len(locations)-maxLSPResults))
+            break
+        }
+        sb.WriteString(formatLocation(location) + "\n")
+    }
+    return sb.String()
+}
+
+// formatLocation renders a location as path:line:col with 1-based numbers
+func formatLocation(location lsp.Location) string {
+    path := relativePath(lsp.URIToPath(location.URI))
+    return fmt.Sprintf("%s:%d:%d", path, location.Range.Start.Line+1, location.Range.Start.Character+1)
+}
+
+func (t *LSPTool) GetName() string {
+    return "lsp"
+}
+
+func (t *LSPTool) GetDescription() string {
+    return "Query the language server: go to a symbol's definition, find its references, show hover documentation for a position, search workspace symbols, or get a file's compiler diagnostics"
+}
+
+func (t *LSPTool) GetInputSchema() map[string]interface{} {
+    return objectSchema(map[string]interface{}{
+        "action":    map[string]interface{}{"type": "string", "enum": []string{"definition", "references", "hover", "symbols", "diagnostics"}, "description": "The query to perform"},
+        "file_path": property("string", "File containing the position (definition, references, hover) or to check (diagnostics)"),
+        "line":      property("integer", "1-based line number of the symbol, as shown by file_read"),
+        "column":    property("integer", "1-based column of the symbol within the line (default 1)"),
+        "query":     property("string", "Symbol name or fragment to search for (symbols)"),
+    }, "action")
+}
+
+func (t *LSPTool) IsReadOnly() bool {
+    return true
+}
@@ -0,0 +1,324 @@
+package tools
+
+import (
+    "fmt"
+    "net/url"
+    "regexp"
+    "strings"
+
+    "golang.org/x/net/html"
+    "golang.org/x/net/html/atom"
+)
+
+// skippedElements hold navigation, scripts and other content that isn't
+// part of a page's readable text
+var skippedElements = map[atom.Atom]bool{
+    atom.Head: true, atom.Script: true, atom.Style: true, atom.Noscript: true,
+    atom.Nav: true, atom.Header: true, atom.Footer: true, atom.Aside: true,
+    atom.Form: true, atom.Button: true, atom.Select: true, atom.Input: true,
+    atom.Textarea: true, atom.Iframe: true, atom.Svg: true, atom.Template: true,
+    atom.Canvas: true, atom.Dialog: true,
+}
+
+// blockElements are separated from their surroundings by blank lines
+var blockElements = map[atom.Atom]bool{
+    atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true,
+    atom.Main: true, atom.Body: true, atom.Figure: true, atom.Figcaption: true,
+    atom.Dl: true, atom.Dt: true, atom.Dd: true, atom.Details: true,
+    atom.Summary: true, atom.Address: true, atom.Center: true,
+}
+
+var (
+    whitespaceRun = regexp.MustCompile(`[ \t\r\n\f]+`)
+    blankLines    = regexp.MustCompile(`\n{3,}`)
+)
+
+// HTMLToMarkdown converts an HTML document to readable Markdown, keeping the
+// main content and dropping navigation, scripts and styling. Relative links
+// are resolved against base. It returns the page title separately.
+func HTMLToMarkdown(document string, base *url.URL) (title string, markdown string, err error) {
+    root, err := html.Parse(strings.NewReader(document))
+    if err != nil {
+        return "", "", fmt.Errorf("failed to parse HTML: %w", err)
+    }
+    if titleNode := findElement(root, atom.Title); titleNode != nil {
+        title = strings.TrimSpace(whitespaceRun.ReplaceAllString(textContent(titleNode), " "))
+    }
+
+    // Prefer the page's main content when it marks it up
+    content := findElement(root, atom.Main)
+    if content == nil {
+        content = findElement(root, atom.Article)
+    }
+    if content == nil {
+        content = root
+    }
+
+    converter := &markdownConverter{base: base}
+    markdown = converter.node(content)
+    return title, tidyMarkdown(markdown), nil
+}
+
+// markdownConverter renders HTML nodes as Markdown strings
+type markdownConverter struct {
+    base *url.URL
+}
+
+// node renders n and its descendants
+func (c *markdownConverter) node(n *html.Node) string {
+    switch n.Type {
+    case html.TextNode:
+        return whitespaceRun.ReplaceAllString(n.Data, " ")
+    case html.DocumentNode:
+        return c.children(n)
+    case html.ElementNode:
+    default:
+        return ""
+    }
+    if skippedElements[n.DataAtom] || hasAttr(n, "hidden") || getAttr(n, "aria-hidden") == "true" {
+        return ""
+    }
+
+    switch n.DataAtom {
+    case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+        level := int(n.Data[1] - '0')
+        text := strings.TrimSpace(c.children(n))
+        if text == "" {
+            return ""
+        }
+        return "\n\n" + strings.Repeat("#", level) + " " + strings.ReplaceAll(text, "\n", " ") + "\n\n"
+    case atom.Br:
+        return "\n"
+    case atom.Hr:
+        return "\n\n---\n\n"
+    case atom.Strong, atom.B:
+        return wrapInline(c.children(n), "**")
+    case atom.Em, atom.I:
+        return wrapInline(c.children(n), "*")
+    case atom.Del, atom.S:
+        return wrapInline(c.children(n), "~~")
+    case atom.Code, atom.Kbd, atom.Samp:
+        text := textContent(n)
+        if strings.TrimSpace(text) == "" {
+            return ""
+        }
+        fence := "`"
+        if strings.Contains(text, "`") {
+            fence = "``"
+        }
+        return fence + whitespaceRun.ReplaceAllString(text, " ") + fence
+    case atom.Pre:
+        code := strings.Trim(textContent(n), "\n")
+        language := ""
+        if codeNode := findElement(n, atom.Code); codeNode != nil {
+            for _, class := range strings.Fields(getAttr(codeNode, "class")) {
+                if lang, ok := strings.CutPrefix(class, "language-"); ok {
+                    language = lang
+                }
+            }
+        }
+        return "\n\n```" + language + "\n" + code + "\n```\n\n"
+    case atom.A:
+        text := strings.TrimSpace(c.children(n))
+        href := c.resolve(getAttr(n, "href"))
+        if text == "" || href == "" || strings.HasPrefix(href, "javascript:") || strings.HasPrefix(href, "#") {
+            return text
+        }
+        return "[" + text + "](" + href + ")"
+    case atom.Img:
+        alt := strings.TrimSpace(getAttr(n, "alt"))
+        src := c.resolve(getAttr(n, "src"))
+        if alt == "" || src == "" || strings.HasPrefix(src, "data:") {
+            return ""
+        }
+        return "![" + alt + "](" + src + ")"
+    case atom.Ul, atom.Ol:
+        if n.Parent != nil && n.Parent.DataAtom == atom.Li {
+            // Keep nested lists tight against their parent item
+            return "\n" + c.list(n) + "\n"
+        }
+        return "\n\n" + c.list(n) + "\n\n"
+    case atom.Blockquote:
+        text := strings.TrimSpace(tidyMarkdown(c.children(n)))
+        if text == "" {
+            return ""
+        }
+        return "\n\n> " + strings.ReplaceAll(text, "\n", "\n> ") + "\n\n"
+    case atom.Table:
+        return "\n\n" + c.table(n) + "\n\n"
+    }
+
+    if blockElements[n.DataAtom] {
+        return "\n\n" + c.children(n) + "\n\n"
+    }
+    return c.children(n)
+}
+
+// children renders n's child nodes, dropping spaces at the start of lines
+func (c *markdownConverter) children(n *html.Node) string {
+    var sb strings.Builder
+    for child := n.FirstChild; child != nil; child = child.NextSibling {
+        text := c.node(child)
+        current := sb.String()
+        if current == "" || strings.HasSuffix(current, "\n") || strings.HasSuffix(current, " ") {
+            text = strings.TrimLeft(text, " ")
+        }
+        sb.WriteString(text)
+    }
+    return sb.String()
+}
+
+// list renders a ul or ol, indenting nested content under each item
+func (c *markdownConverter) list(n *html.Node) string {
+    var items []string
+    number := 1
+    if start := getAttr(n, "start"); start != "" {
+        fmt.Sscanf(start, "%d", &number)
+    }
+    for child := n.FirstChild; child != nil; child = child.NextSibling {
+        if child.Type != html.ElementNode || child.DataAtom != atom.Li {
+            continue
+        }
+        marker := "- "
+        if n.DataAtom == atom.Ol {
+            marker = fmt.Sprintf("%d. ", number)
+            number++
+        }
+        text := strings.TrimSpace(tidyMarkdown(c.children(child)))
+        // Nested blocks inside an item are indented to line up with its text
+        text = strings.ReplaceAll(text, "\n", "\n"+strings.Repeat(" ", len(marker)))
+        items = append(items, marker+text)
+    }
+    return strings.Join(items, "\n")
+}
+
+// table renders a table as a Markdown pipe table, treating the first row as the header
+func (c *markdownConverter) table(n *html.Node) string {
+    var rows [][]string
+    var walk func(*html.Node)
+    walk = func(node *html.Node) {
+        for child := node.FirstChild; child != nil; child = child.NextSibling {
+            if child.Type != html.ElementNode {
+                continue
+            }
+            switch child.DataAtom {
+            case atom.Tr:
+                var cells []string
+                for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+                    if cell.Type == html.ElementNode && (cell.DataAtom == atom.Td || cell.DataAtom == atom.Th) {
+                        text := strings.TrimSpace(tidyMarkdown(c.children(cell)))
+                        text = strings.ReplaceAll(strings.ReplaceAll(text, "\n", " "), "|", "\\|")
+                        cells = append(cells, text)
+                    }
+                }
+                if len(cells) > 0 {
+                    rows = append(rows, cells)
+                }
+            case atom.Table:
+                // Nested tables are flattened into their cell's text
+            default:
+                walk(child)
+            }
+        }
+    }
+    walk(n)
+    if len(rows) == 0 {
+        return ""
+    }
+
+    columns := 0
+    for _, row := range rows {
+        if len(row) > columns {
+            columns = len(row)
+        }
+    }
+    var sb strings.Builder
+    for i, row := range rows {
+        for len(row) < columns {
+            row = append(row, "")
+        }
+        sb.WriteString("| " + strings.Join(row, " | ") + " |\n")
+        if i == 0 {
+            sb.WriteString("|" + strings.Repeat(" --- |", columns) + "\n")
+        }
+    }
+    return strings.TrimRight(sb.String(), "\n")
+}
+
+// resolve makes a link absolute against the page URL
+func (c *markdownConverter) resolve(ref string) string {
+    ref = strings.TrimSpace(ref)
+    if ref == "" || c.base == nil {
+        return ref
+    }
+    parsed, err := url.Parse(ref)
+    if err != nil {
+        return ref
+    }
+    return c.base.ResolveReference(parsed).String()
+}
+
+// wrapInline surrounds text with a Markdown emphasis marker, keeping
+// surrounding spaces outside the marker
+func wrapInline(text, marker string) string {
+    trimmed := strings.TrimSpace(text)
+    if trimmed == "" {
+        return text
+    }
+    leading := text[:len(text)-len(strings.TrimLeft(text, " "))]
+    trailing := text[len(strings.TrimRight(text, " ")):]
+    return leading + marker + trimmed + marker + trailing
+}
+
+// tidyMarkdown trims trailing spaces and collapses runs of blank lines
+func tidyMarkdown(markdown string) string {
+    lines := strings.Split(markdown, "\n")
+    for i, line := range lines {
+        lines[i] = strings.TrimRight(line, " \t")
+    }
+    markdown = blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+    return strings.TrimSpace(markdown) + "\n"
+}
+
+// findElement returns the first element of the given type in n's subtree
+func findElement(n *html.Node, element atom.Atom) *html.Node {
+    if n.Type == html.ElementNode && n.DataAtom == element {
+        return n
+    }
+    for child := n.FirstChild; child != nil; child = child.NextSibling {
+        if found := findElement(child, element); found != nil {
+            return found
+        }
+    }
+    return nil
+}
+
+// textContent concatenates the text beneath n
+func textContent(n *html.Node) string {
+    if n.Type == html.TextNode {
+        return n.Data
+    }
+    var sb strings.Builder
+    for child := n.FirstChild; child != nil; child = child.NextSibling {
+        sb.WriteString(textContent(child))
+    }
+    return sb.String()
+}
+
+func getAttr(n *html.Node, key string) string {
+    for _, attr := range n.Attr {
+        if attr.Key == key {
+            return attr.Val
+        }
+    }
+    return ""
+}
+
+func hasAttr(n *html.Node, key string) bool {
+    for _, attr := range n.Attr {
+        if attr.Key == key {
+            return true
+        }
+    }
+    return false
+}
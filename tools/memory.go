@@ -0,0 +1,365 @@
+package tools
+
+import (
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+    "unicode"
+)
+
+// Memory scopes
+const (
+    MemoryScopeUser    = "user"    // Facts about the user, shared by every project
+    MemoryScopeProject = "project" // Facts about the current project
+)
+
+// memoryFileName is the file holding a scope's memories within its directory
+const memoryFileName = "memories.json"
+
+// maxMemoryLength bounds a single memory; memories are meant to be small facts
+const maxMemoryLength = 2000
+
+// DefaultMemoryResults is the number of memories memory_search returns when no limit is given
+const DefaultMemoryResults = 10
+
+// Memory is a small fact remembered across sessions
+type Memory struct {
+    ID      string    `json:"id"`
+    Scope   string    `json:"-"`
+    Content string    `json:"content"`
+    Tags    []string  `json:"tags,omitempty"`
+    Created time.Time `json:"created"`
+}
+
+// MemoryStore keeps user and project memories in JSON files
+type MemoryStore struct {
+    dirs map[string]string // Scope -> directory
+
+    mu sync.Mutex
+}
+
+// NewMemoryStore creates a store keeping user memories in userDir and project
+// memories in projectDir. The directories are created on first save.
+func NewMemoryStore(userDir, projectDir string) *MemoryStore {
+    return &MemoryStore{dirs: map[string]string{
+        MemoryScopeUser:    userDir,
+        MemoryScopeProject: projectDir,
+    }}
+}
+
+// ProjectMemoryDir returns the directory for a project's memories under base,
+// named after the project directory plus a hash of its absolute path
+func ProjectMemoryDir(base, projectDir string) string {
+    absDir, err := filepath.Abs(projectDir)
+    if err != nil {
+        absDir = projectDir
+    }
+    sum := sha256.Sum256([]byte(absDir))
+    return filepath.Join(base, "projects", filepath.Base(absDir)+"-"+hex.EncodeToString(sum[:6]), "memory")
+}
+
+// load reads a scope's memories; the caller holds s.mu
+func (s *MemoryStore) load(scope string) ([]Memory, error) {
+    dir, ok := s.dirs[scope]
+    if !ok || dir == "" {
+        return nil, fmt.Errorf("unknown memory scope %q: use %s or %s", scope, MemoryScopeUser, MemoryScopeProject)
+    }
+    data, err := os.ReadFile(filepath.Join(dir, memoryFileName))
+    if os.IsNotExist(err) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to read memories: %w", err)
+    }
+    var memories []Memory
+    if err := json.Unmarshal(data, &memories); err != nil {
+        return nil, fmt.Errorf("invalid memory file %s: %w", filepath.Join(dir, memoryFileName), err)
+    }
+    for i := range memories {
+        memories[i].Scope = scope
+    }
+    return memories, nil
+}
+
+// store writes a scope's memories; the caller holds s.mu
+func (s *MemoryStore) store(scope string, memories []Memory) error {
+    dir := s.dirs[scope]
+    if err := os.MkdirAll(dir, 0755); err != nil {
+        return fmt.Errorf("failed to create memory directory: %w", err)
+    }
+    data, err := json.MarshalIndent(memories, "", "  ")
+    if err != nil {
+        return err
+    }
+    return WriteFileAtomic(filepath.Join(dir, memoryFileName), data, 0644)
+}
+
+// Save remembers content in the given scope. Saving a fact that is already
+// remembered returns the existing memory.
+func (s *MemoryStore) Save(scope, content string, tags []string) (Memory, error) {
+    content = strings.TrimSpace(content)
+    if content == "" {
+        return Memory{}, fmt.Errorf("content is required")
+    }
+    if len(content) > maxMemoryLength {
+        return Memory{}, fmt.Errorf("memory is %d characters; keep memories under %d and save separate facts separately", len(content), maxMemoryLength)
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    memories, err := s.load(scope)
+    if err != nil {
+        return Memory{}, err
+    }
+    for _, memory := range memories {
+        if strings.EqualFold(memory.Content, content) {
+            return memory, nil
+        }
+    }
+
+    memory := Memory{ID: newMemoryID(scope), Scope: scope, Content: content, Tags: tags, Created: time.Now()}
+    if err := s.store(scope, append(memories, memory)); err != nil {
+        return Memory{}, err
+    }
+    return memory, nil
+}
+
+// Delete forgets the memory with the given ID
+func (s *MemoryStore) Delete(id string) (Memory, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    for _, scope := range []string{MemoryScopeUser, MemoryScopeProject} {
+        memories, err := s.load(scope)
+        if err != nil {
+            return Memory{}, err
+        }
+        for i, memory := range memories {
+            if memory.ID == id {
+                if err := s.store(scope, append(memories[:i], memories[i+1:]...)); err != nil {
+                    return Memory{}, err
+                }
+                return memory, nil
+            }
+        }
+    }
+    return Memory{}, fmt.Errorf("no memory with id %s", id)
+}
+
+// All returns every memory, user memories first, oldest first within a scope
+func (s *MemoryStore) All() ([]Memory, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    var all []Memory
+    for _, scope := range []string{MemoryScopeUser, MemoryScopeProject} {
+        memories, err := s.load(scope)
+        if err != nil {
+            return nil, err
+        }
+        all = append(all, memories...)
+    }
+    return all, nil
+}
+
+// Search returns up to limit memories matching query, best matches first.
+// Memories match when they contain any of the query's words; those containing
+// more of them rank higher. An empty query returns the newest memories.
+func (s *MemoryStore) Search(query string, limit int) ([]Memory, error) {
+    all, err := s.All()
+    if err != nil {
+        return nil, err
+    }
+    terms := memoryTerms(query)
+
+    type scored struct {
+        memory Memory
+        score  int
+    }
+    var matches []scored
+    for _, memory := range all {
+        score := 0
+        if len(terms) > 0 {
+            words := make(map[string]bool)
+            for _, word := range memoryTerms(memory.Content + " " + strings.Join(memory.Tags, " ")) {
+                words[word] = true
+            }
+            for _, term := range terms {
+                if words[term] {
+                    score += 2
+                } else if strings.Contains(strings.ToLower(memory.Content), term) {
+                    score++
+                }
+            }
+            if score == 0 {
+                continue
+            }
+        }
+        matches = append(matches, scored{memory: memory, score: score})
+    }
+    sort.SliceStable(matches, func(i, j int) bool {
+        if matches[i].score != matches[j].score {
+            return matches[i].score > matches[j].score
+        }
+        return matches[i].memory.Created.After(matches[j].memory.Created)
+    })
+
+    var results []Memory
+    for _, match := range matches {
+        if limit > 0 && len(results) == limit {
+            break
+        }
+        results = append(results, match.memory)
+    }
+    return results, nil
+}
+
+// memoryTerms splits text into lowercase words for matching
+func memoryTerms(text string) []string {
+    return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+        return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+    })
+}
+
+// newMemoryID returns a short random ID prefixed with the scope's initial
+func newMemoryID(scope string) string {
+    buf := make([]byte, 4)
+    rand.Read(buf)
+    return scope[:1] + "-" + hex.EncodeToString(buf)
+}
+
+// formatMemory renders a memory on one line for the model
+func formatMemory(memory Memory) string {
+    line := fmt.Sprintf("[%s] (%s) %s", memory.ID, memory.Scope, memory.Content)
+    if len(memory.Tags) > 0 {
+        line += " #" + strings.Join(memory.Tags, " #")
+    }
+    return line
+}
+
+// MemorySaveTool stores a fact for future sessions
+type MemorySaveTool struct {
+    Store *MemoryStore
+}
+
+func (t *MemorySaveTool) Execute(input string) (string, error) {
+    var request struct {
+        Content string   `json:"content"`
+        Scope   string   `json:"scope,omitempty"`
+        Tags    []string `json:"tags,omitempty"`
+    }
+    if !decodeInput(input, &request) {
+        request.Content = input
+    }
+    if request.Scope == "" {
+        request.Scope = MemoryScopeProject
+    }
+    memory, err := t.Store.Save(request.Scope, request.Content, request.Tags)
+    if err != nil {
+        return "", err
+    }
+    return "Saved memory " + formatMemory(memory), nil
+}
+
+func (t *MemorySaveTool) GetName() string {
+    return "memory_save"
+}
+
+func (t *MemorySaveTool) GetDescription() string {
+    return "Remember a small, durable fact for future sessions, such as a user preference (scope user) or a project convention (scope project). Save one fact per call; don't save things that are obvious from the code"
+}
+
+func (t *MemorySaveTool) GetInputSchema() map[string]interface{} {
+    return objectSchema(map[string]interface{}{
+        "content": property("string", "The fact to remember, as a self-contained sentence"),
+        "scope":   map[string]interface{}{"type": "string", "enum": []string{MemoryScopeUser, MemoryScopeProject}, "description": "user for facts about the user across projects, project (default) for facts about this project"},
+        "tags":    map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}, "description": "Optional keywords to help find the memory later"},
+    }, "content")
+}
+
+// MemorySearchTool finds saved memories
+type MemorySearchTool struct {
+    Store *MemoryStore
+}
+
+func (t *MemorySearchTool) Execute(input string) (string, error) {
+    request := struct {
+        Query string `json:"query"`
+        Limit int    `json:"limit,omitempty"`
+    }{Query: strings.TrimSpace(input)}
+    decodeInput(input, &request)
+    if request.Limit <= 0 {
+        request.Limit = DefaultMemoryResults
+    }
+    memories, err := t.Store.Search(request.Query, request.Limit)
+    if err != nil {
+        return "", err
+    }
+    if len(memories) == 0 {
+        return "No matching memories", nil
+    }
+    lines := make([]string, len(memories))
+    for i, memory := range memories {
+        lines[i] = formatMemory(memory)
+    }
+    return strings.Join(lines, "\n"), nil
+}
+
+func (t *MemorySearchTool) GetName() string {
+    return "memory_search"
+}
+
+func (t *MemorySearchTool) GetDescription() string {
+    return "Search memories saved in earlier sessions by keyword; an empty query lists the newest"
+}
+
+func (t *MemorySearchTool) GetInputSchema() map[string]interface{} {
+    return objectSchema(map[string]interface{}{
+        "query": property("string", "Keywords to search for"),
+        "limit": property("integer", fmt.Sprintf("Maximum number of memories to return (default %d)", DefaultMemoryResults)),
+    })
+}
+
+func (t *MemorySearchTool) IsReadOnly() bool {
+    return true
+}
+
+// MemoryDeleteTool forgets a saved memory
+type MemoryDeleteTool struct {
+    Store *MemoryStore
+}
+
+func (t *MemoryDeleteTool) Execute(input string) (string, error) {
+    request := struct {
+        ID string `json:"id"`
+    }{ID: strings.TrimSpace(input)}
+    decodeInput(input, &request)
+    if request.ID == "" {
+        return "", fmt.Errorf("id is required")
+    }
+    memory, err := t.Store.Delete(strings.TrimSpace(request.ID))
+    if err != nil {
+        return "", err
+    }
+    return "Deleted memory " + formatMemory(memory), nil
+}
+
+func (t *MemoryDeleteTool) GetName() string {
+    return "memory_delete"
+}
+
+func (t *MemoryDeleteTool) GetDescription() string {
+    return "Delete a saved memory that is wrong or out of date, by the id shown in brackets"
+}
+
+func (t *MemoryDeleteTool) GetInputSchema() map[string]interface{} {
+    return objectSchema(map[string]interface{}{
+        "id": property("string", "The memory's id, e.g. p-1a2b3c4d"),
+    }, "id")
+}
@@ -0,0 +1,138 @@
+package tools
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestMemoryStore(t *testing.T) {
+    dir := t.TempDir()
+    userDir, projectDir := filepath.Join(dir, "user"), filepath.Join(dir, "project", "memory")
+    store := NewMemoryStore(userDir, projectDir)
+
+    tabs, err := store.Save(MemoryScopeUser, "  Prefers tabs over spaces ", []string{"style"})
+    if err != nil {
+        t.Fatalf("Save: %v", err)
+    }
+    if tabs.Content != "Prefers tabs over spaces" || !strings.HasPrefix(tabs.ID, "u-") || tabs.Scope != MemoryScopeUser {
+        t.Errorf("saved memory = %+v", tabs)
+    }
+    tests, err := store.Save(MemoryScopeProject, "Run the integration tests with make itest", nil)
+    if err != nil {
+        t.Fatalf("Save: %v", err)
+    }
+    if !strings.HasPrefix(tests.ID, "p-") {
+        t.Errorf("project memory ID = %s", tests.ID)
+    }
+
+    // Saving the same fact again returns the existing memory
+    again, err := store.Save(MemoryScopeUser, "prefers TABS over spaces", nil)
+    if err != nil || again.ID != tabs.ID {
+        t.Errorf("saving a duplicate = %+v, %v; want %s", again, err, tabs.ID)
+    }
+
+    // A new store reads what the first one wrote
+    reloaded := NewMemoryStore(userDir, projectDir)
+    all, err := reloaded.All()
+    if err != nil {
+        t.Fatalf("All: %v", err)
+    }
+    if len(all) != 2 || all[0].ID != tabs.ID || all[1].ID != tests.ID || all[0].Scope != MemoryScopeUser || all[1].Scope != MemoryScopeProject {
+        t.Fatalf("reloaded memories = %+v", all)
+    }
+    if len(all[0].Tags) != 1 || all[0].Tags[0] != "style" || !all[0].Created.Equal(tabs.Created) {
+        t.Errorf("reloaded %+v, want %+v", all[0], tabs)
+    }
+
+    results, err := reloaded.Search("which tests to run", 0)
+    if err != nil {
+        t.Fatalf("Search: %v", err)
+    }
+    if len(results) != 1 || results[0].ID != tests.ID {
+        t.Errorf("Search = %+v, want %s", results, tests.ID)
+    }
+    // Tags count as words
+    if results, _ := reloaded.Search("style", 0); len(results) != 1 || results[0].ID != tabs.ID {
+        t.Errorf("Search by tag = %+v, want %s", results, tabs.ID)
+    }
+    // An empty query lists the newest first
+    if results, _ := reloaded.Search("", 1); len(results) != 1 || results[0].ID != tests.ID {
+        t.Errorf("Search with no query = %+v, want %s", results, tests.ID)
+    }
+
+    forgotten, err := reloaded.Delete(tabs.ID)
+    if err != nil || forgotten.Content != tabs.Content {
+        t.Fatalf("Delete = %+v, %v", forgotten, err)
+    }
+    if _, err := reloaded.Delete(tabs.ID); err == nil || !strings.Contains(err.Error(), "no memory with id") {
+        t.Errorf("deleting twice: %v", err)
+    }
+    all, _ = NewMemoryStore(userDir, projectDir).All()
+    if len(all) != 1 || all[0].ID != tests.ID {
+        t.Errorf("memories after Delete = %+v", all)
+    }
+}
+
+func TestMemoryStoreErrors(t *testing.T) {
+    dir := t.TempDir()
+    store := NewMemoryStore(filepath.Join(dir, "user"), "")
+
+    if _, err := store.Save(MemoryScopeUser, " ", nil); err == nil || !strings.Contains(err.Error(), "content is required") {
+        t.Errorf("saving nothing: %v", err)
+    }
+    if _, err := store.Save(MemoryScopeUser, strings.Repeat("x", maxMemoryLength+1), nil); err == nil || !strings.Contains(err.Error(), "keep memories under") {
+        t.Errorf("saving a long memory: %v", err)
+    }
+    if _, err := store.Save("team", "fact", nil); err == nil || !strings.Contains(err.Error(), `unknown memory scope "team"`) {
+        t.Errorf("saving to an unknown scope: %v", err)
+    }
+    // Without a project directory the project scope is unavailable
+    if _, err := store.Save(MemoryScopeProject, "fact", nil); err == nil || !strings.Contains(err.Error(), "unknown memory scope") {
+        t.Errorf("saving without a project directory: %v", err)
+    }
+
+    if err := os.MkdirAll(filepath.Join(dir, "user"), 0755); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(filepath.Join(dir, "user", memoryFileName), []byte("{not json"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := store.Save(MemoryScopeUser, "fact", nil); err == nil || !strings.Contains(err.Error(), "invalid memory file") {
+        t.Errorf("saving over a corrupt file: %v", err)
+    }
+}
+
+func TestMemoryTools(t *testing.T) {
+    store := NewMemoryStore(filepath.Join(t.TempDir(), "user"), filepath.Join(t.TempDir(), "project"))
+    save := &MemorySaveTool{Store: store}
+    search := &MemorySearchTool{Store: store}
+    forget := &MemoryDeleteTool{Store: store}
+
+    // Plain text is saved to the project scope
+    output, err := save.Execute("The API server listens on port 8080")
+    if err != nil || !strings.Contains(output, "(project) The API server listens on port 8080") {
+        t.Fatalf("memory_save = %q, %v", output, err)
+    }
+    if _, err := save.Execute(`{"content": "Lives in Lisbon", "scope": "user", "tags": ["location"]}`); err != nil {
+        t.Fatalf("memory_save: %v", err)
+    }
+
+    output, err = search.Execute(`{"query": "lisbon"}`)
+    if err != nil || !strings.Contains(output, "(user) Lives in Lisbon #location") || strings.Contains(output, "8080") {
+        t.Errorf("memory_search = %q, %v", output, err)
+    }
+    if output, _ := search.Execute("kubernetes"); output != "No matching memories" {
+        t.Errorf("memory_search with no match = %q", output)
+    }
+
+    all, _ := store.All()
+    output, err = forget.Execute(all[0].ID)
+    if err != nil || !strings.Contains(output, "Deleted memory ["+all[0].ID+"]") {
+        t.Errorf("memory_delete = %q, %v", output, err)
+    }
+    if _, err := forget.Execute(`{"id": ""}`); err == nil {
+        t.Error("memory_delete without an id succeeded")
+    }
+}
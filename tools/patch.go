@@ -0,0 +1,721 @@
+package tools
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+)
+
+// maxPatchFuzz is how many leading and trailing context lines a hunk may
+// ignore when its full context cannot be found, as with patch --fuzz
+const maxPatchFuzz = 2
+
+// ApplyPatchRequest defines the structure for apply_patch operations
+type ApplyPatchRequest struct {
+    Patch string `json:"patch"`
+}
+
+// ApplyPatchTool applies a unified diff to one or more files. Every hunk is
+// validated before anything is written, and files are only changed if the
+// whole patch applies.
+type ApplyPatchTool struct {
+    // Tracker, if set, is used to refuse patches to files changed on disk since they were read
+    Tracker *FileTracker
+}
+
+// filePatch is the part of a patch that concerns a single file
+type filePatch struct {
+    oldPath  string // Empty when the file is created
+    newPath  string // Empty when the file is deleted
+    isNew    bool
+    isDelete bool
+    hunks    []hunk
+}
+
+// path returns the name used to refer to the file in reports
+func (p *filePatch) path() string {
+    if p.newPath != "" {
+        return p.newPath
+    }
+    return p.oldPath
+}
+
+// paths returns the files the section reads or writes
+func (p *filePatch) paths() []string {
+    var paths []string
+    if p.oldPath != "" {
+        paths = append(paths, p.oldPath)
+    }
+    if p.newPath != "" && p.newPath != p.oldPath {
+        paths = append(paths, p.newPath)
+    }
+    return paths
+}
+
+// hunk is a single @@ section of a unified diff
+type hunk struct {
+    header   string
+    oldStart int
+    lines    []hunkLine
+    // Set by "\ No newline at end of file" markers
+    oldNoEOL bool
+    newNoEOL bool
+}
+
+// hunkLine is one line of a hunk; op is ' ', '-' or '+'
+type hunkLine struct {
+    op   byte
+    text string
+}
+
+// contextBounds returns how many context lines lead and trail the hunk
+func (h *hunk) contextBounds() (int, int) {
+    leading := 0
+    for leading < len(h.lines) && h.lines[leading].op == ' ' {
+        leading++
+    }
+    trailing := 0
+    for trailing < len(h.lines)-leading && h.lines[len(h.lines)-1-trailing].op == ' ' {
+        trailing++
+    }
+    return leading, trailing
+}
+
+// oldLines returns the lines the hunk expects to find
+func (h *hunk) oldLines() []string {
+    var lines []string
+    for _, line := range h.lines {
+        if line.op != '+' {
+            lines = append(lines, line.text)
+        }
+    }
+    return lines
+}
+
+// newLines returns the lines the hunk replaces them with
+func (h *hunk) newLines() []string {
+    var lines []string
+    for _, line := range h.lines {
+        if line.op != '-' {
+            lines = append(lines, line.text)
+        }
+    }
+    return lines
+}
+
+// parsePatch parses a unified diff, including git's extended headers for
+// new, deleted and renamed files
+func parsePatch(patch string) ([]*filePatch, error) {
+    lines := strings.Split(strings.ReplaceAll(patch, "\r\n", "\n"), "\n")
+    var files []*filePatch
+    var current *filePatch
+    var currentHunk *hunk
+    // Lines still expected in the current hunk, from its @@ header
+    oldLeft, newLeft := 0, 0
+
+    for i := 0; i < len(lines); i++ {
+        line := lines[i]
+
+        // Inside a hunk every line belongs to it until the counts from its
+        // header are used up, even ones that look like file headers
+        if currentHunk != nil && (oldLeft > 0 || newLeft > 0) {
+            op := byte(' ')
+            text := ""
+            switch {
+            case strings.HasPrefix(line, `\`):
+                markNoEOL(currentHunk)
+                continue
+            case line == "" && i < len(lines)-1:
+                // Some tools strip the leading space from empty context lines
+            case strings.HasPrefix(line, " ") || strings.HasPrefix(line, "-") || strings.HasPrefix(line, "+"):
+                op, text = line[0], line[1:]
+            default:
+                return nil, fmt.Errorf("line %d: hunk %q ends early, expecting %d more old and %d more new lines; check the line counts in its header",
+                    i+1, currentHunk.header, oldLeft, newLeft)
+            }
+            if (op != '+' && oldLeft == 0) || (op != '-' && newLeft == 0) {
+                return nil, fmt.Errorf("line %d: hunk %q has more lines than its header counts", i+1, currentHunk.header)
+            }
+            if op != '+' {
+                oldLeft--
+            }
+            if op != '-' {
+                newLeft--
+            }
+            currentHunk.lines = append(currentHunk.lines, hunkLine{op: op, text: text})
+            continue
+        }
+
+        switch {
+        case strings.HasPrefix(line, "diff --git "):
+            current = &filePatch{}
+            files = append(files, current)
+            currentHunk = nil
+            if oldPath, newPath, ok := parseGitDiffLine(line); ok {
+                current.oldPath, current.newPath = oldPath, newPath
+            }
+
+        case current != nil && currentHunk == nil && strings.HasPrefix(line, "new file mode"):
+            current.isNew = true
+            current.oldPath = ""
+
+        case current != nil && currentHunk == nil && strings.HasPrefix(line, "deleted file mode"):
+            current.isDelete = true
+            current.newPath = ""
+
+        case current != nil && currentHunk == nil && strings.HasPrefix(line, "rename from "):
+            current.oldPath = strings.TrimPrefix(line, "rename from ")
+
+        case current != nil && currentHunk == nil && strings.HasPrefix(line, "rename to "):
+            current.newPath = strings.TrimPrefix(line, "rename to ")
+
+        case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+            // Reuse the section opened by a "diff --git" line, otherwise start a new one
+            if current == nil || len(current.hunks) > 0 {
+                current = &filePatch{}
+                files = append(files, current)
+            }
+            oldPath := parsePatchPath(strings.TrimPrefix(line, "--- "), "a/")
+            newPath := parsePatchPath(strings.TrimPrefix(lines[i+1], "+++ "), "b/")
+            current.oldPath, current.newPath = oldPath, newPath
+            current.isNew = oldPath == ""
+            current.isDelete = newPath == ""
+            currentHunk = nil
+            i++
+
+        case strings.HasPrefix(line, "@@"):
+            if current == nil {
+                return nil, fmt.Errorf("line %d: hunk without a file header", i+1)
+            }
+            oldStart, oldCount, newCount, err := parseHunkHeader(line)
+            if err != nil {
+                return nil, fmt.Errorf("line %d: %w", i+1, err)
+            }
+            current.hunks = append(current.hunks, hunk{header: line, oldStart: oldStart})
+            currentHunk = &current.hunks[len(current.hunks)-1]
+            oldLeft, newLeft = oldCount, newCount
+
+        case currentHunk != nil && strings.HasPrefix(line, `\`):
+            // A marker for the hunk's last line
+            markNoEOL(currentHunk)
+
+        case currentHunk != nil && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "-") || strings.HasPrefix(line, "+")):
+            return nil, fmt.Errorf("line %d: hunk %q has more lines than its header counts", i+1, currentHunk.header)
+
+        default:
+            // Anything else (index lines, commit messages, blank separators) ends the hunk
+            currentHunk = nil
+        }
+    }
+    if currentHunk != nil && (oldLeft > 0 || newLeft > 0) {
+        return nil, fmt.Errorf("patch ends inside hunk %q, expecting %d more old and %d more new lines; check the line counts in its header",
+            currentHunk.header, oldLeft, newLeft)
+    }
+
+    var result []*filePatch
+    for _, file := range files {
+        if file.oldPath == "" && file.newPath == "" {
+            continue
+        }
+        if len(file.hunks) == 0 && !file.isDelete && !file.isNew && file.oldPath == file.newPath {
+            continue
+        }
+        result = append(result, file)
+    }
+    if len(result) == 0 {
+        return nil, fmt.Errorf("no file changes found in patch; expected a unified diff with ---/+++ headers and @@ hunks")
+    }
+
+    // Sections are planned against the files on disk independently, so a
+    // second section for a path would silently overwrite the first
+    seen := make(map[string]bool)
+    for _, file := range result {
+        for _, path := range file.paths() {
+            cleaned := filepath.Clean(path)
+            if seen[cleaned] {
+                return nil, fmt.Errorf("%s appears in more than one section of the patch; put all of its hunks in one section", path)
+            }
+            seen[cleaned] = true
+        }
+    }
+    return result, nil
+}
+
+// parseGitDiffLine extracts the paths from a "diff --git a/x b/y" line
+func parseGitDiffLine(line string) (string, string, bool) {
+    rest := strings.TrimPrefix(line, "diff --git ")
+    if !strings.HasPrefix(rest, "a/") {
+        return "", "", false
+    }
+    index := strings.Index(rest, " b/")
+    if index == -1 {
+        return "", "", false
+    }
+    return rest[2:index], rest[index+3:], true
+}
+
+// parsePatchPath cleans a ---/+++ path, returning "" for /dev/null
+func parsePatchPath(path, prefix string) string {
+    // Drop a trailing timestamp as written by diff -u
+    if tab := strings.Index(path, "\t"); tab != -1 {
+        path = path[:tab]
+    }
+    path = strings.TrimSpace(path)
+    if path == "/dev/null" {
+        return ""
+    }
+    return strings.TrimPrefix(path, prefix)
+}
+
+// markNoEOL applies a "\ No newline at end of file" marker to the hunk's
+// preceding line
+func markNoEOL(h *hunk) {
+    n := len(h.lines)
+    if n == 0 {
+        return
+    }
+    switch h.lines[n-1].op {
+    case '-':
+        h.oldNoEOL = true
+    case '+':
+        h.newNoEOL = true
+    default:
+        h.oldNoEOL = true
+        h.newNoEOL = true
+    }
+}
+
+// parseHunkHeader returns the old start line and the old and new line counts
+// of an "@@ -l,s +l,s @@" header. Omitted counts are 1.
+func parseHunkHeader(header string) (int, int, int, error) {
+    fields := strings.Fields(header)
+    if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+        return 0, 0, 0, fmt.Errorf("malformed hunk header %q", header)
+    }
+    oldStart, oldCount, err := parseHunkRange(strings.TrimPrefix(fields[1], "-"))
+    if err != nil {
+        return 0, 0, 0, fmt.Errorf("malformed hunk header %q", header)
+    }
+    _, newCount, err := parseHunkRange(strings.TrimPrefix(fields[2], "+"))
+    if err != nil {
+        return 0, 0, 0, fmt.Errorf("malformed hunk header %q", header)
+    }
+    return oldStart, oldCount, newCount, nil
+}
+
+// parseHunkRange parses the "l,s" or "l" part of a hunk header
+func parseHunkRange(field string) (int, int, error) {
+    start, count, hasCount := strings.Cut(field, ",")
+    startLine, err := strconv.Atoi(start)
+    if err != nil {
+        return 0, 0, err
+    }
+    if !hasCount {
+        return startLine, 1, nil
+    }
+    lineCount, err := strconv.Atoi(count)
+    if err != nil || lineCount < 0 {
+        return 0, 0, fmt.Errorf("invalid line count %q", count)
+    }
+    return startLine, lineCount, nil
+}
+
+// fileContent is a file split into lines plus whether it ends with a newline
+type fileContent struct {
+    lines []string
+    eol   bool
+}
+
+func splitContent(data []byte) fileContent {
+    text := string(data)
+    if text == "" {
+        return fileContent{eol: true}
+    }
+    eol := strings.HasSuffix(text, "\n")
+    return fileContent{lines: strings.Split(strings.TrimSuffix(text, "\n"), "\n"), eol: eol}
+}
+
+func (c fileContent) bytes() []byte {
+    if len(c.lines) == 0 {
+        return nil
+    }
+    text := strings.Join(c.lines, "\n")
+    if c.eol {
+        text += "\n"
+    }
+    return []byte(text)
+}
+
+// applyHunks applies the hunks in order, returning a description of each
+// hunk's outcome and whether all of them applied
+func applyHunks(content fileContent, hunks []hunk) (fileContent, []string, bool) {
+    lines := append([]string(nil), content.lines...)
+    eol := content.eol
+    var report []string
+    ok := true
+    offset := 0 // Shift between the hunk headers' line numbers and the working copy
+    minPos := 0 // Hunks may not overlap earlier ones
+
+    for i, h := range hunks {
+        oldLines := h.oldLines()
+        newLines := h.newLines()
+        expected := h.oldStart - 1 + offset
+        if len(oldLines) == 0 {
+            // Pure insertion: the header names the line after which to insert
+            expected = h.oldStart + offset
+        }
+
+        leading, trailing := h.contextBounds()
+        maxFuzz := maxPatchFuzz
+        if leading < maxFuzz {
+            maxFuzz = leading
+        }
+        if trailing < maxFuzz {
+            maxFuzz = trailing
+        }
+        pos, fuzz, loose := findHunk(lines, oldLines, expected, minPos, maxFuzz)
+        if pos == -1 {
+            ok = false
+            report = append(report, fmt.Sprintf("hunk %d (%s) FAILED: context not found. Expected these lines:\n%s",
+                i+1, h.header, indentLines(oldLines)))
+            continue
+        }
+
+        // Fuzz ignores context lines at both ends, which are then left as they
+        // are. Context lines keep the file's text in case whitespace differed.
+        matched := len(oldLines) - 2*fuzz
+        replacement := make([]string, 0, len(newLines))
+        filePos := pos
+        for _, line := range h.lines[fuzz : len(h.lines)-fuzz] {
+            switch line.op {
+            case ' ':
+                replacement = append(replacement, lines[filePos])
+                filePos++
+            case '-':
+                filePos++
+            case '+':
+                replacement = append(replacement, line.text)
+            }
+        }
+
+        updated := make([]string, 0, len(lines)-matched+len(replacement))
+        updated = append(updated, lines[:pos]...)
+        updated = append(updated, replacement...)
+        updated = append(updated, lines[pos+matched:]...)
+
+        // End-of-file newline changes only matter when the hunk touches the last line
+        if pos+matched == len(lines) {
+            if h.newNoEOL {
+                eol = false
+            } else if h.oldNoEOL {
+                eol = true
+            }
+        }
+        lines = updated
+
+        actual := pos - fuzz
+        status := fmt.Sprintf("hunk %d applied at line %d", i+1, actual+1)
+        var notes []string
+        if shift := actual - expected; shift != 0 && len(oldLines) > 0 {
+            notes = append(notes, fmt.Sprintf("offset %+d", shift))
+        }
+        if fuzz > 0 {
+            notes = append(notes, fmt.Sprintf("fuzz %d", fuzz))
+        }
+        if loose {
+            notes = append(notes, "ignoring whitespace differences")
+        }
+        if len(notes) > 0 {
+            status += " (" + strings.Join(notes, ", ") + ")"
+        }
+        report = append(report, status)
+
+        offset += len(replacement) - matched
+        minPos = pos + len(replacement)
+    }
+
+    return fileContent{lines: lines, eol: eol}, report, ok
+}
+
+// findHunk locates oldLines in lines, preferring the position closest to
+// expected. It first requires an exact match, then tolerates whitespace
+// differences, then drops up to maxFuzz context lines from each end.
+// It returns the match position (of the possibly reduced block), the fuzz
+// used, and whether whitespace was ignored; pos is -1 if nothing matched.
+func findHunk(lines, oldLines []string, expected, minPos, maxFuzz int) (int, int, bool) {
+    if len(oldLines) == 0 {
+        if expected < minPos {
+            expected = minPos
+        }
+        if expected > len(lines) {
+            expected = len(lines)
+        }
+        return expected, 0, false
+    }
+
+    for fuzz := 0; fuzz <= maxFuzz; fuzz++ {
+        if fuzz > 0 && 2*fuzz >= len(oldLines) {
+            break
+        }
+        block := oldLines[fuzz : len(oldLines)-fuzz]
+        for _, loose := range []bool{false, true} {
+            if pos := searchBlock(lines, block, expected+fuzz, minPos, loose); pos != -1 {
+                return pos, fuzz, loose
+            }
+        }
+    }
+    return -1, 0, false
+}
+
+// searchBlock finds block in lines at or after minPos, searching outwards
+// from expected
+func searchBlock(lines, block []string, expected, minPos int, loose bool) int {
+    last := len(lines) - len(block)
+    if last < minPos {
+        return -1
+    }
+    if expected < minPos {
+        expected = minPos
+    }
+    if expected > last {
+        expected = last
+    }
+    for distance := 0; expected-distance >= minPos || expected+distance <= last; distance++ {
+        if pos := expected - distance; pos >= minPos && blockMatches(lines[pos:], block, loose) {
+            return pos
+        }
+        if pos := expected + distance; distance > 0 && pos <= last && blockMatches(lines[pos:], block, loose) {
+            return pos
+        }
+    }
+    return -1
+}
+
+func blockMatches(lines, block []string, loose bool) bool {
+    for i, want := range block {
+        got := lines[i]
+        if loose {
+            got, want = strings.Join(strings.Fields(got), " "), strings.Join(strings.Fields(want), " ")
+        }
+        if got != want {
+            return false
+        }
+    }
+    return true
+}
+
+func indentLines(lines []string) string {
+    var sb strings.Builder
+    for _, line := range lines {
+        sb.WriteString("    | " + line + "\n")
+    }
+    return strings.TrimRight(sb.String(), "\n")
+}
+
+// plannedChange is the validated outcome of patching one file
+type plannedChange struct {
+    patch      *filePatch
+    content    []byte
+    mode       os.FileMode
+    report     []string
+    oldContent []byte // For rolling back; nil if the target did not exist
+    oldExists  bool
+}
+
+func (t *ApplyPatchTool) Execute(input string) (string, error) {
+    request := ApplyPatchRequest{Patch: input}
+    if decodeInput(input, &request) && request.Patch == "" {
+        return "", fmt.Errorf("patch is required")
+    }
+
+    patches, err := parsePatch(request.Patch)
+    if err != nil {
+        return "", err
+    }
+
+    // Validate every file and hunk before touching the filesystem
+    var changes []*plannedChange
+    var failures []string
+    for _, patch := range patches {
+        change, problems := t.plan(patch)
+        if len(problems) > 0 {
+            failures = append(failures, fmt.Sprintf("%s:\n  %s", patch.path(), strings.Join(problems, "\n  ")))
+            continue
+        }
+        changes = append(changes, change)
+    }
+    if len(failures) > 0 {
+        return "", fmt.Errorf("patch not applied, no files were changed:\n%s", strings.Join(failures, "\n"))
+    }
+
+    if err := t.commit(changes); err != nil {
+        return "", err
+    }
+
+    var sb strings.Builder
+    sb.WriteString(fmt.Sprintf("Applied patch to %d file(s):\n", len(changes)))
+    for _, change := range changes {
+        patch := change.patch
+        switch {
+        case patch.isNew:
+            sb.WriteString(fmt.Sprintf("- created %s\n", patch.newPath))
+        case patch.isDelete:
+            sb.WriteString(fmt.Sprintf("- deleted %s\n", patch.oldPath))
+        case patch.oldPath != patch.newPath:
+            sb.WriteString(fmt.Sprintf("- renamed %s -> %s\n", patch.oldPath, patch.newPath))
+        default:
+            sb.WriteString(fmt.Sprintf("- modified %s\n", patch.newPath))
+        }
+        for _, line := range change.report {
+            sb.WriteString("    " + line + "\n")
+        }
+    }
+    return sb.String(), nil
+}
+
+// plan computes the new content of a file, returning problems instead if
+// the patch does not apply cleanly
+func (t *ApplyPatchTool) plan(patch *filePatch) (*plannedChange, []string) {
+    change := &plannedChange{patch: patch, mode: 0644}
+
+    var original fileContent
+    if !patch.isNew {
+        info, err := os.Stat(patch.oldPath)
+        if err != nil {
+            return nil, []string{fmt.Sprintf("cannot read %s: %v", patch.oldPath, err)}
+        }
+        if info.IsDir() {
+            return nil, []string{fmt.Sprintf("%s is a directory", patch.oldPath)}
+        }
+        data, err := os.ReadFile(patch.oldPath)
+        if err != nil {
+            return nil, []string{fmt.Sprintf("cannot read %s: %v", patch.oldPath, err)}
+        }
+        if err := t.Tracker.Check(patch.oldPath, info, data); err != nil {
+            return nil, []string{err.Error()}
+        }
+        change.mode = info.Mode().Perm()
+        original = splitContent(data)
+    } else if _, err := os.Stat(patch.newPath); err == nil {
+        return nil, []string{fmt.Sprintf("cannot create %s: file already exists", patch.newPath)}
+    } else {
+        original = fileContent{eol: true}
+    }
+
+    if !patch.isDelete && patch.newPath != patch.oldPath && !patch.isNew {
+        if _, err := os.Stat(patch.newPath); err == nil {
+            return nil, []string{fmt.Sprintf("cannot rename to %s: file already exists", patch.newPath)}
+        }
+    }
+
+    updated, report, ok := applyHunks(original, patch.hunks)
+    change.report = report
+    if !ok {
+        return nil, report
+    }
+    if patch.isDelete && len(updated.lines) > 0 {
+        return nil, []string{"deletion hunks do not remove the whole file; it has changed since the diff was made"}
+    }
+    change.content = updated.bytes()
+    return change, nil
+}
+
+// commit writes all planned changes, rolling back the ones already made if
+// any step fails
+func (t *ApplyPatchTool) commit(changes []*plannedChange) error {
+    type undo struct {
+        path    string
+        content []byte
+        existed bool
+        mode    os.FileMode
+    }
+    var undos []undo
+
+    // remember records how to revert path before it is modified
+    remember := func(path string) {
+        info, err := os.Stat(path)
+        if err != nil {
+            undos = append(undos, undo{path: path})
+            return
+        }
+        data, _ := os.ReadFile(path)
+        undos = append(undos, undo{path: path, content: data, existed: true, mode: info.Mode().Perm()})
+    }
+
+    rollback := func() {
+        for i := len(undos) - 1; i >= 0; i-- {
+            u := undos[i]
+            if u.existed {
+                WriteFileAtomic(u.path, u.content, u.mode)
+            } else {
+                os.Remove(u.path)
+            }
+        }
+    }
+
+    for _, change := range changes {
+        patch := change.patch
+        if patch.newPath != "" {
+            remember(patch.newPath)
+            if err := os.MkdirAll(filepath.Dir(patch.newPath), 0755); err != nil {
+                rollback()
+                return fmt.Errorf("failed to create directory for %s, patch rolled back: %w", patch.newPath, err)
+            }
+            if err := WriteFileAtomic(patch.newPath, change.content, change.mode); err != nil {
+                rollback()
+                return fmt.Errorf("failed to write %s, patch rolled back: %w", patch.newPath, err)
+            }
+        }
+        if patch.oldPath != "" && patch.oldPath != patch.newPath {
+            remember(patch.oldPath)
+            if err := os.Remove(patch.oldPath); err != nil {
+                rollback()
+                return fmt.Errorf("failed to remove %s, patch rolled back: %w", patch.oldPath, err)
+            }
+        }
+    }
+
+    for _, change := range changes {
+        if change.patch.newPath != "" {
+            t.Tracker.Record(change.patch.newPath, change.content)
+        }
+        if change.patch.oldPath != "" && change.patch.oldPath != change.patch.newPath {
+            t.Tracker.Forget(change.patch.oldPath)
+        }
+    }
+    return nil
+}
+
+func (t *ApplyPatchTool) GetName() string {
+    return "apply_patch"
+}
+
+func (t *ApplyPatchTool) GetDescription() string {
+    return "Apply a unified diff (as produced by diff -u or git diff) to one or more files at once. Supports creating (--- /dev/null), deleting (+++ /dev/null) and renaming (git rename from/rename to) files. The line counts in each @@ header must match the hunk. Hunks are matched with some tolerance for shifted lines and whitespace; if any hunk fails, no file is changed"
+}
+
+func (t *ApplyPatchTool) GetInputSchema() map[string]interface{} {
+    return objectSchema(map[string]interface{}{
+        "patch": property("string", "The unified diff to apply, with paths relative to the working directory"),
+    }, "patch")
+}
+
+func (t *ApplyPatchTool) MutatedFiles(input string) []string {
+    request := ApplyPatchRequest{Patch: input}
+    decodeInput(input, &request)
+    patches, err := parsePatch(request.Patch)
+    if err != nil {
+        return nil
+    }
+    var paths []string
+    for _, patch := range patches {
+        paths = append(paths, patch.paths()...)
+    }
+    return paths
+}
+
+func (t *ApplyPatchTool) FileTracker() *FileTracker {
+    return t.Tracker
+}
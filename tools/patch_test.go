@@ -0,0 +1,244 @@
+package tools
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+// chdirTemp changes to a new temporary directory for the rest of the test,
+// since patch paths are relative to the working directory
+func chdirTemp(t *testing.T) string {
+    t.Helper()
+    dir := t.TempDir()
+    previous, err := os.Getwd()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := os.Chdir(dir); err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { os.Chdir(previous) })
+    return dir
+}
+
+func readFile(t *testing.T, path string) string {
+    t.Helper()
+    data, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return string(data)
+}
+
+func TestApplyPatchTool(t *testing.T) {
+    tests := []struct {
+        name       string
+        files      map[string]string
+        patch      string
+        want       map[string]string // Expected contents; "" means the file must not exist
+        wantReport string
+    }{
+        {
+            name:  "modify",
+            files: map[string]string{"a.txt": "one\ntwo\nthree\n"},
+            patch: "--- a/a.txt\n+++ b/a.txt\n@@ -1,3 +1,3 @@\n one\n-two\n+TWO\n three\n",
+            want:  map[string]string{"a.txt": "one\nTWO\nthree\n"},
+        },
+        {
+            name:       "add",
+            patch:      "--- /dev/null\n+++ b/dir/new.txt\n@@ -0,0 +1,2 @@\n+hello\n+world\n",
+            want:       map[string]string{"dir/new.txt": "hello\nworld\n"},
+            wantReport: "created dir/new.txt",
+        },
+        {
+            name:       "delete",
+            files:      map[string]string{"gone.txt": "x\ny\n"},
+            patch:      "diff --git a/gone.txt b/gone.txt\ndeleted file mode 100644\n--- a/gone.txt\n+++ /dev/null\n@@ -1,2 +0,0 @@\n-x\n-y\n",
+            want:       map[string]string{"gone.txt": ""},
+            wantReport: "deleted gone.txt",
+        },
+        {
+            name:       "rename",
+            files:      map[string]string{"old.txt": "keep\n"},
+            patch:      "diff --git a/old.txt b/new.txt\nsimilarity index 100%\nrename from old.txt\nrename to new.txt\n",
+            want:       map[string]string{"old.txt": "", "new.txt": "keep\n"},
+            wantReport: "renamed old.txt -> new.txt",
+        },
+        {
+            name:       "rename with changes",
+            files:      map[string]string{"old.go": "package a\n\nfunc F() {}\n"},
+            patch:      "diff --git a/old.go b/new.go\nrename from old.go\nrename to new.go\n--- a/old.go\n+++ b/new.go\n@@ -1,3 +1,3 @@\n package a\n \n-func F() {}\n+func G() {}\n",
+            want:       map[string]string{"old.go": "", "new.go": "package a\n\nfunc G() {}\n"},
+            wantReport: "renamed old.go -> new.go",
+        },
+        {
+            name:       "offset",
+            files:      map[string]string{"a.txt": "new1\nnew2\nnew3\none\ntwo\nthree\n"},
+            patch:      "--- a/a.txt\n+++ b/a.txt\n@@ -1,3 +1,3 @@\n one\n-two\n+TWO\n three\n",
+            want:       map[string]string{"a.txt": "new1\nnew2\nnew3\none\nTWO\nthree\n"},
+            wantReport: "applied at line 4 (offset +3)",
+        },
+        {
+            name:       "fuzz",
+            files:      map[string]string{"a.txt": "ZERO\none\ntwo\nTHREE\n"},
+            patch:      "--- a/a.txt\n+++ b/a.txt\n@@ -1,4 +1,4 @@\n zero\n one\n-two\n+TWO\n three\n",
+            want:       map[string]string{"a.txt": "ZERO\none\nTWO\nTHREE\n"},
+            wantReport: "fuzz 1",
+        },
+        {
+            name:       "whitespace differences",
+            files:      map[string]string{"a.go": "func f() {\n\treturn  1\n}\n"},
+            patch:      "--- a/a.go\n+++ b/a.go\n@@ -1,3 +1,3 @@\n func f() {\n-    return 1\n+\treturn 2\n }\n",
+            want:       map[string]string{"a.go": "func f() {\n\treturn 2\n}\n"},
+            wantReport: "ignoring whitespace differences",
+        },
+        {
+            name:  "add a newline at end of file",
+            files: map[string]string{"a.txt": "one\ntwo"},
+            patch: "--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1,2 @@\n one\n-two\n\\ No newline at end of file\n+two\n",
+            want:  map[string]string{"a.txt": "one\ntwo\n"},
+        },
+        {
+            name:  "remove the newline at end of file",
+            files: map[string]string{"a.txt": "one\ntwo\n"},
+            patch: "--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1,2 @@\n one\n-two\n+two\n\\ No newline at end of file\n",
+            want:  map[string]string{"a.txt": "one\ntwo"},
+        },
+        {
+            name:  "several files",
+            files: map[string]string{"a.txt": "a\n", "b.txt": "b\n"},
+            patch: "--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-a\n+A\n--- a/b.txt\n+++ b/b.txt\n@@ -1 +1 @@\n-b\n+B\n",
+            want:  map[string]string{"a.txt": "A\n", "b.txt": "B\n"},
+        },
+        {
+            name:  "hunk line that looks like a file header",
+            files: map[string]string{"a.txt": "-- a\nx\n"},
+            patch: "--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1,2 @@\n--- a\n+++ b\n x\n",
+            want:  map[string]string{"a.txt": "++ b\nx\n"},
+        },
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            dir := chdirTemp(t)
+            writeTree(t, dir, test.files)
+            output, err := (&ApplyPatchTool{}).Execute(test.patch)
+            if err != nil {
+                t.Fatalf("Execute: %v", err)
+            }
+            if !strings.Contains(output, test.wantReport) {
+                t.Errorf("report lacks %q:\n%s", test.wantReport, output)
+            }
+            for name, want := range test.want {
+                data, err := os.ReadFile(name)
+                switch {
+                case want == "" && !os.IsNotExist(err):
+                    t.Errorf("%s still exists", name)
+                case want != "" && (err != nil || string(data) != want):
+                    t.Errorf("%s = %q, %v; want %q", name, data, err, want)
+                }
+            }
+        })
+    }
+}
+
+func TestApplyPatchToolRejects(t *testing.T) {
+    tests := []struct {
+        name    string
+        patch   string
+        wantErr string
+    }{
+        {
+            name:    "too many hunk lines",
+            patch:   "--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1,2 @@\n one\n-two\n+TWO\n three\n",
+            wantErr: "more lines than its header counts",
+        },
+        {
+            name:    "too few hunk lines",
+            patch:   "--- a/a.txt\n+++ b/a.txt\n@@ -1,4 +1,4 @@\n one\n-two\n+TWO\n three\n",
+            wantErr: "expecting 1 more old and 1 more new lines",
+        },
+        {
+            name:    "hunk cut short by another section",
+            patch:   "--- a/a.txt\n+++ b/a.txt\n@@ -1,3 +1,3 @@\n one\n-two\n+TWO\ndiff --git a/b.txt b/b.txt\n",
+            wantErr: "ends early",
+        },
+        {
+            name:    "malformed header",
+            patch:   "--- a/a.txt\n+++ b/a.txt\n@@ -x +1 @@\n-one\n+ONE\n",
+            wantErr: "malformed hunk header",
+        },
+        {
+            name:    "not a diff",
+            patch:   "please change two to TWO",
+            wantErr: "no file changes found",
+        },
+        {
+            name:    "two sections for one file",
+            patch:   "--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-one\n+ONE\n--- a/a.txt\n+++ b/a.txt\n@@ -3 +3 @@\n-three\n+THREE\n",
+            wantErr: "a.txt appears in more than one section",
+        },
+        {
+            name:    "context not found",
+            patch:   "--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1,2 @@\n one\n-five\n+FIVE\n",
+            wantErr: "context not found",
+        },
+        {
+            name:    "create an existing file",
+            patch:   "--- /dev/null\n+++ b/a.txt\n@@ -0,0 +1 @@\n+new\n",
+            wantErr: "file already exists",
+        },
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            dir := chdirTemp(t)
+            original := "one\ntwo\nthree\n"
+            writeTree(t, dir, map[string]string{"a.txt": original})
+            _, err := (&ApplyPatchTool{}).Execute(test.patch)
+            if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+                t.Fatalf("Execute error = %v, want %q", err, test.wantErr)
+            }
+            if got := readFile(t, "a.txt"); got != original {
+                t.Errorf("a.txt = %q after a rejected patch, want it unchanged", got)
+            }
+        })
+    }
+}
+
+func TestApplyPatchToolRollsBack(t *testing.T) {
+    dir := chdirTemp(t)
+    writeTree(t, dir, map[string]string{"a.txt": "one\n", "old.txt": "old\n", "blocker": "a file, not a directory\n"})
+    // Every section is valid on its own, but blocker/new.txt cannot be
+    // written after a.txt and the rename have been
+    patch := "--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-one\n+ONE\n" +
+        "diff --git a/old.txt b/renamed.txt\nrename from old.txt\nrename to renamed.txt\n" +
+        "--- /dev/null\n+++ b/blocker/new.txt\n@@ -0,0 +1 @@\n+new\n"
+
+    _, err := (&ApplyPatchTool{}).Execute(patch)
+    if err == nil || !strings.Contains(err.Error(), "rolled back") {
+        t.Fatalf("Execute error = %v, want a rollback", err)
+    }
+    if got := readFile(t, "a.txt"); got != "one\n" {
+        t.Errorf("a.txt = %q, want the original restored", got)
+    }
+    if got := readFile(t, "old.txt"); got != "old\n" {
+        t.Errorf("old.txt = %q, want the original restored", got)
+    }
+    if _, err := os.Stat(filepath.Join(dir, "renamed.txt")); !os.IsNotExist(err) {
+        t.Errorf("renamed.txt exists after the rollback")
+    }
+}
+
+func TestApplyPatchToolRefusesStaleFiles(t *testing.T) {
+    dir := chdirTemp(t)
+    writeTree(t, dir, map[string]string{"a.txt": "one\n"})
+    tracker := NewFileTracker()
+    tracker.Record("a.txt", []byte("one\n"))
+    writeTree(t, dir, map[string]string{"a.txt": "changed elsewhere\n"})
+
+    _, err := (&ApplyPatchTool{Tracker: tracker}).Execute("--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-changed elsewhere\n+ONE\n")
+    if err == nil || !strings.Contains(err.Error(), "modified on disk since") {
+        t.Fatalf("Execute error = %v, want the file reported as changed since it was read", err)
+    }
+}
@@ -0,0 +1,237 @@
+package tools
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "regexp"
+    "runtime"
+    "sort"
+    "strings"
+    "time"
+)
+
+// DefaultPluginTimeout bounds a plugin call when the plugin doesn't set its own
+const DefaultPluginTimeout = 60 * time.Second
+
+// pluginDescribeTimeout bounds the describe call made when loading a plugin
+const pluginDescribeTimeout = 5 * time.Second
+
+// maxPluginOutput caps the stdout read from a plugin
+const maxPluginOutput = 1 << 20
+
+// errPluginTimeout is returned by runPlugin when the plugin exceeds its deadline
+var errPluginTimeout = errors.New("timed out")
+
+// validToolName matches the tool names the model API accepts
+var validToolName = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// PluginDescription is what a plugin prints in response to `describe`
+type PluginDescription struct {
+    Name        string                 `json:"name"`
+    Description string                 `json:"description"`
+    InputSchema map[string]interface{} `json:"input_schema,omitempty"`
+    ReadOnly    bool                   `json:"read_only,omitempty"` // Safe to run concurrently with other read-only tools
+    Timeout     string                 `json:"timeout,omitempty"`   // e.g. "2m"; defaults to DefaultPluginTimeout
+}
+
+// pluginResponse is what a plugin prints after handling a call
+type pluginResponse struct {
+    Output string `json:"output"`
+    Error  string `json:"error,omitempty"`
+}
+
+// PluginTool is a tool implemented by an external executable. The executable
+// is run as `<path> describe` to learn its name and schema, and as `<path>`
+// with the tool input as a JSON object on stdin for each call. It must print
+// {"output": "..."} or {"error": "..."} on stdout.
+type PluginTool struct {
+    Path        string
+    Description PluginDescription
+    Timeout     time.Duration
+}
+
+// LoadPlugin runs the executable's describe call and returns the tool it provides
+func LoadPlugin(path string) (*PluginTool, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), pluginDescribeTimeout)
+    defer cancel()
+    stdout, err := runPlugin(ctx, path, []string{"describe"}, nil)
+    if err != nil {
+        return nil, fmt.Errorf("plugin %s: describe failed: %w", path, err)
+    }
+
+    var description PluginDescription
+    if err := json.Unmarshal(stdout, &description); err != nil {
+        return nil, fmt.Errorf("plugin %s: invalid describe output: %w", path, err)
+    }
+    if !validToolName.MatchString(description.Name) {
+        return nil, fmt.Errorf("plugin %s: invalid tool name %q", path, description.Name)
+    }
+    if description.Description == "" {
+        return nil, fmt.Errorf("plugin %s: description is required", path)
+    }
+    if description.InputSchema == nil {
+        description.InputSchema = objectSchema(map[string]interface{}{
+            "input": property("string", "Input for the tool"),
+        })
+    }
+
+    tool := &PluginTool{Path: path, Description: description, Timeout: DefaultPluginTimeout}
+    if description.Timeout != "" {
+        timeout, err := time.ParseDuration(description.Timeout)
+        if err != nil || timeout <= 0 {
+            return nil, fmt.Errorf("plugin %s: invalid timeout %q", path, description.Timeout)
+        }
+        tool.Timeout = timeout
+    }
+    return tool, nil
+}
+
+// LoadPlugins loads every executable in dir. Plugins that fail to load are
+// skipped and reported in the returned errors; a missing dir is not an error.
+func LoadPlugins(dir string) ([]*PluginTool, []error) {
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        if os.IsNotExist(err) {
+            return nil, nil
+        }
+        return nil, []error{fmt.Errorf("failed to read plugin directory: %w", err)}
+    }
+
+    var plugins []*PluginTool
+    var errs []error
+    seen := make(map[string]string)
+    for _, entry := range entries {
+        path := filepath.Join(dir, entry.Name())
+        if !isExecutable(path) {
+            continue
+        }
+        plugin, err := LoadPlugin(path)
+        if err != nil {
+            errs = append(errs, err)
+            continue
+        }
+        if other, ok := seen[plugin.GetName()]; ok {
+            errs = append(errs, fmt.Errorf("plugin %s: tool name %q is already provided by %s", path, plugin.GetName(), other))
+            continue
+        }
+        seen[plugin.GetName()] = path
+        plugins = append(plugins, plugin)
+    }
+    sort.Slice(plugins, func(i, j int) bool { return plugins[i].GetName() < plugins[j].GetName() })
+    return plugins, errs
+}
+
+// isExecutable reports whether path is a regular file the plugin loader should run
+func isExecutable(path string) bool {
+    info, err := os.Stat(path)
+    if err != nil || !info.Mode().IsRegular() || strings.HasPrefix(filepath.Base(path), ".") {
+        return false
+    }
+    if runtime.GOOS == "windows" {
+        switch strings.ToLower(filepath.Ext(path)) {
+        case ".exe", ".bat", ".cmd":
+            return true
+        }
+        return false
+    }
+    return info.Mode().Perm()&0111 != 0
+}
+
+// runPlugin runs the executable and returns its stdout, reporting stderr on failure
+func runPlugin(ctx context.Context, path string, args []string, stdin []byte) ([]byte, error) {
+    cmd := exec.CommandContext(ctx, path, args...)
+    // Don't wait forever for grandchildren that inherited the output pipes
+    cmd.WaitDelay = 2 * time.Second
+    if stdin != nil {
+        cmd.Stdin = bytes.NewReader(stdin)
+    }
+    var stdout, stderr bytes.Buffer
+    cmd.Stdout = &limitedBuffer{buffer: &stdout, limit: maxPluginOutput}
+    cmd.Stderr = &limitedBuffer{buffer: &stderr, limit: 64 * 1024}
+
+    err := cmd.Run()
+    if ctx.Err() == context.DeadlineExceeded {
+        return nil, errPluginTimeout
+    }
+    if err != nil {
+        message := strings.TrimSpace(stderr.String())
+        if message == "" {
+            return nil, err
+        }
+        return nil, fmt.Errorf("%w: %s", err, message)
+    }
+    return stdout.Bytes(), nil
+}
+
+// limitedBuffer discards writes beyond limit so a runaway plugin can't exhaust memory
+type limitedBuffer struct {
+    buffer *bytes.Buffer
+    limit  int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+    if remaining := b.limit - b.buffer.Len(); remaining > 0 {
+        if len(p) > remaining {
+            b.buffer.Write(p[:remaining])
+        } else {
+            b.buffer.Write(p)
+        }
+    }
+    return len(p), nil
+}
+
+func (t *PluginTool) Execute(input string) (string, error) {
+    // Plugins always receive a JSON object; plain text is wrapped as {"input": ...}
+    var payload []byte
+    var object map[string]interface{}
+    if decodeInput(input, &object) {
+        payload = []byte(strings.TrimSpace(input))
+    } else {
+        payload, _ = json.Marshal(map[string]string{"input": input})
+    }
+
+    timeout := t.Timeout
+    if timeout <= 0 {
+        timeout = DefaultPluginTimeout
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), timeout)
+    defer cancel()
+    stdout, err := runPlugin(ctx, t.Path, nil, payload)
+    if err != nil {
+        if errors.Is(err, errPluginTimeout) {
+            return "", fmt.Errorf("plugin %s timed out after %s", t.GetName(), timeout)
+        }
+        return "", fmt.Errorf("plugin %s failed: %w", t.GetName(), err)
+    }
+
+    var response pluginResponse
+    if err := json.Unmarshal(bytes.TrimSpace(stdout), &response); err != nil {
+        return "", fmt.Errorf("plugin %s returned invalid JSON: %w", t.GetName(), err)
+    }
+    if response.Error != "" {
+        return "", fmt.Errorf("%s", response.Error)
+    }
+    return response.Output, nil
+}
+
+func (t *PluginTool) GetName() string {
+    return t.Description.Name
+}
+
+func (t *PluginTool) GetDescription() string {
+    return t.Description.Description
+}
+
+func (t *PluginTool) GetInputSchema() map[string]interface{} {
+    return t.Description.InputSchema
+}
+
+func (t *PluginTool) IsReadOnly() bool {
+    return t.Description.ReadOnly
+}
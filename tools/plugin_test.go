@@ -0,0 +1,98 @@
+package tools
+
+import (
+    "reflect"
+    "runtime"
+    "strings"
+    "testing"
+    "time"
+)
+
+// testdata/plugins holds shell script plugins, one of them broken and one
+// reusing another's tool name
+func loadTestPlugins(t *testing.T) map[string]*PluginTool {
+    t.Helper()
+    if runtime.GOOS == "windows" {
+        t.Skip("the plugin fixtures are shell scripts")
+    }
+    plugins, errs := LoadPlugins("testdata/plugins")
+    var messages []string
+    for _, err := range errs {
+        messages = append(messages, err.Error())
+    }
+    if len(errs) != 2 ||
+        !strings.Contains(messages[0], "plugin testdata/plugins/broken: invalid describe output") ||
+        !strings.Contains(messages[1], `plugin testdata/plugins/zz_duplicate: tool name "echo_input" is already provided by testdata/plugins/echo_input`) {
+        t.Errorf("LoadPlugins errors = %q", messages)
+    }
+
+    byName := make(map[string]*PluginTool)
+    var names []string
+    for _, plugin := range plugins {
+        byName[plugin.GetName()] = plugin
+        names = append(names, plugin.GetName())
+    }
+    if want := []string{"echo_input", "failing", "slow"}; !reflect.DeepEqual(names, want) {
+        t.Fatalf("loaded plugins %v, want %v", names, want)
+    }
+    return byName
+}
+
+func TestLoadPlugins(t *testing.T) {
+    plugins := loadTestPlugins(t)
+
+    echo := plugins["echo_input"]
+    if echo.GetDescription() != "Echo the tool input" || !echo.IsReadOnly() || echo.Timeout != 10*time.Second {
+        t.Errorf("echo_input description %q, read-only %v, timeout %s", echo.GetDescription(), echo.IsReadOnly(), echo.Timeout)
+    }
+    if required := echo.GetInputSchema()["required"]; !reflect.DeepEqual(required, []interface{}{"text"}) {
+        t.Errorf("echo_input schema required = %v", required)
+    }
+
+    // Without a schema of its own a plugin takes a single input string
+    failing := plugins["failing"]
+    if failing.IsReadOnly() || failing.Timeout != DefaultPluginTimeout {
+        t.Errorf("failing read-only %v, timeout %s", failing.IsReadOnly(), failing.Timeout)
+    }
+    if _, ok := failing.GetInputSchema()["properties"].(map[string]interface{})["input"]; !ok {
+        t.Errorf("failing schema = %v, want the default input property", failing.GetInputSchema())
+    }
+
+    if plugins, errs := LoadPlugins("testdata/no-such-dir"); plugins != nil || errs != nil {
+        t.Errorf("LoadPlugins on a missing directory = %v, %v", plugins, errs)
+    }
+}
+
+func TestPluginToolExecute(t *testing.T) {
+    plugins := loadTestPlugins(t)
+    tests := []struct {
+        name    string
+        plugin  string
+        input   string
+        want    string
+        wantErr string
+    }{
+        {name: "JSON input", plugin: "echo_input", input: ` {"text": "hi"} `, want: `got {"text": "hi"}`},
+        {name: "plain text input", plugin: "echo_input", input: `say "hi"`, want: `got {"input":"say \"hi\""}`},
+        {name: "error response", plugin: "failing", input: `{}`, wantErr: "nothing to do"},
+        {name: "non-zero exit", plugin: "failing", input: `crash`, wantErr: "plugin failing failed: exit status 3: plugin crashed"},
+        {name: "timeout", plugin: "slow", input: `{}`, wantErr: "plugin slow timed out after 200ms"},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            output, err := plugins[test.plugin].Execute(test.input)
+            if test.wantErr != "" {
+                if err == nil || err.Error() != test.wantErr {
+                    t.Fatalf("Execute error = %v, want %q", err, test.wantErr)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("Execute: %v", err)
+            }
+            if output != test.want {
+                t.Errorf("output = %q, want %q", output, test.want)
+            }
+        })
+    }
+}
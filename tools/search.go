@@ -0,0 +1,205 @@
+package tools
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "strings"
+    "time"
+)
+
+// DefaultSearchResults is the number of results web_search returns when no limit is given
+const DefaultSearchResults = 8
+
+// maxSearchResults caps the limit a caller may request
+const maxSearchResults = 20
+
+// DefaultBraveEndpoint is the Brave Search web results API
+const DefaultBraveEndpoint = "https://api.search.brave.com/res/v1/web/search"
+
+// searchTimeout bounds a single search request
+const searchTimeout = 15 * time.Second
+
+// SearchResult is a single web search hit
+type SearchResult struct {
+    Title   string
+    URL     string
+    Snippet string
+}
+
+// SearchProvider queries a web search backend
+type SearchProvider interface {
+    Search(query string, limit int) ([]SearchResult, error)
+}
+
+// NewSearchProvider creates the named provider: "searxng", "brave" or "mock".
+// endpoint is the provider's URL and may be empty for brave and mock.
+func NewSearchProvider(kind, endpoint, apiKey string) (SearchProvider, error) {
+    switch strings.ToLower(kind) {
+    case "searxng", "searx":
+        if endpoint == "" {
+            return nil, fmt.Errorf("the searxng search provider requires a URL")
+        }
+        return &SearxNGProvider{BaseURL: endpoint, APIKey: apiKey}, nil
+    case "brave":
+        if apiKey == "" {
+            return nil, fmt.Errorf("the brave search provider requires an API key")
+        }
+        return &BraveProvider{Endpoint: endpoint, APIKey: apiKey}, nil
+    case "mock":
+        return &MockSearchProvider{}, nil
+    default:
+        return nil, fmt.Errorf("unknown search provider %q: use searxng, brave or mock", kind)
+    }
+}
+
+// SearxNGProvider queries a SearxNG instance, or any service implementing
+// its /search?format=json API
+type SearxNGProvider struct {
+    BaseURL    string // e.g. https://searx.example.org
+    APIKey     string // Sent as a bearer token when set, for instances behind a proxy
+    HTTPClient *http.Client
+}
+
+func (p *SearxNGProvider) Search(query string, limit int) ([]SearchResult, error) {
+    params := url.Values{"q": {query}, "format": {"json"}}
+    request, err := http.NewRequest("GET", strings.TrimRight(p.BaseURL, "/")+"/search?"+params.Encode(), nil)
+    if err != nil {
+        return nil, fmt.Errorf("invalid search URL: %w", err)
+    }
+    if p.APIKey != "" {
+        request.Header.Set("Authorization", "Bearer "+p.APIKey)
+    }
+
+    var response struct {
+        Results []struct {
+            Title   string `json:"title"`
+            URL     string `json:"url"`
+            Content string `json:"content"`
+        } `json:"results"`
+    }
+    if err := getSearchJSON(p.HTTPClient, request, &response); err != nil {
+        return nil, err
+    }
+
+    var results []SearchResult
+    for _, result := range response.Results {
+        if len(results) == limit {
+            break
+        }
+        results = append(results, SearchResult{Title: result.Title, URL: result.URL, Snippet: result.Content})
+    }
+    return results, nil
+}
+
+// BraveProvider queries the Brave Search API, or a service with the same
+// request and response format
+type BraveProvider struct {
+    Endpoint   string // Defaults to DefaultBraveEndpoint
+    APIKey     string
+    HTTPClient *http.Client
+}
+
+func (p *BraveProvider) Search(query string, limit int) ([]SearchResult, error) {
+    endpoint := p.Endpoint
+    if endpoint == "" {
+        endpoint = DefaultBraveEndpoint
+    }
+    params := url.Values{"q": {query}, "count": {fmt.Sprint(limit)}}
+    request, err := http.NewRequest("GET", endpoint+"?"+params.Encode(), nil)
+    if err != nil {
+        return nil, fmt.Errorf("invalid search URL: %w", err)
+    }
+    request.Header.Set("Accept", "application/json")
+    request.Header.Set("X-Subscription-Token", p.APIKey)
+
+    var response struct {
+        Web struct {
+            Results []struct {
+                Title       string `json:"title"`
+                URL         string `json:"url"`
+                Description string `json:"description"`
+            } `json:"results"`
+        } `json:"web"`
+    }
+    if err := getSearchJSON(p.HTTPClient, request, &response); err != nil {
+        return nil, err
+    }
+
+    var results []SearchResult
+    for _, result := range response.Web.Results {
+        if len(results) == limit {
+            break
+        }
+        results = append(results, SearchResult{Title: result.Title, URL: result.URL, Snippet: stripTags(result.Description)})
+    }
+    return results, nil
+}
+
+// MockSearchProvider returns canned results without touching the network,
+// for tests and offline use
+type MockSearchProvider struct {
+    // Results, if set, are returned for every query; otherwise placeholder
+    // results naming the query are generated
+    Results []SearchResult
+}
+
+func (p *MockSearchProvider) Search(query string, limit int) ([]SearchResult, error) {
+    results := p.Results
+    if results == nil {
+        for i := 1; i <= 3; i++ {
+            results = append(results, SearchResult{
+                Title:   fmt.Sprintf("Mock result %d for %s", i, query),
+                URL:     fmt.Sprintf("https://example.com/search/%d?q=%s", i, url.QueryEscape(query)),
+                Snippet: fmt.Sprintf("Placeholder snippet %d for the query '%s'.", i, query),
+            })
+        }
+    }
+    if len(results) > limit {
+        results = results[:limit]
+    }
+    return results, nil
+}
+
+// getSearchJSON performs request and decodes its JSON response into v
+func getSearchJSON(client *http.Client, request *http.Request, v interface{}) error {
+    if client == nil {
+        client = &http.Client{Timeout: searchTimeout}
+    }
+    response, err := client.Do(request)
+    if err != nil {
+        return fmt.Errorf("search request failed: %w", err)
+    }
+    defer response.Body.Close()
+
+    body, err := io.ReadAll(io.LimitReader(response.Body, 4<<20))
+    if err != nil {
+        return fmt.Errorf("failed to read search response: %w", err)
+    }
+    if response.StatusCode != http.StatusOK {
+        return fmt.Errorf("search request failed with status %d: %s", response.StatusCode, truncateField(strings.TrimSpace(string(body)), 200))
+    }
+    if err := json.Unmarshal(body, v); err != nil {
+        return fmt.Errorf("failed to parse search response: %w", err)
+    }
+    return nil
+}
+
+// stripTags removes the HTML highlighting some providers put in snippets
+func stripTags(text string) string {
+    var sb strings.Builder
+    inTag := false
+    for _, r := range text {
+        switch {
+        case r == '<':
+            inTag = true
+        case r == '>' && inTag:
+            inTag = false
+        case !inTag:
+            sb.WriteRune(r)
+        }
+    }
+    return sb.String()
+}
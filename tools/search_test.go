@@ -0,0 +1,160 @@
+package tools
+
+import (
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+// newSearchServer serves body as JSON and records the last request it received
+func newSearchServer(t *testing.T, status int, body string) (*httptest.Server, **http.Request) {
+    t.Helper()
+    var last *http.Request
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        last = r
+        w.Header().Set("Content-Type", "application/json")
+        w.WriteHeader(status)
+        fmt.Fprint(w, body)
+    }))
+    t.Cleanup(server.Close)
+    return server, &last
+}
+
+func TestSearxNGProvider(t *testing.T) {
+    server, last := newSearchServer(t, http.StatusOK, `{"results":[
+        {"title":"Go","url":"https://go.dev","content":"The Go language"},
+        {"title":"Tour","url":"https://go.dev/tour","content":"A tour of Go"},
+        {"title":"Blog","url":"https://go.dev/blog","content":"The Go blog"}]}`)
+    provider := &SearxNGProvider{BaseURL: server.URL + "/", APIKey: "searx-key"}
+
+    results, err := provider.Search("golang tour", 2)
+    if err != nil {
+        t.Fatalf("Search: %v", err)
+    }
+    want := []SearchResult{
+        {Title: "Go", URL: "https://go.dev", Snippet: "The Go language"},
+        {Title: "Tour", URL: "https://go.dev/tour", Snippet: "A tour of Go"},
+    }
+    if !reflect.DeepEqual(results, want) {
+        t.Errorf("results = %+v, want %+v", results, want)
+    }
+    request := *last
+    if request.URL.Path != "/search" || request.URL.Query().Get("q") != "golang tour" || request.URL.Query().Get("format") != "json" {
+        t.Errorf("requested %s, want /search with the query in JSON format", request.URL)
+    }
+    if got := request.Header.Get("Authorization"); got != "Bearer searx-key" {
+        t.Errorf("Authorization = %q, want the bearer key", got)
+    }
+
+    // Without a key no credentials are sent
+    provider.APIKey = ""
+    if _, err := provider.Search("golang", 5); err != nil {
+        t.Fatalf("Search: %v", err)
+    }
+    if got := (*last).Header.Get("Authorization"); got != "" {
+        t.Errorf("Authorization = %q without a key, want none", got)
+    }
+}
+
+func TestBraveProvider(t *testing.T) {
+    server, last := newSearchServer(t, http.StatusOK, `{"web":{"results":[
+        {"title":"Go","url":"https://go.dev","description":"The <strong>Go</strong> language"}]}}`)
+    provider := &BraveProvider{Endpoint: server.URL + "/res/v1/web/search", APIKey: "brave-key"}
+
+    results, err := provider.Search("golang", 3)
+    if err != nil {
+        t.Fatalf("Search: %v", err)
+    }
+    want := []SearchResult{{Title: "Go", URL: "https://go.dev", Snippet: "The Go language"}}
+    if !reflect.DeepEqual(results, want) {
+        t.Errorf("results = %+v, want %+v", results, want)
+    }
+    request := *last
+    if request.URL.Path != "/res/v1/web/search" || request.URL.Query().Get("q") != "golang" || request.URL.Query().Get("count") != "3" {
+        t.Errorf("requested %s, want the endpoint with the query and count", request.URL)
+    }
+    if got := request.Header.Get("X-Subscription-Token"); got != "brave-key" {
+        t.Errorf("X-Subscription-Token = %q, want the key", got)
+    }
+}
+
+func TestSearchProviderErrors(t *testing.T) {
+    server, _ := newSearchServer(t, http.StatusTooManyRequests, `{"error":"rate limited"}`)
+    malformed, _ := newSearchServer(t, http.StatusOK, `not json`)
+    tests := []struct {
+        name     string
+        provider SearchProvider
+        wantErr  string
+    }{
+        {name: "searxng status", provider: &SearxNGProvider{BaseURL: server.URL}, wantErr: "status 429: {\"error\":\"rate limited\"}"},
+        {name: "brave status", provider: &BraveProvider{Endpoint: server.URL, APIKey: "k"}, wantErr: "status 429"},
+        {name: "malformed response", provider: &SearxNGProvider{BaseURL: malformed.URL}, wantErr: "failed to parse search response"},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            _, err := test.provider.Search("golang", 5)
+            if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+                t.Errorf("Search error = %v, want %q", err, test.wantErr)
+            }
+        })
+    }
+}
+
+func TestMockSearchProvider(t *testing.T) {
+    results, err := (&MockSearchProvider{}).Search("a b", 2)
+    if err != nil || len(results) != 2 || !strings.Contains(results[0].Title, "a b") || !strings.Contains(results[0].URL, "q=a+b") {
+        t.Errorf("placeholder results = %+v, %v; want 2 naming the query", results, err)
+    }
+    canned := []SearchResult{{Title: "Canned", URL: "https://example.com"}}
+    if results, _ := (&MockSearchProvider{Results: canned}).Search("anything", 5); !reflect.DeepEqual(results, canned) {
+        t.Errorf("canned results = %+v, want %+v", results, canned)
+    }
+}
+
+func TestNewSearchProvider(t *testing.T) {
+    tests := []struct {
+        kind, endpoint, apiKey string
+        want                   SearchProvider
+        wantErr                string
+    }{
+        {kind: "searxng", endpoint: "https://searx.example.org", want: &SearxNGProvider{BaseURL: "https://searx.example.org"}},
+        {kind: "SearX", endpoint: "https://searx.example.org", apiKey: "k", want: &SearxNGProvider{BaseURL: "https://searx.example.org", APIKey: "k"}},
+        {kind: "searxng", wantErr: "requires a URL"},
+        {kind: "brave", apiKey: "k", want: &BraveProvider{APIKey: "k"}},
+        {kind: "brave", wantErr: "requires an API key"},
+        {kind: "mock", want: &MockSearchProvider{}},
+        {kind: "bing", wantErr: "unknown search provider"},
+    }
+    for _, test := range tests {
+        provider, err := NewSearchProvider(test.kind, test.endpoint, test.apiKey)
+        if test.wantErr != "" {
+            if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+                t.Errorf("NewSearchProvider(%q) error = %v, want %q", test.kind, err, test.wantErr)
+            }
+            continue
+        }
+        if err != nil || !reflect.DeepEqual(provider, test.want) {
+            t.Errorf("NewSearchProvider(%q) = %+v, %v; want %+v", test.kind, provider, err, test.want)
+        }
+    }
+}
+
+func TestWebSearchTool(t *testing.T) {
+    tool := &WebSearchTool{Provider: &MockSearchProvider{Results: []SearchResult{
+        {Title: "Go", URL: "https://go.dev", Snippet: "The Go\n   language"},
+        {Title: "Tour", URL: "https://go.dev/tour"},
+    }}}
+    output, err := tool.Execute(`{"query":"golang","limit":1}`)
+    if err != nil {
+        t.Fatalf("Execute: %v", err)
+    }
+    if want := "Search results for 'golang':\n\n1. Go\n   https://go.dev\n   The Go language\n"; output != want {
+        t.Errorf("output = %q, want %q", output, want)
+    }
+    if _, err := tool.Execute(`{"query":"  "}`); err == nil {
+        t.Error("Execute with an empty query succeeded")
+    }
+}
@@ -1,12 +1,20 @@
 package tools
 
 import (
+    "bufio"
+    "bytes"
+    "crypto/sha256"
     "encoding/json"
     "fmt"
+    "io"
+    "io/fs"
     "io/ioutil"
     "os"
     "path/filepath"
+    "sort"
     "strings"
+    "unicode/utf16"
+    "unicode/utf8"
 )
 
 // Tool defines the interface for agent tools
@@ -14,15 +22,146 @@ type Tool interface {
     Execute(input string) (string, error)
     GetName() string
     GetDescription() string
+    // GetInputSchema returns the JSON schema of the tool's input object
+    GetInputSchema() map[string]interface{}
 }
 
-// WebSearchTool is a mock tool simulating a web search
-type WebSearchTool struct{}
+// ReadOnlyTool is implemented by tools that never modify files or other
+// external state, which allows the agent to run them concurrently
+type ReadOnlyTool interface {
+    IsReadOnly() bool
+}
+
+// ReadOnlyInputTool is implemented by tools that are read-only for some
+// inputs only, such as git, where every action but commit is
+type ReadOnlyInputTool interface {
+    IsReadOnlyInput(input string) bool
+}
+
+// FileMutator is implemented by tools that modify files, so the agent can
+// snapshot those files before the tool runs
+type FileMutator interface {
+    // MutatedFiles returns the paths the given input would create, modify or delete
+    MutatedFiles(input string) []string
+}
+
+// TrackingTool is implemented by tools that record the files they write in a
+// FileTracker, so the agent can re-record files that hooks changed afterwards
+type TrackingTool interface {
+    FileTracker() *FileTracker
+}
+
+// GatedTool is implemented by tools whose calls may need the user's approval
+type GatedTool interface {
+    // NeedsApproval reports whether the given input requires approval, with a
+    // short description of the action to show the user
+    NeedsApproval(input string) (bool, string)
+}
+
+// DiagnosticsProvider reports compiler diagnostics for a file, such as a
+// language server. Diagnose returns "" when the file has no problems.
+type DiagnosticsProvider interface {
+    Diagnose(path string) (string, error)
+    // Handles reports whether the provider checks files like path at all
+    Handles(path string) bool
+}
+
+// IsReadOnly reports whether a tool is known to be free of side effects
+func IsReadOnly(tool Tool) bool {
+    readOnly, ok := tool.(ReadOnlyTool)
+    return ok && readOnly.IsReadOnly()
+}
+
+// IsReadOnlyCall reports whether calling tool with input is free of side effects
+func IsReadOnlyCall(tool Tool, input string) bool {
+    if IsReadOnly(tool) {
+        return true
+    }
+    readOnly, ok := tool.(ReadOnlyInputTool)
+    return ok && readOnly.IsReadOnlyInput(input)
+}
+
+// HasReadOnlyCalls reports whether at least some calls of tool are free of
+// side effects, so it can be offered where only those are allowed
+func HasReadOnlyCalls(tool Tool) bool {
+    _, ok := tool.(ReadOnlyInputTool)
+    return ok || IsReadOnly(tool)
+}
+
+// decodeInput unmarshals a JSON object tool input into v. It returns false
+// when the input is not a JSON object, so tools can fall back to treating it
+// as plain text.
+func decodeInput(input string, v interface{}) bool {
+    trimmed := strings.TrimSpace(input)
+    if !strings.HasPrefix(trimmed, "{") {
+        return false
+    }
+    return json.Unmarshal([]byte(trimmed), v) == nil
+}
+
+// objectSchema builds a JSON schema for an object with the given properties
+func objectSchema(properties map[string]interface{}, required ...string) map[string]interface{} {
+    schema := map[string]interface{}{
+        "type":       "object",
+        "properties": properties,
+    }
+    if len(required) > 0 {
+        schema["required"] = required
+    }
+    return schema
+}
+
+// property builds a JSON schema property of the given type
+func property(typ, description string) map[string]interface{} {
+    return map[string]interface{}{"type": typ, "description": description}
+}
+
+// WebSearchRequest defines the structure for web search operations
+type WebSearchRequest struct {
+    Query string `json:"query"`
+    Limit int    `json:"limit,omitempty"` // Maximum number of results to return
+}
+
+// WebSearchTool searches the web through a configurable provider
+type WebSearchTool struct {
+    Provider SearchProvider
+}
 
 func (t *WebSearchTool) Execute(input string) (string, error) {
-    // Mock a web search result
-    query := strings.TrimSpace(input)
-    return fmt.Sprintf("Mock search results for '%s': [Result 1, Result 2, Result 3]", query), nil
+    if t.Provider == nil {
+        return "", fmt.Errorf("no search provider is configured")
+    }
+    request := WebSearchRequest{Query: strings.TrimSpace(input)}
+    if decodeInput(input, &request) {
+        request.Query = strings.TrimSpace(request.Query)
+    }
+    if request.Query == "" {
+        return "", fmt.Errorf("query is required")
+    }
+    if request.Limit <= 0 {
+        request.Limit = DefaultSearchResults
+    }
+    if request.Limit > maxSearchResults {
+        request.Limit = maxSearchResults
+    }
+
+    results, err := t.Provider.Search(request.Query, request.Limit)
+    if err != nil {
+        return "", err
+    }
+    if len(results) == 0 {
+        return fmt.Sprintf("No results for '%s'", request.Query), nil
+    }
+
+    var sb strings.Builder
+    sb.WriteString(fmt.Sprintf("Search results for '%s':\n", request.Query))
+    for i, result := range results {
+        sb.WriteString(fmt.Sprintf("\n%d. %s\n   %s\n", i+1, result.Title, result.URL))
+        if snippet := strings.TrimSpace(result.Snippet); snippet != "" {
+            sb.WriteString("   " + strings.Join(strings.Fields(snippet), " ") + "\n")
+        }
+    }
+    return sb.String(), nil
 }
 
 func (t *WebSearchTool) GetName() string {
@@ -30,7 +169,29 @@ func (t *WebSearchTool) GetName() string {
 }
 
 func (t *WebSearchTool) GetDescription() string {
-    return "Search the web for information"
+    return "Search the web and get a list of results with title, URL and snippet"
+}
+
+func (t *WebSearchTool) GetInputSchema() map[string]interface{} {
+    return objectSchema(map[string]interface{}{
+        "query": property("string", "The search query"),
+        "limit": property("integer", fmt.Sprintf("Maximum number of results (default %d, at most %d)", DefaultSearchResults, maxSearchResults)),
+    }, "query")
+}
+
+func (t *WebSearchTool) IsReadOnly() bool {
+    return true
+}
+
+// DefaultSearchLimit is the maximum number of matches FileSearchTool returns when no limit is given
+const DefaultSearchLimit = 100
+
+// FileSearchRequest defines the structure for file search operations
+type FileSearchRequest struct {
+    Pattern        string `json:"pattern"`
+    Path           string `json:"path,omitempty"`            // Directory to search, relative to RootDir
+    Limit          int    `json:"limit,omitempty"`           // Maximum number of matches to return
+    IncludeIgnored bool   `json:"include_ignored,omitempty"` // Also search files excluded by ignore files
 }
 
 // FileSearchTool is a tool for finding files in the system
@@ -38,17 +199,122 @@ type FileSearchTool struct {
     RootDir string
 }
 
+// fileMatch is a search hit with the modification time used for ordering
+type fileMatch struct {
+    path    string
+    modTime int64
+}
+
 func (t *FileSearchTool) Execute(input string) (string, error) {
-    searchTerm := strings.TrimSpace(input)
-    var matchedFiles []string
+    request := FileSearchRequest{Pattern: strings.TrimSpace(input)}
+    if decodeInput(input, &request) {
+        request.Pattern = strings.TrimSpace(request.Pattern)
+    }
+    if request.Pattern == "" {
+        return "", fmt.Errorf("pattern is required")
+    }
+    searchTerm := request.Pattern
     
-    err := filepath.Walk(t.RootDir, func(path string, info os.FileInfo, err error) error {
+    root := t.RootDir
+    if root == "" {
+        root = "."
+    }
+    if request.Path != "" {
+        root = filepath.Join(root, request.Path)
+    }
+    limit := request.Limit
+    if limit <= 0 {
+        limit = DefaultSearchLimit
+    }
+    
+    glob := hasGlobMeta(searchTerm)
+    pattern := filepath.ToSlash(strings.TrimPrefix(searchTerm, "./"))
+    
+    var matchedFiles []fileMatch
+    skipped := 0
+    // Ignore files above the searched directory apply too, up to the repository root
+    topMatcher, prefix := &ignoreMatcher{}, ""
+    if !request.IncludeIgnored {
+        topRoot := t.RootDir
+        if topRoot == "" {
+            topRoot = "."
+        }
+        topMatcher, prefix = ancestorMatcher(topRoot, root)
+    }
+    matchers := map[string]*ignoreMatcher{}
+    
+    err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
         if err != nil {
-            return err
+            if path == root {
+                return err
+            }
+            // Keep going past unreadable entries instead of aborting the search
+            skipped++
+            if d != nil && d.IsDir() {
+                return filepath.SkipDir
+            }
+            return nil
+        }
+        
+        rel, relErr := filepath.Rel(root, path)
+        if relErr != nil {
+            return nil
+        }
+        rel = filepath.ToSlash(rel)
+        
+        // Each directory inherits its parent's ignore rules plus its own
+        parentRel := "."
+        if rel != "." {
+            parentRel = slashParent(rel)
+        }
+        matcher := matchers[parentRel]
+        if matcher == nil {
+            matcher = topMatcher
+        }
+        
+        if rel != "." {
+            if d.IsDir() && (d.Name() == ".git" || (!request.IncludeIgnored && defaultIgnoredDirs[d.Name()])) {
+                return filepath.SkipDir
+            }
+            if !request.IncludeIgnored && matcher.ignored(joinRel(prefix, rel), d.IsDir()) {
+                if d.IsDir() {
+                    return filepath.SkipDir
+                }
+                return nil
+            }
+        }
+        
+        if d.IsDir() {
+            if request.IncludeIgnored {
+                matchers[rel] = matcher
+            } else {
+                matchers[rel] = matcher.loadDir(path, joinRel(prefix, rel))
+            }
+            if rel == "." {
+                return nil
+            }
         }
-        if strings.Contains(strings.ToLower(info.Name()), strings.ToLower(searchTerm)) {
-            matchedFiles = append(matchedFiles, path)
+        
+        var matched bool
+        switch {
+        case !glob:
+            matched = strings.Contains(strings.ToLower(d.Name()), strings.ToLower(searchTerm))
+        case d.IsDir():
+            matched = false
+        case strings.Contains(pattern, "/"):
+            matched = matchGlob(pattern, rel)
+        default:
+            matched = matchGlob(pattern, d.Name())
         }
+        if !matched {
+            return nil
+        }
+        
+        var modTime int64
+        if info, err := d.Info(); err == nil {
+            modTime = info.ModTime().UnixNano()
+        }
+        matchedFiles = append(matchedFiles, fileMatch{path: path, modTime: modTime})
         return nil
     })
     
@@ -60,38 +326,309 @@ func (t *FileSearchTool) Execute(input string) (string, error) {
         return fmt.Sprintf("No files matching '%s' found", searchTerm), nil
     }
     
+    // Most recently modified files first
+    sort.SliceStable(matchedFiles, func(i, j int) bool {
+        return matchedFiles[i].modTime > matchedFiles[j].modTime
+    })
+    
     result := fmt.Sprintf("Files matching '%s':\n", searchTerm)
-    for _, file := range matchedFiles {
-        result += fmt.Sprintf("- %s\n", file)
+    for i, file := range matchedFiles {
+        if i == limit {
+            break
+        }
+        result += fmt.Sprintf("- %s\n", file.path)
+    }
+    if len(matchedFiles) > limit {
+        result += fmt.Sprintf("(showing %d of %d matches, most recently modified first; narrow the pattern or raise the limit to see more)\n", limit, len(matchedFiles))
+    }
+    if skipped > 0 {
+        result += fmt.Sprintf("(%d unreadable entries skipped)\n", skipped)
     }
     return result, nil
 }
 
+// slashParent returns the parent of a slash-separated relative path, or "." at the top level
+func slashParent(rel string) string {
+    if i := strings.LastIndex(rel, "/"); i != -1 {
+        return rel[:i]
+    }
+    return "."
+}
+
 func (t *FileSearchTool) GetName() string {
     return "file_search"
 }
 
 func (t *FileSearchTool) GetDescription() string {
-    return "Search for files by name in the filesystem"
+    return "Search for files by name or glob pattern (e.g. **/*.go), skipping files excluded by .gitignore/.ignore; results are sorted by modification time, newest first"
+}
+
+func (t *FileSearchTool) GetInputSchema() map[string]interface{} {
+    return objectSchema(map[string]interface{}{
+        "pattern":         property("string", "Glob pattern such as '**/*_test.go' or 'cmd/*/main.go', or part of a file name"),
+        "path":            property("string", "Directory to search in, relative to the working directory"),
+        "limit":           property("integer", fmt.Sprintf("Maximum number of results (default %d)", DefaultSearchLimit)),
+        "include_ignored": property("boolean", "Also search files excluded by ignore files and dependency directories"),
+    }, "pattern")
+}
+
+func (t *FileSearchTool) IsReadOnly() bool {
+    return true
+}
+
+// DefaultReadLimit is the maximum number of lines FileReadTool returns when no limit is given
+const DefaultReadLimit = 2000
+
+// maxReadLineLength truncates very long lines such as minified code
+const maxReadLineLength = 2000
+
+// binarySniffLen is how much of a file is inspected to detect binary content
+const binarySniffLen = 8000
+
+// maxUTF16ReadSize is the largest UTF-16 file FileReadTool will convert
+const maxUTF16ReadSize = 16 << 20
+
+// FileReadRequest defines the structure for file read operations
+type FileReadRequest struct {
+    FilePath string `json:"file_path"`
+    Offset   int    `json:"offset,omitempty"` // 1-based line to start reading from
+    Limit    int    `json:"limit,omitempty"`  // Maximum number of lines to return
 }
 
 // FileReadTool reads the content of a file
-type FileReadTool struct{}
+type FileReadTool struct {
+    // MaxLines caps the lines returned per call; DefaultReadLimit when zero
+    MaxLines int
+    // Tracker, if set, records what was read so later edits can detect external changes
+    Tracker *FileTracker
+}
 
 func (t *FileReadTool) Execute(input string) (string, error) {
-    filePath := strings.TrimSpace(input)
+    request := FileReadRequest{FilePath: strings.TrimSpace(input)}
+    if decodeInput(input, &request) {
+        request.FilePath = strings.TrimSpace(request.FilePath)
+    }
+    if request.FilePath == "" {
+        return "", fmt.Errorf("file_path is required")
+    }
     
     // Check if file exists
-    if _, err := os.Stat(filePath); os.IsNotExist(err) {
-        return "", fmt.Errorf("file not found: %s", filePath)
+    info, err := os.Stat(request.FilePath)
+    if os.IsNotExist(err) {
+        return "", fmt.Errorf("file not found: %s", request.FilePath)
+    } else if err != nil {
+        return "", fmt.Errorf("error accessing file: %w", err)
+    }
+    if info.IsDir() {
+        return "", fmt.Errorf("%s is a directory, not a file", request.FilePath)
     }
     
-    data, err := ioutil.ReadFile(filePath)
+    file, err := os.Open(request.FilePath)
     if err != nil {
         return "", err
     }
+    defer file.Close()
     
-    return string(data), nil
+    // The file is streamed so that only the requested lines are held in
+    // memory, and hashed on the way for the tracker
+    hash := sha256.New()
+    reader := bufio.NewReaderSize(io.TeeReader(file, hash), binarySniffLen)
+    sample, err := reader.Peek(binarySniffLen)
+    if err != nil && err != io.EOF {
+        return "", err
+    }
+    if isBinary(sample) {
+        return "", fmt.Errorf("%s appears to be a binary file (%d bytes); refusing to display it", request.FilePath, info.Size())
+    }
+    
+    scanner := &lineScanner{reader: reader, first: true}
+    if isUTF16(sample) {
+        // UTF-16 is decoded in one go, so it is only read up to a size limit
+        if info.Size() > maxUTF16ReadSize {
+            return "", fmt.Errorf("%s is a UTF-16 file larger than %d bytes; convert it to UTF-8 to read it", request.FilePath, maxUTF16ReadSize)
+        }
+        data, err := io.ReadAll(reader)
+        if err != nil {
+            return "", err
+        }
+        text, err := decodeUTF16(data)
+        if err != nil {
+            return "", fmt.Errorf("%s: %w", request.FilePath, err)
+        }
+        scanner.lines = strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+        if text == "" {
+            scanner.lines = []string{}
+        }
+    }
+    
+    offset := request.Offset
+    if offset < 1 {
+        offset = 1
+    }
+    limit := t.MaxLines
+    if limit <= 0 {
+        limit = DefaultReadLimit
+    }
+    if request.Limit > 0 && request.Limit < limit {
+        limit = request.Limit
+    }
+    end := offset - 1 + limit
+    
+    // Keep the requested lines, but scan to the end to count them all
+    var lines []string
+    total := 0
+    for {
+        line, ok, err := scanner.next()
+        if err != nil {
+            return "", fmt.Errorf("failed to read %s: %w", request.FilePath, err)
+        }
+        if !ok {
+            break
+        }
+        total++
+        if total >= offset && total <= end {
+            lines = append(lines, line)
+        }
+    }
+    t.Tracker.recordHash(request.FilePath, hash)
+    
+    if total == 0 {
+        return "(file is empty)", nil
+    }
+    if offset > total {
+        return "", fmt.Errorf("offset %d is beyond the end of the file (%d lines)", offset, total)
+    }
+    if end > total {
+        end = total
+    }
+    
+    // Prefix each line with its 1-based number, matching file_edit's start_line/end_line
+    var sb strings.Builder
+    switch {
+    case scanner.lines != nil:
+        sb.WriteString("[Note: file is UTF-16 encoded; shown converted to UTF-8]\n")
+    case scanner.invalid:
+        sb.WriteString("[Note: file is not valid UTF-8; invalid bytes are shown as \uFFFD]\n")
+    }
+    for i, line := range lines {
+        sb.WriteString(fmt.Sprintf("%6d\t%s\n", offset+i, line))
+    }
+    if end < total {
+        sb.WriteString(fmt.Sprintf("\n[Showing lines %d-%d of %d. Use offset %d to read more.]\n", offset, end, total, end+1))
+    }
+    
+    return sb.String(), nil
+}
+
+// lineScanner reads a text file one line at a time, holding at most
+// maxReadLineLength bytes of any line in memory
+type lineScanner struct {
+    reader  *bufio.Reader
+    lines   []string // Lines decoded up front, for UTF-16 files
+    first   bool
+    invalid bool // Set once a line with invalid UTF-8 has been read
+}
+
+// next returns the next line without its line ending, and false at the end
+// of the file. Long lines are truncated.
+func (s *lineScanner) next() (string, bool, error) {
+    if s.lines != nil {
+        if len(s.lines) == 0 {
+            return "", false, nil
+        }
+        line := strings.TrimSuffix(s.lines[0], "\r")
+        s.lines = s.lines[1:]
+        return truncateLine(line), true, nil
+    }
+    
+    fragment, isPrefix, err := s.reader.ReadLine()
+    if err == io.EOF {
+        return "", false, nil
+    } else if err != nil {
+        return "", false, err
+    }
+    if s.first {
+        fragment = bytes.TrimPrefix(fragment, []byte{0xEF, 0xBB, 0xBF})
+        s.first = false
+    }
+    // The rest of a long line is discarded once it is known to be truncated
+    line := append([]byte(nil), fragment...)
+    for isPrefix {
+        if fragment, isPrefix, err = s.reader.ReadLine(); err != nil {
+            return "", false, err
+        }
+        if room := maxReadLineLength + utf8.UTFMax - len(line); room > 0 {
+            if len(fragment) > room {
+                fragment = fragment[:room]
+            }
+            line = append(line, fragment...)
+        }
+    }
+    
+    text := string(line)
+    if !utf8.ValidString(text) {
+        s.invalid = true
+        text = strings.ToValidUTF8(text, "\uFFFD")
+    }
+    return truncateLine(text), true, nil
+}
+
+// truncateLine shortens lines longer than maxReadLineLength, such as minified code
+func truncateLine(line string) string {
+    if len(line) <= maxReadLineLength {
+        return line
+    }
+    cut := maxReadLineLength
+    for cut > 0 && !utf8.RuneStart(line[cut]) {
+        cut--
+    }
+    return line[:cut] + "... [line truncated]"
+}
+
+// isBinary reports whether data looks like binary rather than text content
+func isBinary(data []byte) bool {
+    sample := data
+    if len(sample) > binarySniffLen {
+        sample = sample[:binarySniffLen]
+    }
+    // UTF-16 text contains NUL bytes but is handled by decodeUTF16
+    if isUTF16(sample) {
+        return false
+    }
+    if bytes.IndexByte(sample, 0) != -1 {
+        return true
+    }
+    
+    // Treat a high proportion of control characters as binary
+    control := 0
+    for _, b := range sample {
+        if b < 0x20 && b != '\n' && b != '\r' && b != '\t' && b != '\f' && b != 0x1b {
+            control++
+        }
+    }
+    return len(sample) > 0 && control*10 > len(sample)
+}
+
+// isUTF16 reports whether data starts with a UTF-16 byte order mark
+func isUTF16(data []byte) bool {
+    return bytes.HasPrefix(data, []byte{0xFF, 0xFE}) || bytes.HasPrefix(data, []byte{0xFE, 0xFF})
+}
+
+// decodeUTF16 converts UTF-16 content with a byte order mark to UTF-8
+func decodeUTF16(data []byte) (string, error) {
+    if len(data)%2 != 0 {
+        return "", fmt.Errorf("malformed UTF-16 content")
+    }
+    bigEndian := data[0] == 0xFE
+    units := make([]uint16, 0, len(data)/2-1)
+    for i := 2; i+1 < len(data); i += 2 {
+        if bigEndian {
+            units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+        } else {
+            units = append(units, uint16(data[i+1])<<8|uint16(data[i]))
+        }
+    }
+    return string(utf16.Decode(units)), nil
 }
 
 func (t *FileReadTool) GetName() string {
@@ -99,7 +636,19 @@ func (t *FileReadTool) GetName() string {
 }
 
 func (t *FileReadTool) GetDescription() string {
-    return "Read the contents of a file at the specified path"
+    return "Read the contents of a file at the specified path. Lines are prefixed with their line numbers; use offset and limit to page through large files"
+}
+
+func (t *FileReadTool) GetInputSchema() map[string]interface{} {
+    return objectSchema(map[string]interface{}{
+        "file_path": property("string", "Path of the file to read"),
+        "offset":    property("integer", "1-based line number to start reading from"),
+        "limit":     property("integer", fmt.Sprintf("Maximum number of lines to read (at most %d)", DefaultReadLimit)),
+    }, "file_path")
+}
+
+func (t *FileReadTool) IsReadOnly() bool {
+    return true
 }
 
 // FileEditRequest defines the structure for file edit operations
@@ -112,11 +661,15 @@ type FileEditRequest struct {
 }
 
 // FileEditTool edits content in files
-type FileEditTool struct{}
+type FileEditTool struct {
+    // Tracker, if set, is used to refuse edits to files changed on disk since they were read
+    Tracker *FileTracker
+    // Diagnostics, if set, is consulted after each edit so errors the edit
+    // introduced are reported straight away
+    Diagnostics DiagnosticsProvider
+}
 
 func (t *FileEditTool) Execute(input string) (string, error) {
-    fmt.Printf("FileEditTool received input: %s\n", input)
-    
     // Parse the JSON request
     var request FileEditRequest
     if err := json.Unmarshal([]byte(input), &request); err != nil {
@@ -136,10 +689,11 @@ func (t *FileEditTool) Execute(input string) (string, error) {
     if os.IsNotExist(err) {
         // If file doesn't exist and we're not appending, create it
         if request.Content != "" {
-            if err := ioutil.WriteFile(request.FilePath, []byte(request.Content), 0644); err != nil {
+            if err := WriteFileAtomic(request.FilePath, []byte(request.Content), 0644); err != nil {
                 return "", fmt.Errorf("failed to create file: %w", err)
             }
-            return fmt.Sprintf("Created new file %s with %d bytes", request.FilePath, len(request.Content)), nil
+            t.Tracker.Record(request.FilePath, []byte(request.Content))
+            return fmt.Sprintf("Created new file %s with %d bytes", request.FilePath, len(request.Content)) + t.diagnose(request.FilePath), nil
         }
         return "", fmt.Errorf("file not found: %s", request.FilePath)
     } else if err != nil {
@@ -157,6 +711,11 @@ func (t *FileEditTool) Execute(input string) (string, error) {
         return "", fmt.Errorf("failed to read file: %w", err)
     }
     
+    // Refuse to clobber changes made by someone else since the model read the file
+    if err := t.Tracker.Check(request.FilePath, fileInfo, currentContent); err != nil {
+        return "", err
+    }
+    
     var newContent []byte
     
     switch request.Operation {
@@ -199,12 +758,30 @@ func (t *FileEditTool) Execute(input string) (string, error) {
         return "", fmt.Errorf("unsupported operation: %s. Use 'replace' or 'append'", request.Operation)
     }
     
-    // Write the modified content back to the file
-    if err := ioutil.WriteFile(request.FilePath, newContent, fileInfo.Mode()); err != nil {
+    // Write the modified content back to the file, replacing it atomically
+    if err := WriteFileAtomic(request.FilePath, newContent, fileInfo.Mode().Perm()); err != nil {
         return "", fmt.Errorf("failed to write to file: %w", err)
     }
+    t.Tracker.Record(request.FilePath, newContent)
     
-    return fmt.Sprintf("Successfully edited %s (%d bytes written)", request.FilePath, len(newContent)), nil
+    return fmt.Sprintf("Successfully edited %s (%d bytes written)", request.FilePath, len(newContent)) + t.diagnose(request.FilePath), nil
+}
+
+// diagnose returns the file's diagnostics formatted for appending to an edit
+// result. Diagnostics are advisory, so failures to get them are reported
+// rather than failing the edit.
+func (t *FileEditTool) diagnose(path string) string {
+    if t.Diagnostics == nil || !t.Diagnostics.Handles(path) {
+        return ""
+    }
+    diagnostics, err := t.Diagnostics.Diagnose(path)
+    if err != nil {
+        return fmt.Sprintf("\n\n(diagnostics unavailable: %v)", err)
+    }
+    if diagnostics == "" {
+        return "\n\nNo diagnostics reported."
+    }
+    return "\n\nDiagnostics after edit:\n" + strings.TrimRight(diagnostics, "\n")
 }
 
 func (t *FileEditTool) GetName() string {
@@ -212,5 +789,27 @@ func (t *FileEditTool) GetName() string {
 }
 
 func (t *FileEditTool) GetDescription() string {
-    return "Edit a file - can replace entire file, replace specific lines, or append content (input is JSON)"
+    return "Edit a file - can replace entire file, replace specific lines (numbered as shown by file_read), or append content (input is JSON)"
+}
+
+func (t *FileEditTool) GetInputSchema() map[string]interface{} {
+    return objectSchema(map[string]interface{}{
+        "file_path":  property("string", "Path of the file to edit; created if it does not exist"),
+        "operation":  map[string]interface{}{"type": "string", "enum": []string{"replace", "append"}, "description": "Either 'replace' or 'append'"},
+        "content":    property("string", "The content to write"),
+        "start_line": property("integer", "First line to replace, 1-based (replace only)"),
+        "end_line":   property("integer", "Last line to replace, inclusive (replace only)"),
+    }, "file_path", "operation", "content")
+}
+
+func (t *FileEditTool) MutatedFiles(input string) []string {
+    var request FileEditRequest
+    if err := json.Unmarshal([]byte(input), &request); err != nil || request.FilePath == "" {
+        return nil
+    }
+    return []string{request.FilePath}
+}
+
+func (t *FileEditTool) FileTracker() *FileTracker {
+    return t.Tracker
 }
@@ -0,0 +1,243 @@
+package tools
+
+import (
+    "encoding/json"
+    "errors"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "unicode/utf16"
+)
+
+// utf16File encodes text as UTF-16 with a byte order mark
+func utf16File(text string, bigEndian bool) string {
+    var data []byte
+    if bigEndian {
+        data = []byte{0xFE, 0xFF}
+    } else {
+        data = []byte{0xFF, 0xFE}
+    }
+    for _, unit := range utf16.Encode([]rune(text)) {
+        if bigEndian {
+            data = append(data, byte(unit>>8), byte(unit))
+        } else {
+            data = append(data, byte(unit), byte(unit>>8))
+        }
+    }
+    return string(data)
+}
+
+func TestFileReadTool(t *testing.T) {
+    longLine := strings.Repeat("x", maxReadLineLength+500)
+    tests := []struct {
+        name     string
+        content  string
+        offset   int
+        limit    int
+        maxLines int
+        want     string
+        wantErr  string
+    }{
+        {
+            name:    "line numbers",
+            content: "package main\n\nfunc main() {}\n",
+            want:    "     1\tpackage main\n     2\t\n     3\tfunc main() {}\n",
+        },
+        {
+            name:    "no final newline and CRLF endings",
+            content: "one\r\ntwo",
+            want:    "     1\tone\n     2\ttwo\n",
+        },
+        {
+            name:    "offset and limit",
+            content: "a\nb\nc\nd\n",
+            offset:  2,
+            limit:   2,
+            want:    "     2\tb\n     3\tc\n\n[Showing lines 2-3 of 4. Use offset 4 to read more.]\n",
+        },
+        {
+            name:     "tool line cap",
+            content:  "a\nb\nc\n",
+            limit:    10,
+            maxLines: 1,
+            want:     "     1\ta\n\n[Showing lines 1-1 of 3. Use offset 2 to read more.]\n",
+        },
+        {
+            name:    "offset past the end",
+            content: "a\nb\n",
+            offset:  3,
+            wantErr: "offset 3 is beyond the end of the file (2 lines)",
+        },
+        {
+            name:    "empty file",
+            content: "",
+            want:    "(file is empty)",
+        },
+        {
+            name:    "UTF-8 byte order mark",
+            content: "\xEF\xBB\xBFhello\n",
+            want:    "     1\thello\n",
+        },
+        {
+            name:    "UTF-16 little endian",
+            content: utf16File("héllo\r\nwörld\n", false),
+            want:    "[Note: file is UTF-16 encoded; shown converted to UTF-8]\n     1\théllo\n     2\twörld\n",
+        },
+        {
+            name:    "UTF-16 big endian",
+            content: utf16File("日本\n", true),
+            want:    "[Note: file is UTF-16 encoded; shown converted to UTF-8]\n     1\t日本\n",
+        },
+        {
+            name:    "invalid UTF-8",
+            content: "caf\xE9\n",
+            want:    "[Note: file is not valid UTF-8; invalid bytes are shown as �]\n     1\tcaf�\n",
+        },
+        {
+            name:    "long line",
+            content: "short\n" + longLine + "\nafter\n",
+            want:    "     1\tshort\n     2\t" + longLine[:maxReadLineLength] + "... [line truncated]\n     3\tafter\n",
+        },
+        {
+            name:    "NUL bytes",
+            content: "ELF\x00\x01\x02\x00",
+            wantErr: "appears to be a binary file (7 bytes)",
+        },
+        {
+            name:    "control characters",
+            content: strings.Repeat("\x01\x02\x03ab", 20),
+            wantErr: "appears to be a binary file",
+        },
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            path := filepath.Join(t.TempDir(), "file")
+            if err := os.WriteFile(path, []byte(test.content), 0644); err != nil {
+                t.Fatal(err)
+            }
+            input, _ := json.Marshal(FileReadRequest{FilePath: path, Offset: test.offset, Limit: test.limit})
+            output, err := (&FileReadTool{MaxLines: test.maxLines}).Execute(string(input))
+            if test.wantErr != "" {
+                if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+                    t.Fatalf("Execute error = %v, want %q", err, test.wantErr)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("Execute: %v", err)
+            }
+            if output != test.want {
+                t.Errorf("output =\n%q\nwant\n%q", output, test.want)
+            }
+        })
+    }
+}
+
+func TestFileReadToolErrors(t *testing.T) {
+    dir := t.TempDir()
+    tool := &FileReadTool{}
+    if _, err := tool.Execute(filepath.Join(dir, "missing.txt")); err == nil || !strings.Contains(err.Error(), "file not found") {
+        t.Errorf("reading a missing file: %v", err)
+    }
+    if _, err := tool.Execute(dir); err == nil || !strings.Contains(err.Error(), "is a directory") {
+        t.Errorf("reading a directory: %v", err)
+    }
+    if _, err := tool.Execute(`{"file_path":" "}`); err == nil || !strings.Contains(err.Error(), "file_path is required") {
+        t.Errorf("reading without a path: %v", err)
+    }
+}
+
+func TestFileReadToolRecordsReads(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "a.txt")
+    if err := os.WriteFile(path, []byte("one\ntwo\nthree\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    tracker := NewFileTracker()
+    // Reading only part of the file still records the whole of it
+    if _, err := (&FileReadTool{Tracker: tracker}).Execute(`{"file_path":"` + path + `","limit":1}`); err != nil {
+        t.Fatalf("Execute: %v", err)
+    }
+    info, _ := os.Stat(path)
+    if err := tracker.Check(path, info, []byte("one\ntwo\nthree\n")); err != nil {
+        t.Errorf("Check right after reading = %v", err)
+    }
+
+    if err := os.WriteFile(path, []byte("changed elsewhere\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    info, _ = os.Stat(path)
+    if err := tracker.Check(path, info, []byte("changed elsewhere\n")); err == nil {
+        t.Error("Check after an outside change succeeded")
+    }
+}
+
+// fakeDiagnostics is a DiagnosticsProvider for Go files only
+type fakeDiagnostics struct {
+    report string
+    err    error
+    calls  int
+}
+
+func (d *fakeDiagnostics) Diagnose(path string) (string, error) {
+    d.calls++
+    return d.report, d.err
+}
+
+func (d *fakeDiagnostics) Handles(path string) bool {
+    return filepath.Ext(path) == ".go"
+}
+
+func TestFileEditToolDiagnostics(t *testing.T) {
+    tests := []struct {
+        name        string
+        file        string
+        diagnostics *fakeDiagnostics
+        want        string
+        wantCalls   int
+    }{
+        {
+            name:        "problems",
+            file:        "main.go",
+            diagnostics: &fakeDiagnostics{report: "main.go:1:1: error: expected 'package'\n"},
+            want:        "\n\nDiagnostics after edit:\nmain.go:1:1: error: expected 'package'",
+            wantCalls:   1,
+        },
+        {
+            name:        "clean file",
+            file:        "main.go",
+            diagnostics: &fakeDiagnostics{},
+            want:        "\n\nNo diagnostics reported.",
+            wantCalls:   1,
+        },
+        {
+            name:        "server timed out",
+            file:        "main.go",
+            diagnostics: &fakeDiagnostics{err: errors.New("the language server published no diagnostics after 5s")},
+            want:        "\n\n(diagnostics unavailable: the language server published no diagnostics after 5s)",
+            wantCalls:   1,
+        },
+        {
+            name:        "language the server doesn't handle",
+            file:        "README.md",
+            diagnostics: &fakeDiagnostics{},
+            want:        "",
+        },
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            path := filepath.Join(t.TempDir(), test.file)
+            input, _ := json.Marshal(FileEditRequest{FilePath: path, Operation: "replace", Content: "package main\n"})
+            output, err := (&FileEditTool{Diagnostics: test.diagnostics}).Execute(string(input))
+            if err != nil {
+                t.Fatalf("Execute: %v", err)
+            }
+            if want := "Created new file " + path + " with 13 bytes" + test.want; output != want {
+                t.Errorf("output = %q, want %q", output, want)
+            }
+            if test.diagnostics.calls != test.wantCalls {
+                t.Errorf("Diagnose called %d times, want %d", test.diagnostics.calls, test.wantCalls)
+            }
+        })
+    }
+}
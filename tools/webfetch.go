@@ -0,0 +1,415 @@
+package tools
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "mime"
+    "net"
+    "net/http"
+    "net/url"
+    "os"
+    "path/filepath"
+    "strings"
+    "syscall"
+    "time"
+    "unicode/utf8"
+)
+
+// Defaults for WebFetchTool limits
+const (
+    DefaultFetchTimeout      = 20 * time.Second
+    DefaultFetchMaxBytes     = 5 << 20
+    DefaultFetchMaxRedirects = 5
+    DefaultFetchCacheTTL     = 15 * time.Minute
+    DefaultFetchMaxOutput    = 50000
+)
+
+// WebFetchRequest defines the structure for web_fetch operations
+type WebFetchRequest struct {
+    URL    string `json:"url"`
+    Raw    bool   `json:"raw,omitempty"`    // Return HTML source instead of Markdown
+    Offset int    `json:"offset,omitempty"` // Character offset to continue a truncated page from
+}
+
+// WebFetchTool retrieves a URL and returns its content as Markdown or text
+type WebFetchTool struct {
+    Timeout      time.Duration // Defaults to DefaultFetchTimeout
+    MaxBytes     int64         // Largest response body accepted; defaults to DefaultFetchMaxBytes
+    MaxRedirects int           // Defaults to DefaultFetchMaxRedirects; negative disables redirects
+    MaxOutput    int           // Characters returned per call; defaults to DefaultFetchMaxOutput
+
+    // AllowDomains, if not nil, restricts fetches to these domains and their
+    // subdomains; an empty list allows none. DenyDomains are refused even
+    // when allowed.
+    AllowDomains []string
+    DenyDomains  []string
+
+    // CacheDir, if set, stores responses on disk for CacheTTL
+    CacheDir string
+    CacheTTL time.Duration
+
+    // AllowPrivateNetworks permits connections to loopback, private and
+    // link-local addresses, such as cloud metadata endpoints, which are
+    // refused by default
+    AllowPrivateNetworks bool
+
+    // HTTPClient overrides the client used for requests; its CheckRedirect
+    // is replaced to enforce the redirect and domain limits. A non-nil
+    // Transport replaces the one that refuses private addresses.
+    HTTPClient *http.Client
+}
+
+// fetchedPage is a fetched response, as stored in the cache
+type fetchedPage struct {
+    URL         string    `json:"url"` // Final URL after redirects
+    ContentType string    `json:"content_type"`
+    Body        []byte    `json:"body"`
+    Fetched     time.Time `json:"fetched"`
+}
+
+func (t *WebFetchTool) Execute(input string) (string, error) {
+    request := WebFetchRequest{URL: strings.TrimSpace(input)}
+    if decodeInput(input, &request) {
+        request.URL = strings.TrimSpace(request.URL)
+    }
+    if request.URL == "" {
+        return "", fmt.Errorf("url is required")
+    }
+
+    target, err := url.Parse(request.URL)
+    if err != nil {
+        return "", fmt.Errorf("invalid url: %w", err)
+    }
+    if target.Scheme == "" {
+        // Accept bare hosts such as example.com/page
+        target, err = url.Parse("https://" + request.URL)
+        if err != nil {
+            return "", fmt.Errorf("invalid url: %w", err)
+        }
+    }
+    if err := t.checkURL(target); err != nil {
+        return "", err
+    }
+
+    page, cached := t.readCache(target.String())
+    if !cached {
+        page, err = t.fetch(target)
+        if err != nil {
+            return "", err
+        }
+        t.writeCache(target.String(), page)
+    }
+
+    title, content, err := t.render(page, request.Raw)
+    if err != nil {
+        return "", err
+    }
+    return t.paginate(page, title, content, request.Offset), nil
+}
+
+// checkURL enforces the scheme and domain rules
+func (t *WebFetchTool) checkURL(target *url.URL) error {
+    if target.Scheme != "http" && target.Scheme != "https" {
+        return fmt.Errorf("unsupported URL scheme %q: only http and https can be fetched", target.Scheme)
+    }
+    host := strings.ToLower(target.Hostname())
+    if host == "" {
+        return fmt.Errorf("url %s has no host", target)
+    }
+    for _, domain := range t.DenyDomains {
+        if matchesDomain(host, domain) {
+            return fmt.Errorf("fetching from %s is not allowed", host)
+        }
+    }
+    if t.AllowDomains == nil {
+        return nil
+    }
+    for _, domain := range t.AllowDomains {
+        if matchesDomain(host, domain) {
+            return nil
+        }
+    }
+    if len(t.AllowDomains) == 0 {
+        return fmt.Errorf("fetching from %s is not allowed: no domains may be fetched", host)
+    }
+    return fmt.Errorf("fetching from %s is not allowed: only %s may be fetched", host, strings.Join(t.AllowDomains, ", "))
+}
+
+// IntersectDomains returns the domains allowed by both lists of domains,
+// where each domain also allows its subdomains. The result is empty, not
+// nil, when they have nothing in common.
+func IntersectDomains(a, b []string) []string {
+    result := []string{}
+    seen := make(map[string]bool)
+    add := func(domain string) {
+        if !seen[domain] {
+            seen[domain] = true
+            result = append(result, domain)
+        }
+    }
+    for _, x := range a {
+        x = normalizeDomain(x)
+        for _, y := range b {
+            y = normalizeDomain(y)
+            switch {
+            case matchesDomain(x, y):
+                add(x)
+            case matchesDomain(y, x):
+                add(y)
+            }
+        }
+    }
+    return result
+}
+
+// normalizeDomain lowercases a domain and drops a leading "*."
+func normalizeDomain(domain string) string {
+    return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "*."))
+}
+
+// matchesDomain reports whether host is domain or one of its subdomains
+func matchesDomain(host, domain string) bool {
+    domain = normalizeDomain(domain)
+    return domain != "" && (host == domain || strings.HasSuffix(host, "."+domain))
+}
+
+// fetch performs the HTTP request within the configured limits
+func (t *WebFetchTool) fetch(target *url.URL) (*fetchedPage, error) {
+    client := &http.Client{Timeout: t.Timeout}
+    if t.HTTPClient != nil {
+        copied := *t.HTTPClient
+        client = &copied
+    }
+    if client.Timeout == 0 {
+        client.Timeout = DefaultFetchTimeout
+    }
+    if client.Transport == nil {
+        client.Transport = t.transport()
+    }
+    maxRedirects := t.MaxRedirects
+    if maxRedirects == 0 {
+        maxRedirects = DefaultFetchMaxRedirects
+    }
+    client.CheckRedirect = func(next *http.Request, via []*http.Request) error {
+        if len(via) > maxRedirects {
+            return fmt.Errorf("stopped after %d redirects", len(via)-1)
+        }
+        return t.checkURL(next.URL)
+    }
+
+    request, err := http.NewRequest("GET", target.String(), nil)
+    if err != nil {
+        return nil, fmt.Errorf("invalid url: %w", err)
+    }
+    request.Header.Set("User-Agent", "ai-agent/1.0 (+web_fetch)")
+    request.Header.Set("Accept", "text/html,application/xhtml+xml,text/plain,application/json;q=0.9,*/*;q=0.5")
+
+    response, err := client.Do(request)
+    if err != nil {
+        var urlErr *url.Error
+        if errors.As(err, &urlErr) && urlErr.Timeout() {
+            return nil, fmt.Errorf("fetching %s timed out after %s", target, client.Timeout)
+        }
+        return nil, fmt.Errorf("failed to fetch %s: %w", target, err)
+    }
+    defer response.Body.Close()
+
+    if response.StatusCode < 200 || response.StatusCode > 299 {
+        return nil, fmt.Errorf("fetching %s failed with status %s", target, response.Status)
+    }
+
+    maxBytes := t.MaxBytes
+    if maxBytes <= 0 {
+        maxBytes = DefaultFetchMaxBytes
+    }
+    if response.ContentLength > maxBytes {
+        return nil, fmt.Errorf("response is %d bytes, more than the %d byte limit", response.ContentLength, maxBytes)
+    }
+    body, err := io.ReadAll(io.LimitReader(response.Body, maxBytes+1))
+    if err != nil {
+        return nil, fmt.Errorf("failed to read response: %w", err)
+    }
+    if int64(len(body)) > maxBytes {
+        return nil, fmt.Errorf("response exceeds the %d byte limit", maxBytes)
+    }
+
+    return &fetchedPage{
+        URL:         response.Request.URL.String(),
+        ContentType: response.Header.Get("Content-Type"),
+        Body:        body,
+        Fetched:     time.Now(),
+    }, nil
+}
+
+// transport returns an HTTP transport that checks every address it connects
+// to after DNS resolution, so that neither URLs nor redirects can reach
+// private networks through hostnames. Proxies are not used, since they would
+// hide the address of the server.
+func (t *WebFetchTool) transport() *http.Transport {
+    dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+    if !t.AllowPrivateNetworks {
+        dialer.Control = refusePrivateAddress
+    }
+    return &http.Transport{
+        DialContext:           dialer.DialContext,
+        ForceAttemptHTTP2:     true,
+        TLSHandshakeTimeout:   10 * time.Second,
+        ExpectContinueTimeout: time.Second,
+    }
+}
+
+// refusePrivateAddress is a net.Dialer Control function that refuses
+// connections to loopback, private, link-local and unspecified addresses
+func refusePrivateAddress(network, address string, _ syscall.RawConn) error {
+    host, _, err := net.SplitHostPort(address)
+    if err != nil {
+        return err
+    }
+    ip := net.ParseIP(host)
+    if ip == nil {
+        return fmt.Errorf("invalid address %s", address)
+    }
+    if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+        ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() {
+        return fmt.Errorf("connecting to %s is not allowed: it is a local or private network address", ip)
+    }
+    return nil
+}
+
+// render converts the page body to text according to its content type,
+// returning the page title when it has one
+func (t *WebFetchTool) render(page *fetchedPage, raw bool) (string, string, error) {
+    mediaType, _, err := mime.ParseMediaType(page.ContentType)
+    if err != nil || mediaType == "" {
+        mediaType = http.DetectContentType(page.Body)
+        mediaType, _, _ = mime.ParseMediaType(mediaType)
+    }
+    body := page.Body
+    if !utf8.Valid(body) {
+        body = bytes.ToValidUTF8(body, []byte("�"))
+    }
+
+    switch {
+    case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+        if raw {
+            return "", string(body), nil
+        }
+        base, _ := url.Parse(page.URL)
+        return HTMLToMarkdown(string(body), base)
+    case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+        var indented bytes.Buffer
+        if err := json.Indent(&indented, body, "", "  "); err != nil {
+            return "", string(body), nil
+        }
+        return "", indented.String(), nil
+    case strings.HasPrefix(mediaType, "text/") || mediaType == "application/xml" || strings.HasSuffix(mediaType, "+xml") ||
+        mediaType == "application/javascript":
+        return "", string(body), nil
+    default:
+        return "", "", fmt.Errorf("cannot display content of type %s", mediaType)
+    }
+}
+
+// paginate adds a header and returns at most MaxOutput characters from offset
+func (t *WebFetchTool) paginate(page *fetchedPage, title, content string, offset int) string {
+    maxOutput := t.MaxOutput
+    if maxOutput <= 0 {
+        maxOutput = DefaultFetchMaxOutput
+    }
+    runes := []rune(content)
+    if offset < 0 || offset > len(runes) {
+        offset = len(runes)
+    }
+    end := offset + maxOutput
+    if end > len(runes) {
+        end = len(runes)
+    }
+
+    var sb strings.Builder
+    sb.WriteString(fmt.Sprintf("URL: %s\n", page.URL))
+    if title != "" {
+        sb.WriteString(fmt.Sprintf("Title: %s\n", title))
+    }
+    if offset > 0 {
+        sb.WriteString(fmt.Sprintf("(showing characters %d-%d of %d)\n", offset, end, len(runes)))
+    }
+    sb.WriteString("\n")
+    sb.WriteString(string(runes[offset:end]))
+    if end < len(runes) {
+        sb.WriteString(fmt.Sprintf("\n\n[content truncated: %d more characters; fetch again with offset %d to continue]", len(runes)-end, end))
+    }
+    return sb.String()
+}
+
+// cachePath names the cache file for a URL
+func (t *WebFetchTool) cachePath(rawURL string) string {
+    sum := sha256.Sum256([]byte(rawURL))
+    return filepath.Join(t.CacheDir, hex.EncodeToString(sum[:])+".json")
+}
+
+// readCache returns a cached page that is still fresh
+func (t *WebFetchTool) readCache(rawURL string) (*fetchedPage, bool) {
+    if t.CacheDir == "" {
+        return nil, false
+    }
+    data, err := os.ReadFile(t.cachePath(rawURL))
+    if err != nil {
+        return nil, false
+    }
+    var page fetchedPage
+    if err := json.Unmarshal(data, &page); err != nil {
+        return nil, false
+    }
+    ttl := t.CacheTTL
+    if ttl <= 0 {
+        ttl = DefaultFetchCacheTTL
+    }
+    if time.Since(page.Fetched) > ttl {
+        return nil, false
+    }
+    // The domain rules may have changed since the page was cached
+    if final, err := url.Parse(page.URL); err != nil || t.checkURL(final) != nil {
+        return nil, false
+    }
+    return &page, true
+}
+
+// writeCache stores a page; caching is best effort, so errors are ignored
+func (t *WebFetchTool) writeCache(rawURL string, page *fetchedPage) {
+    if t.CacheDir == "" {
+        return
+    }
+    data, err := json.Marshal(page)
+    if err != nil {
+        return
+    }
+    if err := os.MkdirAll(t.CacheDir, 0755); err != nil {
+        return
+    }
+    WriteFileAtomic(t.cachePath(rawURL), data, 0644)
+}
+
+func (t *WebFetchTool) GetName() string {
+    return "web_fetch"
+}
+
+func (t *WebFetchTool) GetDescription() string {
+    return "Fetch a web page by URL and return its main content as Markdown (navigation and scripts removed); plain text and JSON are returned as is"
+}
+
+func (t *WebFetchTool) GetInputSchema() map[string]interface{} {
+    return objectSchema(map[string]interface{}{
+        "url":    property("string", "The http or https URL to fetch"),
+        "raw":    property("boolean", "Return the HTML source instead of converting it to Markdown"),
+        "offset": property("integer", "Character offset to continue reading a truncated page from"),
+    }, "url")
+}
+
+func (t *WebFetchTool) IsReadOnly() bool {
+    return true
+}
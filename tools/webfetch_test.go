@@ -0,0 +1,174 @@
+package tools
+
+import (
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+const testPage = `<!DOCTYPE html>
+<html><head><title>Test Page</title><script>var tracking = 1;</script><style>body { color: red }</style></head>
+<body>
+<nav><a href="/">Home</a> <a href="/about">About</a></nav>
+<main>
+<h1>Welcome</h1>
+<p>Some <strong>bold</strong> text and a <a href="https://example.com/docs">link</a>.</p>
+<ul><li>First</li><li>Second</li></ul>
+</main>
+<footer>Copyright</footer>
+</body></html>`
+
+// newFetchServer serves test pages, counting the requests it receives
+func newFetchServer(t *testing.T, requests *atomic.Int32) *httptest.Server {
+    t.Helper()
+    mux := http.NewServeMux()
+    mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "text/html; charset=utf-8")
+        fmt.Fprint(w, testPage)
+    })
+    mux.HandleFunc("/text", func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "text/plain")
+        fmt.Fprint(w, "just text")
+    })
+    mux.HandleFunc("/json", func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "application/json")
+        fmt.Fprint(w, `{"answer": 42}`)
+    })
+    mux.HandleFunc("/big", func(w http.ResponseWriter, r *http.Request) {
+        fmt.Fprint(w, strings.Repeat("x", 2048))
+    })
+    mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+        time.Sleep(500 * time.Millisecond)
+        fmt.Fprint(w, "late")
+    })
+    mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+        http.NotFound(w, r)
+    })
+    mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+        http.Redirect(w, r, "/loop", http.StatusFound)
+    })
+    mux.HandleFunc("/to-text", func(w http.ResponseWriter, r *http.Request) {
+        http.Redirect(w, r, "/text", http.StatusFound)
+    })
+    mux.HandleFunc("/to-metadata", func(w http.ResponseWriter, r *http.Request) {
+        http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+    })
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        requests.Add(1)
+        mux.ServeHTTP(w, r)
+    }))
+    t.Cleanup(server.Close)
+    return server
+}
+
+func TestWebFetchTool(t *testing.T) {
+    var requests atomic.Int32
+    server := newFetchServer(t, &requests)
+
+    tests := []struct {
+        name    string
+        tool    WebFetchTool
+        path    string
+        raw     bool
+        want    []string
+        notWant []string
+        wantErr string
+    }{
+        {
+            name:    "html as markdown",
+            path:    "/page",
+            want:    []string{"Test Page", "# Welcome", "**bold**", "[link](https://example.com/docs)", "First"},
+            notWant: []string{"tracking", "color: red", "About"},
+        },
+        {name: "raw html", path: "/page", raw: true, want: []string{"<h1>Welcome</h1>"}},
+        {name: "plain text", path: "/text", want: []string{"just text"}},
+        {name: "json", path: "/json", want: []string{`"answer": 42`}},
+        {name: "redirect", path: "/to-text", want: []string{"just text"}},
+        {name: "error status", path: "/missing", wantErr: "404"},
+        {name: "size limit", tool: WebFetchTool{MaxBytes: 1024}, path: "/big", wantErr: "byte limit"},
+        {name: "timeout", tool: WebFetchTool{Timeout: 100 * time.Millisecond}, path: "/slow", wantErr: "timed out"},
+        {name: "redirect limit", tool: WebFetchTool{MaxRedirects: 2}, path: "/loop", wantErr: "stopped after 2 redirects"},
+        {name: "allowed domain", tool: WebFetchTool{AllowDomains: []string{"127.0.0.1"}}, path: "/text", want: []string{"just text"}},
+        {name: "domain not allowed", tool: WebFetchTool{AllowDomains: []string{"example.com"}}, path: "/text", wantErr: "only example.com may be fetched"},
+        {name: "no domain allowed", tool: WebFetchTool{AllowDomains: []string{}}, path: "/text", wantErr: "no domains may be fetched"},
+        {name: "denied domain", tool: WebFetchTool{DenyDomains: []string{"127.0.0.1"}}, path: "/text", wantErr: "not allowed"},
+        {name: "redirect to a denied domain", tool: WebFetchTool{DenyDomains: []string{"169.254.169.254"}}, path: "/to-metadata", wantErr: "not allowed"},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            tool := test.tool
+            tool.AllowPrivateNetworks = true // The test server is on loopback
+            input := fmt.Sprintf(`{"url":%q,"raw":%v}`, server.URL+test.path, test.raw)
+            output, err := tool.Execute(input)
+            if test.wantErr != "" {
+                if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+                    t.Fatalf("Execute error = %v, want %q", err, test.wantErr)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("Execute: %v", err)
+            }
+            for _, want := range test.want {
+                if !strings.Contains(output, want) {
+                    t.Errorf("output does not contain %q:\n%s", want, output)
+                }
+            }
+            for _, notWant := range test.notWant {
+                if strings.Contains(output, notWant) {
+                    t.Errorf("output contains %q:\n%s", notWant, output)
+                }
+            }
+        })
+    }
+}
+
+func TestWebFetchToolRefusesPrivateAddresses(t *testing.T) {
+    var requests atomic.Int32
+    server := newFetchServer(t, &requests)
+    tool := &WebFetchTool{Timeout: 2 * time.Second}
+
+    for _, url := range []string{
+        server.URL + "/text",
+        strings.Replace(server.URL, "127.0.0.1", "localhost", 1) + "/text",
+        "http://169.254.169.254/latest/meta-data/",
+        "http://[::1]:1/",
+        "http://10.0.0.1/",
+    } {
+        _, err := tool.Execute(fmt.Sprintf(`{"url":%q}`, url))
+        if err == nil || !strings.Contains(err.Error(), "private network address") {
+            t.Errorf("fetching %s: error = %v, want it refused", url, err)
+        }
+    }
+    if got := requests.Load(); got != 0 {
+        t.Errorf("server received %d requests, want none", got)
+    }
+}
+
+func TestWebFetchToolCache(t *testing.T) {
+    var requests atomic.Int32
+    server := newFetchServer(t, &requests)
+    tool := &WebFetchTool{CacheDir: t.TempDir(), AllowPrivateNetworks: true}
+    input := fmt.Sprintf(`{"url":%q}`, server.URL+"/text")
+
+    for i := 0; i < 2; i++ {
+        if output, err := tool.Execute(input); err != nil || !strings.Contains(output, "just text") {
+            t.Fatalf("Execute = %q, %v", output, err)
+        }
+    }
+    if got := requests.Load(); got != 1 {
+        t.Errorf("server received %d requests, want 1 with the second served from the cache", got)
+    }
+
+    expired := &WebFetchTool{CacheDir: tool.CacheDir, CacheTTL: time.Nanosecond, AllowPrivateNetworks: true}
+    if _, err := expired.Execute(input); err != nil {
+        t.Fatal(err)
+    }
+    if got := requests.Load(); got != 2 {
+        t.Errorf("server received %d requests, want an expired entry to be fetched again", got)
+    }
+}